@@ -0,0 +1,251 @@
+// Package chainbuilder builds chain fixtures in Go, so tests describe the
+// chain they need instead of writing block headers by hand:
+//
+//	content, err := chainbuilder.NewChain().
+//		AddBlocks(3).
+//		AddBlock(chainbuilder.WithTx(tx)).
+//		Fork(2).
+//		AddBlocks(3).
+//		Build()
+//
+// Hashes, merkle roots, heights, the links between blocks and confirmations
+// are derived from the blocks, as is the chainwork reported by
+// getblockchaininfo. The chain with the most work is the active chain, the
+// blocks of the other branches are stale like in bitcoind.
+package chainbuilder
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/gonative-cc/btc-mock-node/mockserver"
+)
+
+// blockInterval is the time between a block and its parent, unless set with
+// WithTime.
+const blockInterval = 10 * time.Minute
+
+// Chain builds the blocks of a chain and its forks. The methods return the
+// chain to be chained, the first error is kept and returned by Build.
+type Chain struct {
+	params *chaincfg.Params
+	// blocks are all blocks in the order they were added, the genesis block
+	// first
+	blocks []*block
+	// tip is the block the next block is added on
+	tip *block
+	err error
+}
+
+type block struct {
+	msgBlock *wire.MsgBlock
+	hash     chainhash.Hash
+	height   int32
+	parent   *block
+	// work is the total work of the chain up to the block
+	work *big.Int
+}
+
+// NewChain starts a regtest chain holding only its genesis block.
+func NewChain() *Chain {
+	return (&Chain{}).Genesis(&chaincfg.RegressionNetParams)
+}
+
+// Genesis restarts the chain from the genesis block of params, which sets
+// the network of the chain. It must be called before any block is added.
+func (c *Chain) Genesis(params *chaincfg.Params) *Chain {
+	if len(c.blocks) > 1 {
+		return c.fail(errors.New("the genesis block must be set before adding blocks"))
+	}
+	genesis := &block{
+		msgBlock: params.GenesisBlock,
+		hash:     *params.GenesisHash,
+		work:     blockchain.CalcWork(params.GenesisBlock.Header.Bits),
+	}
+	c.params, c.blocks, c.tip = params, []*block{genesis}, genesis
+	return c
+}
+
+type blockOptions struct {
+	txs      []*wire.MsgTx
+	time     time.Time
+	pkScript []byte
+}
+
+// BlockOption configures a block added by AddBlock.
+type BlockOption func(*blockOptions)
+
+// WithTx adds transactions to the block, after its coinbase. They are not
+// validated.
+func WithTx(txs ...*wire.MsgTx) BlockOption {
+	return func(o *blockOptions) {
+		o.txs = append(o.txs, txs...)
+	}
+}
+
+// WithTime sets the timestamp of the block, 10 minutes after its parent by
+// default.
+func WithTime(timestamp time.Time) BlockOption {
+	return func(o *blockOptions) {
+		o.time = timestamp
+	}
+}
+
+// WithCoinbasePkScript sets the script the coinbase pays the subsidy to,
+// OP_TRUE by default so anyone can spend it.
+func WithCoinbasePkScript(pkScript []byte) BlockOption {
+	return func(o *blockOptions) {
+		o.pkScript = pkScript
+	}
+}
+
+// AddBlock adds a block on top of the tip, which becomes the new tip.
+func (c *Chain) AddBlock(opts ...BlockOption) *Chain {
+	if c.err != nil {
+		return c
+	}
+	o := blockOptions{
+		time:     c.tip.msgBlock.Header.Timestamp.Add(blockInterval),
+		pkScript: []byte{txscript.OP_TRUE},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	msgBlock, err := c.newBlock(o)
+	if err != nil {
+		return c.fail(err)
+	}
+	added := &block{
+		msgBlock: msgBlock,
+		hash:     msgBlock.BlockHash(),
+		height:   c.tip.height + 1,
+		parent:   c.tip,
+		work:     new(big.Int).Add(c.tip.work, blockchain.CalcWork(msgBlock.Header.Bits)),
+	}
+	c.blocks = append(c.blocks, added)
+	c.tip = added
+	return c
+}
+
+// AddBlocks adds n empty blocks on top of the tip.
+func (c *Chain) AddBlocks(n int) *Chain {
+	for i := 0; i < n; i++ {
+		c.AddBlock()
+	}
+	return c
+}
+
+// Fork moves the tip back to the block at height of the branch of the tip,
+// so the next blocks start a new branch from it.
+func (c *Chain) Fork(height int32) *Chain {
+	if c.err != nil {
+		return c
+	}
+	if height < 0 || height > c.tip.height {
+		return c.fail(fmt.Errorf("fork height %d is out of range [0, %d]", height, c.tip.height))
+	}
+	for c.tip.height > height {
+		c.tip = c.tip.parent
+	}
+	return c
+}
+
+// Tip returns the hash of the block the next block is added on.
+func (c *Chain) Tip() chainhash.Hash {
+	return c.tip.hash
+}
+
+// Build returns the content of the chain. The branch with the most work, the
+// first one built on equal work, is the active chain. The blocks of the
+// other branches are stale: they have -1 confirmations, and their
+// transactions none, unless the active chain confirms them too.
+func (c *Chain) Build() (mockserver.DataContent, error) {
+	if c.err != nil {
+		return mockserver.DataContent{}, c.err
+	}
+
+	best := c.blocks[0]
+	for _, b := range c.blocks {
+		if b.work.Cmp(best.work) > 0 {
+			best = b
+		}
+	}
+	activeBlocks := make([]*wire.MsgBlock, best.height+1)
+	active := make(map[chainhash.Hash]bool, len(activeBlocks))
+	for b := best; b != nil; b = b.parent {
+		activeBlocks[b.height] = b.msgBlock
+		active[b.hash] = true
+	}
+	activeContent, err := mockserver.BlocksToContent(activeBlocks, 0, c.params)
+	if err != nil {
+		return mockserver.DataContent{}, err
+	}
+	activeTxids := make(map[string]bool, len(activeContent.Transactions))
+	for _, transaction := range activeContent.Transactions {
+		activeTxids[transaction.Txid] = true
+	}
+
+	// the stale blocks go first, so the active chain wins the lookups by
+	// height and txid
+	var dataContent mockserver.DataContent
+	staleTransactions := make(map[string]int)
+	for _, b := range c.blocks {
+		if active[b.hash] {
+			continue
+		}
+		blockHeader := mockserver.NewBlockHeaderResult(&b.msgBlock.Header, b.height)
+		blockHeader.Confirmations = -1
+		dataContent.BlockHeaders = append(dataContent.BlockHeaders, blockHeader)
+
+		for _, tx := range b.msgBlock.Transactions {
+			transaction, err := mockserver.NewTxRawResult(tx, c.params)
+			if err != nil {
+				return mockserver.DataContent{}, fmt.Errorf("block %s: %w", blockHeader.Hash, err)
+			}
+			if activeTxids[transaction.Txid] {
+				continue
+			}
+			transaction.BlockHash = blockHeader.Hash
+			transaction.Time = blockHeader.Time
+			transaction.Blocktime = blockHeader.Time
+			// a transaction of several stale blocks is kept once, with the
+			// last one
+			if index, ok := staleTransactions[transaction.Txid]; ok {
+				dataContent.Transactions[index] = transaction
+				continue
+			}
+			staleTransactions[transaction.Txid] = len(dataContent.Transactions)
+			dataContent.Transactions = append(dataContent.Transactions, transaction)
+		}
+	}
+	dataContent.BlockHeaders = append(dataContent.BlockHeaders, activeContent.BlockHeaders...)
+	dataContent.Transactions = append(dataContent.Transactions, activeContent.Transactions...)
+	return dataContent, nil
+}
+
+func (c *Chain) fail(err error) *Chain {
+	if c.err == nil {
+		c.err = err
+	}
+	return c
+}
+
+// newBlock assembles a block on top of the tip, see mockserver.NewBlock.
+func (c *Chain) newBlock(o blockOptions) (*wire.MsgBlock, error) {
+	height := c.tip.height + 1
+	// the index of the block makes the coinbases of sibling blocks differ
+	coinbase, err := mockserver.NewCoinbase(height, uint64(len(c.blocks)), blockchain.CalcBlockSubsidy(height, c.params), o.pkScript)
+	if err != nil {
+		return nil, err
+	}
+	return mockserver.NewBlock(c.tip.hash, o.time, c.params.PowLimitBits, append([]*wire.MsgTx{coinbase}, o.txs...))
+}
@@ -0,0 +1,104 @@
+package chainbuilder
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gonative-cc/btc-mock-node/mocknode"
+)
+
+func TestBuild(t *testing.T) {
+	ctx := context.Background()
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}))
+	txid := tx.TxHash()
+
+	chain := NewChain().AddBlocks(3).AddBlock(WithTx(tx))
+	staleTip := chain.Tip()
+	content, err := chain.Fork(2).AddBlocks(3).Build()
+	require.NoError(t, err)
+	node := mocknode.Start(t, mocknode.WithContent(content))
+
+	// the fork has more work and is the active chain
+	blockChainInfo, err := node.Client.GetBlockChainInfo(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "regtest", blockChainInfo.Chain)
+	assert.Equal(t, int32(5), blockChainInfo.Blocks)
+	assert.Equal(t, chain.Tip().String(), blockChainInfo.BestBlockHash)
+	// every regtest block has a work of 2
+	assert.Equal(t, "000000000000000000000000000000000000000000000000000000000000000c", blockChainInfo.ChainWork)
+
+	forkHash, err := node.Client.GetBlockHash(ctx, 3)
+	require.NoError(t, err)
+	forkParentHash := chain.Fork(2).Tip()
+	forkParent, err := node.Client.GetBlockHeader(ctx, &forkParentHash, true)
+	require.NoError(t, err)
+	assert.Equal(t, forkHash.String(), forkParent.NextHash)
+	assert.Equal(t, int64(4), forkParent.Confirmations)
+
+	forkHeader, err := node.Client.GetBlockHeader(ctx, forkHash, true)
+	require.NoError(t, err)
+	assert.Equal(t, forkParent.Hash, forkHeader.PreviousHash)
+	assert.Equal(t, int32(3), forkHeader.Height)
+	assert.Equal(t, forkParent.Time+int64(blockInterval/time.Second), forkHeader.Time)
+	bits, err := strconv.ParseUint(forkHeader.Bits, 16, 32)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, blockchain.HashToBig(forkHash).Cmp(blockchain.CompactToBig(uint32(bits))), 0)
+
+	// the replaced branch is stale
+	staleHeader, err := node.Client.GetBlockHeader(ctx, &staleTip, true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), staleHeader.Confirmations)
+	assert.Equal(t, int32(4), staleHeader.Height)
+	assert.Empty(t, staleHeader.NextHash)
+
+	transaction, err := node.Client.GetRawTransaction(ctx, &txid, true, nil)
+	require.NoError(t, err)
+	assert.Equal(t, staleTip.String(), transaction.BlockHash)
+	assert.Equal(t, uint64(0), transaction.Confirmations)
+	verbosity := 1
+	staleBlock, err := node.Client.GetBlock(ctx, &staleTip, &verbosity)
+	require.NoError(t, err)
+	require.Len(t, staleBlock.Tx, 2)
+	assert.Equal(t, staleHeader.MerkleRoot, staleBlock.MerkleRoot)
+}
+
+func TestBuildEqualWork(t *testing.T) {
+	chain := NewChain().AddBlocks(2)
+	firstTip := chain.Tip()
+	content, err := chain.Fork(1).AddBlock().Build()
+	require.NoError(t, err)
+
+	// the first branch built stays active
+	require.Len(t, content.BlockHeaders, 4)
+	assert.Equal(t, chain.Tip().String(), content.BlockHeaders[0].Hash)
+	assert.Equal(t, int64(-1), content.BlockHeaders[0].Confirmations)
+	assert.Equal(t, firstTip.String(), content.BlockHeaders[3].Hash)
+	assert.Equal(t, int64(1), content.BlockHeaders[3].Confirmations)
+}
+
+func TestGenesis(t *testing.T) {
+	content, err := NewChain().Genesis(&chaincfg.MainNetParams).AddBlock().Build()
+	require.NoError(t, err)
+	require.Len(t, content.BlockHeaders, 2)
+	assert.Equal(t, chaincfg.MainNetParams.GenesisHash.String(), content.BlockHeaders[0].Hash)
+	assert.Equal(t, "1d00ffff", content.BlockHeaders[1].Bits)
+
+	_, err = NewChain().AddBlock().Genesis(&chaincfg.MainNetParams).Build()
+	assert.ErrorContains(t, err, "before adding blocks")
+
+	_, err = NewChain().AddBlocks(2).Fork(3).AddBlock().Build()
+	assert.ErrorContains(t, err, "fork height 3 is out of range [0, 2]")
+}
@@ -0,0 +1,89 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gonative-cc/btc-mock-node/client"
+	"github.com/gonative-cc/btc-mock-node/mockserver"
+)
+
+// TestClientMatchesHandler fails when a method of the handler and the field
+// of the client calling it disagree, or when one of them is missing.
+func TestClientMatchesHandler(t *testing.T) {
+	handlerType := reflect.TypeOf(&mockserver.MockServerHandler{})
+	clientType := reflect.TypeOf(client.Client{})
+	contextType := reflect.TypeOf((*context.Context)(nil)).Elem()
+
+	for i := 0; i < handlerType.NumMethod(); i++ {
+		method := handlerType.Method(i)
+		t.Run(method.Name, func(t *testing.T) {
+			field, ok := clientType.FieldByName(method.Name)
+			require.True(t, ok, "client.Client has no %s field", method.Name)
+
+			// the client takes a context in front of the handler parameters,
+			// the handler takes its receiver
+			in := []reflect.Type{contextType}
+			for j := 1; j < method.Type.NumIn(); j++ {
+				in = append(in, method.Type.In(j))
+			}
+			var out []reflect.Type
+			for j := 0; j < method.Type.NumOut(); j++ {
+				out = append(out, method.Type.Out(j))
+			}
+			assert.Equal(t, reflect.FuncOf(in, out, false).String(), field.Type.String())
+		})
+	}
+
+	for i := 0; i < clientType.NumField(); i++ {
+		name := clientType.Field(i).Name
+		_, ok := handlerType.MethodByName(name)
+		assert.True(t, ok, "MockServerHandler has no %s method", name)
+	}
+}
+
+func TestNew(t *testing.T) {
+	// record the headers of the calls on their way to the mock
+	mockHandler := mockserver.NewHTTPHandler(mockserver.NewMockServerHandler("../data/mainnet_oldest_blocks.json"))
+	headers := make(chan http.Header, 10)
+	mockService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		headers <- req.Header.Clone()
+		mockHandler.ServeHTTP(w, req)
+	}))
+	defer mockService.Close()
+
+	ctx := context.Background()
+	c, closer, err := client.New(ctx, mockService.URL, client.WithBasicAuth("user", "pass"), client.WithHeader("X-Custom", "value"))
+	require.NoError(t, err)
+	defer closer()
+
+	blockCount, err := c.GetBlockCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int32(10), blockCount)
+	header := <-headers
+	assert.Equal(t, "Basic dXNlcjpwYXNz", header.Get("Authorization"))
+	assert.Equal(t, "value", header.Get("X-Custom"))
+
+	// admin methods share their types with the mock
+	fault := client.Fault{Mode: client.FaultHTTPError, FailNext: 1}
+	ok, err := c.MockSetFault(ctx, "getblockcount", fault)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	faults, err := c.MockGetFaults(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]client.Fault{"getblockcount": fault}, faults)
+	_, err = c.GetBlockCount(ctx)
+	assert.Error(t, err)
+
+	// the context cancels the call
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = c.GetBestBlockHash(canceled)
+	assert.ErrorIs(t, err, context.Canceled)
+}
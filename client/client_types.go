@@ -1,19 +1,197 @@
 package client
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 )
 
+// Client has a field for every method served by mockserver.MockServerHandler,
+// with the same parameters and results behind a leading context. The fields
+// are filled by New.
 type Client struct {
-	Ping              func(int) int
-	GetBestBlockHash  func() (*chainhash.Hash, error)
-	GetBlock          func(blockHash *chainhash.Hash, verbosity *int) (*btcjson.GetBlockVerboseResult, error)
-	GetBlockCount     func() (int64, error)
-	GetBlockHash      func(blockHeight int64) (*chainhash.Hash, error)
-	GetBlockHeader    func(blockHash *chainhash.Hash, verbose bool) (*btcjson.GetBlockHeaderVerboseResult, error)
-	GetTxOut          func(txHash *chainhash.Hash, index uint32, mempool bool) (*btcjson.GetTxOutResult, error)
-	GetRawTransaction func(txHash *chainhash.Hash, verbose bool, blockHash *chainhash.Hash) (*btcjson.TxRawResult, error)
-	GetNetworkInfo    func() (*btcjson.GetNetworkInfoResult, error)
-	GetInfo           func() (*btcjson.InfoWalletResult, error)
+	Ping               func(ctx context.Context, in int) int
+	GetBestBlockHash   func(ctx context.Context) (*chainhash.Hash, error)
+	GetBlock           func(ctx context.Context, blockHash *chainhash.Hash, verbosity *int) (*GetBlockResult, error)
+	GetBlockCount      func(ctx context.Context) (int32, error)
+	GetBlockHash       func(ctx context.Context, blockHeight int32) (*chainhash.Hash, error)
+	GetBlockHeader     func(ctx context.Context, blockHash *chainhash.Hash, verbose bool) (*btcjson.GetBlockHeaderVerboseResult, error)
+	GetTxOut           func(ctx context.Context, txHash *chainhash.Hash, index uint32, mempool bool) (*btcjson.GetTxOutResult, error)
+	GetRawTransaction  func(ctx context.Context, txHash *chainhash.Hash, verbose bool, blockHash *chainhash.Hash) (*btcjson.TxRawResult, error)
+	GetNetworkInfo     func(ctx context.Context) (*btcjson.GetNetworkInfoResult, error)
+	GetBlockChainInfo  func(ctx context.Context) (*btcjson.GetBlockChainInfoResult, error)
+	GetInfo            func(ctx context.Context) (*btcjson.InfoWalletResult, error)
+	SetMockTime        func(ctx context.Context, timestamp int64) error
+	SendRawTransaction func(ctx context.Context, hexTx string, maxFeeRate json.RawMessage) (*chainhash.Hash, error)
+	ValidateAddress    func(ctx context.Context, address string) (*ValidateAddressResult, error)
+	GetAddressInfo     func(ctx context.Context, address string) (*btcjson.GetAddressInfoResult, error)
+	GetDescriptorInfo  func(ctx context.Context, descriptor string) (*btcjson.GetDescriptorInfoResult, error)
+	DeriveAddresses    func(ctx context.Context, descriptor string, derivationRange json.RawMessage) ([]string, error)
+
+	// admin methods
+	MockReload      func(ctx context.Context) (*DataDiff, error)
+	MockDumpState   func(ctx context.Context, path string) (*DumpStateResult, error)
+	MockSnapshot    func(ctx context.Context) (uint64, error)
+	MockRestore     func(ctx context.Context, id uint64) (bool, error)
+	MockSetFault    func(ctx context.Context, method string, fault Fault) (bool, error)
+	MockClearFaults func(ctx context.Context) (bool, error)
+	MockGetFaults   func(ctx context.Context) (map[string]Fault, error)
+	MockAdvanceTime func(ctx context.Context, seconds int64) (int64, error)
+}
+
+// ValidateAddressResult is the result of validateaddress. Unlike
+// btcjson.ValidateAddressChainResult it has the scriptPubKey and error of
+// bitcoind.
+type ValidateAddressResult struct {
+	IsValid        bool    `json:"isvalid"`
+	Address        string  `json:"address,omitempty"`
+	ScriptPubKey   string  `json:"scriptPubKey,omitempty"`
+	IsScript       *bool   `json:"isscript,omitempty"`
+	IsWitness      *bool   `json:"iswitness,omitempty"`
+	WitnessVersion *int    `json:"witness_version,omitempty"`
+	WitnessProgram *string `json:"witness_program,omitempty"`
+	// Error is why an invalid address is invalid.
+	Error string `json:"error,omitempty"`
+}
+
+// GetBlockResult is the result of getblock, shaped by the verbosity like in
+// bitcoind: the serialized block in hex at verbosity 0, the block with the
+// txids of its transactions in Tx at verbosity 1 and with its decoded
+// transactions in RawTx at verbosity 2. In json the transactions of both
+// verbose results are in the tx field.
+type GetBlockResult struct {
+	// Hex is the serialized block, set at verbosity 0 only.
+	Hex string
+	btcjson.GetBlockVerboseResult
+}
+
+func (r GetBlockResult) MarshalJSON() ([]byte, error) {
+	if r.Hex != "" {
+		return json.Marshal(r.Hex)
+	}
+	if r.RawTx == nil {
+		return json.Marshal(r.GetBlockVerboseResult)
+	}
+	// the tx field of the outer struct hides the one of the block
+	block := struct {
+		btcjson.GetBlockVerboseResult
+		Tx []btcjson.TxRawResult `json:"tx"`
+	}{r.GetBlockVerboseResult, r.RawTx}
+	block.GetBlockVerboseResult.RawTx = nil
+	return json.Marshal(block)
+}
+
+func (r *GetBlockResult) UnmarshalJSON(data []byte) error {
+	*r = GetBlockResult{}
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &r.Hex)
+	}
+	var block struct {
+		btcjson.GetBlockVerboseResult
+		Tx []json.RawMessage `json:"tx"`
+	}
+	if err := json.Unmarshal(data, &block); err != nil {
+		return err
+	}
+	r.GetBlockVerboseResult = block.GetBlockVerboseResult
+	for _, rawTx := range block.Tx {
+		if len(rawTx) > 0 && rawTx[0] == '"' {
+			var txid string
+			if err := json.Unmarshal(rawTx, &txid); err != nil {
+				return err
+			}
+			r.Tx = append(r.Tx, txid)
+			continue
+		}
+		var transaction btcjson.TxRawResult
+		if err := json.Unmarshal(rawTx, &transaction); err != nil {
+			return err
+		}
+		r.RawTx = append(r.RawTx, transaction)
+	}
+	return nil
+}
+
+// DataDiff summarizes the changes between two versions of the data content.
+// Entries are identified by block hash and txid.
+type DataDiff struct {
+	AddedBlocks         []string `json:"added_blocks"`
+	RemovedBlocks       []string `json:"removed_blocks"`
+	ChangedBlocks       []string `json:"changed_blocks"`
+	AddedTransactions   []string `json:"added_transactions"`
+	RemovedTransactions []string `json:"removed_transactions"`
+	ChangedTransactions []string `json:"changed_transactions"`
+	NetworkInfoChanged  bool     `json:"network_info_changed"`
+}
+
+// String returns a one line summary of the diff.
+func (d DataDiff) String() string {
+	return fmt.Sprintf(
+		"blocks +%d -%d ~%d, transactions +%d -%d ~%d, network info changed: %t",
+		len(d.AddedBlocks), len(d.RemovedBlocks), len(d.ChangedBlocks),
+		len(d.AddedTransactions), len(d.RemovedTransactions), len(d.ChangedTransactions),
+		d.NetworkInfoChanged,
+	)
+}
+
+// DumpStateResult is the result of mock_dumpstate.
+type DumpStateResult struct {
+	Path         string `json:"path"`
+	BlockHeaders int    `json:"block_headers"`
+	Transactions int    `json:"transactions"`
+}
+
+// FaultMode is how a call fails when a fault triggers.
+type FaultMode string
+
+const (
+	// FaultRPCError answers with a json-rpc error, the default mode.
+	FaultRPCError FaultMode = "rpc_error"
+	// FaultHTTPError answers with an http error status and no json-rpc body.
+	FaultHTTPError FaultMode = "http_error"
+	// FaultReset closes the connection without answering.
+	FaultReset FaultMode = "reset"
+	// FaultTruncate serves the call but cuts the response body in half.
+	FaultTruncate FaultMode = "truncate"
+)
+
+// AllMethods is the fault key matching every method without its own fault.
+const AllMethods = "*"
+
+// Duration is a time.Duration written as a string like "250ms" in json.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(value)
+	*d = Duration(duration)
+	return err
+}
+
+// Fault describes how calls of a method misbehave.
+type Fault struct {
+	// Latency delays every call, plus a random extra delay up to Jitter.
+	Latency Duration `json:"latency,omitempty"`
+	Jitter  Duration `json:"jitter,omitempty"`
+	// Mode is how a failing call fails.
+	Mode FaultMode `json:"mode,omitempty"`
+	// Rate is the probability in [0, 1] that a call fails.
+	Rate float64 `json:"rate,omitempty"`
+	// FailNext is the number of upcoming calls that fail whatever the Rate.
+	FailNext int `json:"fail_next,omitempty"`
+	// ErrorCode is the code of FaultRPCError errors, ErrRPCMisc when zero.
+	ErrorCode btcjson.RPCErrorCode `json:"error_code,omitempty"`
+	// HTTPStatus is the status of FaultHTTPError answers, 503 when zero.
+	HTTPStatus int `json:"http_status,omitempty"`
 }
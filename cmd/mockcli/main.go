@@ -0,0 +1,13 @@
+// Command mockcli is a bitcoin-cli compatible client of the mock node, see
+// package mockcli. It is also the cli subcommand of the mock node binary.
+package main
+
+import (
+	"os"
+
+	"github.com/gonative-cc/btc-mock-node/mockcli"
+)
+
+func main() {
+	os.Exit(mockcli.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
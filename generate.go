@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/gonative-cc/btc-mock-node/generator"
+	"github.com/gonative-cc/btc-mock-node/mockserver"
+)
+
+// runGenerate is the `generate` subcommand: it builds a synthetic regtest
+// chain and writes it as a data file, or serves it when no output file is
+// given.
+// example: generate -blocks 500 -max-txs 20 -address-types p2wpkh,p2tr -out chain.json.gz
+func runGenerate(args []string) {
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	seed := flags.Int64("seed", 1, "random seed, the same seed and flags always generate the same chain")
+	blocks := flags.Int("blocks", 200, "number of blocks on top of the genesis block")
+	minTxs := flags.Int("min-txs", 1, "minimum number of transactions per block besides the coinbase")
+	maxTxs := flags.Int("max-txs", 5, "maximum number of transactions per block besides the coinbase")
+	addressTypes := flags.String("address-types", "",
+		"comma separated address types to pay to: p2pkh, p2sh-p2wpkh, p2wpkh, p2tr (default all)")
+	minFeeRate := flags.Int64("min-fee-rate", 1, "minimum fee rate in sat/vB")
+	maxFeeRate := flags.Int64("max-fee-rate", 50, "maximum fee rate in sat/vB")
+	feeDistribution := flags.String("fee-distribution", string(generator.FeeUniform),
+		"distribution of the fee rates: uniform or exponential")
+	blockInterval := flags.Duration("block-interval", 10*time.Minute, "time between two blocks")
+	outPath := flags.String("out", "",
+		"write the chain to this data file (.json, .json.gz or .json.zst) instead of serving it")
+	listenAddr := flags.String("listen", "", "serve on this address, a random local port by default")
+	mineInterval := flags.Duration("mine-interval", 0,
+		"when serving, mine a block confirming the mempool at this interval, 0 disables it")
+	minePoisson := flags.Bool("mine-poisson", false,
+		"with -mine-interval, draw the time between blocks from a Poisson process of this mean interval")
+	_ = flags.Parse(args)
+
+	cfg := generator.Config{
+		Seed:            *seed,
+		Blocks:          *blocks,
+		MinTxsPerBlock:  *minTxs,
+		MaxTxsPerBlock:  *maxTxs,
+		MinFeeRate:      *minFeeRate,
+		MaxFeeRate:      *maxFeeRate,
+		FeeDistribution: generator.FeeDistribution(*feeDistribution),
+		BlockInterval:   *blockInterval,
+	}
+	if *addressTypes != "" {
+		for _, addressType := range strings.Split(*addressTypes, ",") {
+			cfg.AddressTypes = append(cfg.AddressTypes, generator.AddressType(strings.TrimSpace(addressType)))
+		}
+	}
+
+	dataContent, err := generator.GenerateContent(cfg)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to generate chain")
+		return
+	}
+	log.Info().Msgf("Generated %d blocks and %d transactions",
+		len(dataContent.BlockHeaders), len(dataContent.Transactions))
+
+	if *outPath != "" {
+		if err := mockserver.WriteDataFile(*outPath, dataContent); err != nil {
+			log.Error().Err(err).Msg("Failed to write data file")
+			return
+		}
+		log.Info().Msgf("Wrote generated chain to %s", *outPath)
+		return
+	}
+
+	dataStore := &mockserver.DataStore{}
+	_ = dataStore.Replace(dataContent)
+	serverHandler := &mockserver.MockServerHandler{
+		Store:  dataStore,
+		Faults: mockserver.NewFaultInjector(),
+		Clock:  &mockserver.Clock{},
+	}
+	serve(*listenAddr, serverHandler, serveOptions{mineInterval: *mineInterval, minePoisson: *minePoisson})
+}
@@ -0,0 +1,367 @@
+// Package generator builds deterministic synthetic chains: blocks with valid
+// merkle roots, witness commitments and proof of work, holding transactions
+// that properly sign for the outputs they spend.
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/gonative-cc/btc-mock-node/mockserver"
+)
+
+// FeeDistribution is how the fee rates of the generated transactions are
+// drawn between Config.MinFeeRate and Config.MaxFeeRate.
+type FeeDistribution string
+
+const (
+	// FeeUniform draws every fee rate in the range with the same probability.
+	FeeUniform FeeDistribution = "uniform"
+	// FeeExponential draws mostly low fee rates with a few expensive ones,
+	// like a quiet mempool.
+	FeeExponential FeeDistribution = "exponential"
+)
+
+const (
+	// dustLimit is the smallest output value the generator creates
+	dustLimit = 1000
+	// keysPerAddressType is the size of the wallet for every address type
+	keysPerAddressType = 4
+	// maxTxsPerBlock keeps generated blocks well below the weight limit
+	maxTxsPerBlock = 2000
+)
+
+// Config describes the chain to generate. The same config always produces
+// the same chain.
+type Config struct {
+	// Params is the network of the chain, regtest when nil. The proof of work
+	// is solved for real, so the network must have a trivial proof of work
+	// limit like regtest or simnet.
+	Params *chaincfg.Params
+	Seed   int64
+	// Blocks is the number of blocks generated on top of the genesis block.
+	Blocks int
+	// MinTxsPerBlock and MaxTxsPerBlock bound the number of transactions
+	// besides the coinbase in every block. Coinbase outputs are only spent
+	// once mature, so the first blocks of a chain hold no transactions.
+	MinTxsPerBlock int
+	MaxTxsPerBlock int
+	// AddressTypes are the outputs the wallet pays to, all types when empty.
+	AddressTypes []AddressType
+	// MinFeeRate and MaxFeeRate bound the fee rate in sat/vB, at least 1.
+	MinFeeRate      int64
+	MaxFeeRate      int64
+	FeeDistribution FeeDistribution
+	// BlockInterval is the time between two blocks, 10 minutes when zero.
+	BlockInterval time.Duration
+}
+
+func (c Config) withDefaults() (Config, error) {
+	if c.Params == nil {
+		c.Params = &chaincfg.RegressionNetParams
+	}
+	if len(c.AddressTypes) == 0 {
+		c.AddressTypes = AddressTypes
+	}
+	if c.MinFeeRate < 1 {
+		c.MinFeeRate = 1
+	}
+	if c.MaxFeeRate < c.MinFeeRate {
+		c.MaxFeeRate = c.MinFeeRate
+	}
+	if c.MaxTxsPerBlock < c.MinTxsPerBlock {
+		c.MaxTxsPerBlock = c.MinTxsPerBlock
+	}
+	if c.FeeDistribution == "" {
+		c.FeeDistribution = FeeUniform
+	}
+	if c.BlockInterval == 0 {
+		c.BlockInterval = 10 * time.Minute
+	}
+
+	switch {
+	case c.Blocks < 0:
+		return c, errors.New("number of blocks can not be negative")
+	case c.MinTxsPerBlock < 0:
+		return c, errors.New("number of transactions per block can not be negative")
+	case c.MaxTxsPerBlock > maxTxsPerBlock:
+		return c, fmt.Errorf("at most %d transactions per block are supported", maxTxsPerBlock)
+	case c.Params.PowLimitBits != chaincfg.RegressionNetParams.PowLimitBits:
+		return c, fmt.Errorf("the proof of work of %s can not be solved", c.Params.Name)
+	case c.FeeDistribution != FeeUniform && c.FeeDistribution != FeeExponential:
+		return c, fmt.Errorf("unknown fee distribution %q", c.FeeDistribution)
+	}
+	return c, nil
+}
+
+// utxo is an output owned by the generator wallet.
+type utxo struct {
+	outPoint wire.OutPoint
+	value    int64
+	key      *walletKey
+	// height is the height of the block holding the output
+	height int32
+}
+
+// Generator extends a chain block by block, starting at the genesis block.
+type Generator struct {
+	cfg    Config
+	random *rand.Rand
+	keys   []*walletKey
+	blocks []*wire.MsgBlock
+
+	// mature are the outputs the next block can spend
+	mature []utxo
+	// immature are the coinbase outputs not spendable yet, oldest first
+	immature []utxo
+}
+
+// New creates a generator holding the genesis block of the config network.
+func New(cfg Config) (*Generator, error) {
+	cfg, err := cfg.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Generator{
+		cfg:    cfg,
+		random: rand.New(rand.NewSource(cfg.Seed)),
+		blocks: []*wire.MsgBlock{cfg.Params.GenesisBlock},
+	}
+	for i := 0; i < keysPerAddressType*len(cfg.AddressTypes); i++ {
+		key, err := newWalletKey(g.random, cfg.AddressTypes[i%len(cfg.AddressTypes)], cfg.Params)
+		if err != nil {
+			return nil, err
+		}
+		g.keys = append(g.keys, key)
+	}
+	return g, nil
+}
+
+// Generate builds the blocks of the chain described by cfg, the genesis block
+// first.
+func Generate(cfg Config) ([]*wire.MsgBlock, error) {
+	g, err := generate(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return g.Blocks(), nil
+}
+
+// GenerateContent builds the chain described by cfg as DataContent, ready to
+// be served or written as a data file.
+func GenerateContent(cfg Config) (mockserver.DataContent, error) {
+	g, err := generate(cfg)
+	if err != nil {
+		return mockserver.DataContent{}, err
+	}
+	return mockserver.BlocksToContent(g.Blocks(), 0, g.Params())
+}
+
+func generate(cfg Config) (*Generator, error) {
+	g, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < g.cfg.Blocks; i++ {
+		if _, err := g.NextBlock(); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// Blocks returns the chain generated so far, the genesis block first.
+func (g *Generator) Blocks() []*wire.MsgBlock {
+	return g.blocks
+}
+
+// Params returns the network of the generated chain.
+func (g *Generator) Params() *chaincfg.Params {
+	return g.cfg.Params
+}
+
+// NextBlock generates the block on top of the current tip and appends it to
+// the chain.
+func (g *Generator) NextBlock() (*wire.MsgBlock, error) {
+	height := int32(len(g.blocks))
+	g.releaseMature(height)
+
+	txCount := g.cfg.MinTxsPerBlock + g.random.Intn(g.cfg.MaxTxsPerBlock-g.cfg.MinTxsPerBlock+1)
+	var txs []*wire.MsgTx
+	var created []utxo
+	var fees int64
+	// outputs too small to pay a fee are skipped, so this is a limit on
+	// attempts rather than transactions
+	for i := 0; i < txCount && len(g.mature) > 0; i++ {
+		tx, fee, err := g.newTransaction()
+		if err != nil {
+			return nil, err
+		}
+		if tx == nil {
+			continue
+		}
+		txs = append(txs, tx)
+		fees += fee
+		// outputs are spendable from the next block on
+		txHash := tx.TxHash()
+		for i, txOut := range tx.TxOut {
+			created = append(created, utxo{
+				outPoint: wire.OutPoint{Hash: txHash, Index: uint32(i)},
+				value:    txOut.Value,
+				key:      g.keyByScript(txOut.PkScript),
+				height:   height,
+			})
+		}
+	}
+
+	coinbaseKey := g.randomKey()
+	// the extra nonce 0 keeps the script at least two bytes long for small
+	// heights
+	value := blockchain.CalcBlockSubsidy(height, g.cfg.Params) + fees
+	coinbase, err := mockserver.NewCoinbase(height, 0, value, coinbaseKey.pkScript)
+	if err != nil {
+		return nil, err
+	}
+	block, err := g.newBlock(append([]*wire.MsgTx{coinbase}, txs...))
+	if err != nil {
+		return nil, err
+	}
+
+	g.blocks = append(g.blocks, block)
+	g.mature = append(g.mature, created...)
+	g.immature = append(g.immature, utxo{
+		outPoint: wire.OutPoint{Hash: coinbase.TxHash(), Index: 0},
+		value:    coinbase.TxOut[0].Value,
+		key:      coinbaseKey,
+		height:   height,
+	})
+	return block, nil
+}
+
+// releaseMature moves the coinbase outputs spendable at height to the mature
+// outputs.
+func (g *Generator) releaseMature(height int32) {
+	maturity := int32(g.cfg.Params.CoinbaseMaturity)
+	released := 0
+	for _, output := range g.immature {
+		if output.height+maturity > height {
+			break
+		}
+		g.mature = append(g.mature, output)
+		released++
+	}
+	g.immature = g.immature[released:]
+}
+
+func (g *Generator) randomKey() *walletKey {
+	return g.keys[g.random.Intn(len(g.keys))]
+}
+
+func (g *Generator) keyByScript(pkScript []byte) *walletKey {
+	for _, key := range g.keys {
+		if string(key.pkScript) == string(pkScript) {
+			return key
+		}
+	}
+	return nil
+}
+
+// feeRate draws a fee rate in sat/vB from the configured distribution.
+func (g *Generator) feeRate() int64 {
+	spread := g.cfg.MaxFeeRate - g.cfg.MinFeeRate
+	if spread == 0 {
+		return g.cfg.MinFeeRate
+	}
+	if g.cfg.FeeDistribution == FeeExponential {
+		// a mean of a quarter of the range keeps most rates low
+		offset := int64(math.Round(g.random.ExpFloat64() * float64(spread) / 4))
+		return g.cfg.MinFeeRate + min(offset, spread)
+	}
+	return g.cfg.MinFeeRate + g.random.Int63n(spread+1)
+}
+
+// newTransaction spends one or two mature outputs to a random wallet address,
+// with change to another one. It returns a nil transaction when the inputs
+// are too small to pay the fee; they are kept for later blocks.
+func (g *Generator) newTransaction() (*wire.MsgTx, int64, error) {
+	inputCount := 1
+	if len(g.mature) > 1 && g.random.Intn(4) == 0 {
+		inputCount = 2
+	}
+	inputs := make([]utxo, inputCount)
+	var total int64
+	for i := range inputs {
+		index := g.random.Intn(len(g.mature))
+		inputs[i] = g.mature[index]
+		g.mature[index] = g.mature[len(g.mature)-1]
+		g.mature = g.mature[:len(g.mature)-1]
+		total += inputs[i].value
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion + 1)
+	for _, input := range inputs {
+		tx.AddTxIn(wire.NewTxIn(&input.outPoint, nil, nil))
+	}
+	tx.AddTxOut(wire.NewTxOut(total/2, g.randomKey().pkScript))
+	tx.AddTxOut(wire.NewTxOut(total-total/2, g.randomKey().pkScript))
+
+	// sign once to learn the size, values don't change it
+	if err := signInputs(tx, inputs); err != nil {
+		return nil, 0, err
+	}
+	fee := g.feeRate() * virtualSize(tx)
+	spendable := total - fee
+	switch {
+	case spendable < dustLimit:
+		g.mature = append(g.mature, inputs...)
+		return nil, 0, nil
+	case spendable < 2*dustLimit:
+		tx.TxOut = tx.TxOut[:1]
+		tx.TxOut[0].Value = spendable
+	default:
+		amount := dustLimit + g.random.Int63n(spendable-2*dustLimit+1)
+		tx.TxOut[0].Value = amount
+		tx.TxOut[1].Value = spendable - amount
+	}
+
+	if err := signInputs(tx, inputs); err != nil {
+		return nil, 0, err
+	}
+	return tx, fee, nil
+}
+
+func signInputs(tx *wire.MsgTx, inputs []utxo) error {
+	prevOuts := make(map[wire.OutPoint]*wire.TxOut, len(inputs))
+	for _, input := range inputs {
+		prevOuts[input.outPoint] = wire.NewTxOut(input.value, input.key.pkScript)
+	}
+	sigHashes := txscript.NewTxSigHashes(tx, txscript.NewMultiPrevOutFetcher(prevOuts))
+
+	for i, input := range inputs {
+		if err := input.key.sign(tx, i, input.value, sigHashes); err != nil {
+			return fmt.Errorf("failed to sign %s input: %w", input.key.addressType, err)
+		}
+	}
+	return nil
+}
+
+func virtualSize(tx *wire.MsgTx) int64 {
+	weight := blockchain.GetTransactionWeight(btcutil.NewTx(tx))
+	return (weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor
+}
+
+// newBlock assembles a block on top of the tip, see mockserver.NewBlock.
+func (g *Generator) newBlock(txs []*wire.MsgTx) (*wire.MsgBlock, error) {
+	tip := g.blocks[len(g.blocks)-1]
+	return mockserver.NewBlock(tip.BlockHash(), tip.Header.Timestamp.Add(g.cfg.BlockInterval), g.cfg.Params.PowLimitBits, txs)
+}
@@ -0,0 +1,156 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gonative-cc/btc-mock-node/mockserver"
+)
+
+// testConfig generates enough blocks for coinbase outputs to mature and be
+// spent.
+var testConfig = Config{
+	Seed:           7,
+	Blocks:         130,
+	MinTxsPerBlock: 1,
+	MaxTxsPerBlock: 4,
+	MinFeeRate:     2,
+	MaxFeeRate:     40,
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	first, err := Generate(testConfig)
+	require.NoError(t, err)
+	second, err := Generate(testConfig)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	otherSeed := testConfig
+	otherSeed.Seed++
+	third, err := Generate(otherSeed)
+	require.NoError(t, err)
+	assert.NotEqual(t, first[len(first)-1].BlockHash(), third[len(third)-1].BlockHash())
+}
+
+func TestGenerateValidChain(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+	blocks, err := Generate(testConfig)
+	require.NoError(t, err)
+	require.Len(t, blocks, testConfig.Blocks+1)
+	assert.Equal(t, *params.GenesisHash, blocks[0].BlockHash())
+
+	utxos := make(map[wire.OutPoint]*wire.TxOut)
+	coinbaseHeights := make(map[wire.OutPoint]int32)
+	spends := 0
+	for height, block := range blocks[1:] {
+		height := int32(height + 1)
+		wrapped := btcutil.NewBlock(block)
+		assert.NoError(t, blockchain.CheckBlockSanity(wrapped, params.PowLimit, blockchain.NewMedianTime()))
+		assert.NoError(t, blockchain.ValidateWitnessCommitment(wrapped))
+		assert.Equal(t, blocks[height-1].BlockHash(), block.Header.PrevBlock)
+
+		coinbaseHeight, err := blockchain.ExtractCoinbaseHeight(wrapped.Transactions()[0])
+		assert.NoError(t, err)
+		assert.Equal(t, height, coinbaseHeight)
+
+		var fees int64
+		for _, tx := range block.Transactions[1:] {
+			prevOuts := make(map[wire.OutPoint]*wire.TxOut)
+			var inputValue int64
+			for _, txIn := range tx.TxIn {
+				prevOut, ok := utxos[txIn.PreviousOutPoint]
+				require.True(t, ok, "input spends an unknown or spent output")
+				if coinbaseHeight, ok := coinbaseHeights[txIn.PreviousOutPoint]; ok {
+					assert.GreaterOrEqual(t, height-coinbaseHeight, int32(params.CoinbaseMaturity))
+				}
+				prevOuts[txIn.PreviousOutPoint] = prevOut
+				inputValue += prevOut.Value
+				delete(utxos, txIn.PreviousOutPoint)
+			}
+
+			fetcher := txscript.NewMultiPrevOutFetcher(prevOuts)
+			sigHashes := txscript.NewTxSigHashes(tx, fetcher)
+			for i, txIn := range tx.TxIn {
+				prevOut := prevOuts[txIn.PreviousOutPoint]
+				engine, err := txscript.NewEngine(prevOut.PkScript, tx, i,
+					txscript.StandardVerifyFlags, nil, sigHashes, prevOut.Value, fetcher)
+				require.NoError(t, err)
+				assert.NoError(t, engine.Execute())
+				spends++
+			}
+
+			var outputValue int64
+			for _, txOut := range tx.TxOut {
+				outputValue += txOut.Value
+			}
+			fee := inputValue - outputValue
+			feeRate := float64(fee) / float64(virtualSize(tx))
+			assert.GreaterOrEqual(t, feeRate, float64(testConfig.MinFeeRate)*0.95)
+			assert.LessOrEqual(t, feeRate, float64(testConfig.MaxFeeRate)*1.05)
+			fees += fee
+		}
+		assert.Equal(t, blockchain.CalcBlockSubsidy(height, params)+fees, block.Transactions[0].TxOut[0].Value)
+
+		// outputs become spendable once the block is connected, coinbase
+		// outputs once mature
+		for txIndex, tx := range block.Transactions {
+			for i, txOut := range tx.TxOut {
+				if txscript.IsUnspendable(txOut.PkScript) {
+					continue
+				}
+				outPoint := wire.OutPoint{Hash: tx.TxHash(), Index: uint32(i)}
+				utxos[outPoint] = txOut
+				if txIndex == 0 {
+					coinbaseHeights[outPoint] = height
+				}
+			}
+		}
+	}
+	assert.Greater(t, spends, 0)
+}
+
+func TestGenerateContent(t *testing.T) {
+	cfg := testConfig
+	cfg.AddressTypes = []AddressType{P2TR}
+	cfg.FeeDistribution = FeeExponential
+	dataContent, err := GenerateContent(cfg)
+	require.NoError(t, err)
+	require.Len(t, dataContent.BlockHeaders, cfg.Blocks+1)
+
+	// the genesis coinbase pays to a bare public key
+	for _, transaction := range dataContent.Transactions[1:] {
+		for _, vout := range transaction.Vout {
+			if vout.ScriptPubKey.Type != "nulldata" {
+				assert.Equal(t, "witness_v1_taproot", vout.ScriptPubKey.Type)
+			}
+		}
+	}
+
+	// the content is served like a data file
+	dataStore := &mockserver.DataStore{}
+	require.NoError(t, dataStore.Replace(dataContent))
+	blockCount, err := (&mockserver.MockServerHandler{Store: dataStore}).GetBlockCount()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(cfg.Blocks), blockCount)
+}
+
+func TestConfigErrors(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"NegativeBlocks":      {Blocks: -1},
+		"TooManyTxs":          {MaxTxsPerBlock: maxTxsPerBlock + 1},
+		"UnknownDistribution": {FeeDistribution: "normal"},
+		"UnknownAddressType":  {AddressTypes: []AddressType{"p2wsh"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Generate(cfg)
+			assert.Error(t, err)
+		})
+	}
+}
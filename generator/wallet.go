@@ -0,0 +1,101 @@
+package generator
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// AddressType is the kind of output script the generated transactions pay to.
+type AddressType string
+
+const (
+	P2PKH      AddressType = "p2pkh"
+	P2SHP2WPKH AddressType = "p2sh-p2wpkh"
+	P2WPKH     AddressType = "p2wpkh"
+	P2TR       AddressType = "p2tr"
+)
+
+// AddressTypes lists every supported address type.
+var AddressTypes = []AddressType{P2PKH, P2SHP2WPKH, P2WPKH, P2TR}
+
+// walletKey is a key of the generator wallet, paying to one address type.
+type walletKey struct {
+	addressType AddressType
+	privKey     *btcec.PrivateKey
+	address     btcutil.Address
+	pkScript    []byte
+	// redeemScript is the witness program of a p2sh-p2wpkh key
+	redeemScript []byte
+}
+
+// newWalletKey derives a key from the random source, so the wallet is the
+// same for the same seed.
+func newWalletKey(random *rand.Rand, addressType AddressType, params *chaincfg.Params) (*walletKey, error) {
+	var seed [32]byte
+	random.Read(seed[:])
+	privKey, _ := btcec.PrivKeyFromBytes(seed[:])
+	pubKeyHash := btcutil.Hash160(privKey.PubKey().SerializeCompressed())
+
+	key := &walletKey{addressType: addressType, privKey: privKey}
+	var err error
+	switch addressType {
+	case P2PKH:
+		key.address, err = btcutil.NewAddressPubKeyHash(pubKeyHash, params)
+	case P2SHP2WPKH:
+		var witnessAddress btcutil.Address
+		witnessAddress, err = btcutil.NewAddressWitnessPubKeyHash(pubKeyHash, params)
+		if err != nil {
+			return nil, err
+		}
+		if key.redeemScript, err = txscript.PayToAddrScript(witnessAddress); err != nil {
+			return nil, err
+		}
+		key.address, err = btcutil.NewAddressScriptHash(key.redeemScript, params)
+	case P2WPKH:
+		key.address, err = btcutil.NewAddressWitnessPubKeyHash(pubKeyHash, params)
+	case P2TR:
+		// BIP86 key path only output, committing to no script
+		outputKey := txscript.ComputeTaprootKeyNoScript(privKey.PubKey())
+		key.address, err = btcutil.NewAddressTaproot(schnorr.SerializePubKey(outputKey), params)
+	default:
+		return nil, fmt.Errorf("unknown address type %q", addressType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key.pkScript, err = txscript.PayToAddrScript(key.address)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// sign sets the signature script and witness of input idx, which spends an
+// output of value paying to the key.
+func (k *walletKey) sign(tx *wire.MsgTx, idx int, value int64, sigHashes *txscript.TxSigHashes) error {
+	txIn := tx.TxIn[idx]
+	var err error
+	switch k.addressType {
+	case P2PKH:
+		txIn.SignatureScript, err = txscript.SignatureScript(tx, idx, k.pkScript, txscript.SigHashAll, k.privKey, true)
+	case P2SHP2WPKH:
+		txIn.Witness, err = txscript.WitnessSignature(tx, sigHashes, idx, value, k.redeemScript, txscript.SigHashAll, k.privKey, true)
+		if err != nil {
+			return err
+		}
+		txIn.SignatureScript, err = txscript.NewScriptBuilder().AddData(k.redeemScript).Script()
+	case P2WPKH:
+		txIn.Witness, err = txscript.WitnessSignature(tx, sigHashes, idx, value, k.pkScript, txscript.SigHashAll, k.privKey, true)
+	case P2TR:
+		txIn.Witness, err = txscript.TaprootWitnessSignature(tx, sigHashes, idx, value, k.pkScript, txscript.SigHashDefault, k.privKey)
+	}
+	return err
+}
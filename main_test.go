@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gonative-cc/btc-mock-node/mockserver"
+)
+
+// writeRegtestFixture writes a hex fixture of the regtest genesis block and a
+// child paying to a regtest address.
+func writeRegtestFixture(t *testing.T) string {
+	params := &chaincfg.RegressionNetParams
+	address, err := btcutil.NewAddressWitnessPubKeyHash(make([]byte, 20), params)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(address)
+	require.NoError(t, err)
+	coinbase, err := mockserver.NewCoinbase(1, 0, 50e8, pkScript)
+	require.NoError(t, err)
+	block, err := mockserver.NewBlock(*params.GenesisHash, time.Unix(1700000000, 0), params.PowLimitBits,
+		[]*wire.MsgTx{coinbase})
+	require.NoError(t, err)
+
+	var fixture bytes.Buffer
+	for _, block := range []*wire.MsgBlock{params.GenesisBlock, block} {
+		var raw bytes.Buffer
+		require.NoError(t, block.Serialize(&raw))
+		fixture.WriteString(hex.EncodeToString(raw.Bytes()) + "\n")
+	}
+	path := filepath.Join(t.TempDir(), "regtest.hex")
+	require.NoError(t, os.WriteFile(path, fixture.Bytes(), 0o600))
+	return path
+}
+
+// TestNetworkFixture loads a regtest hex fixture with -network regtest, into
+// the in-memory and the bolt store.
+func TestNetworkFixture(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+	path := writeRegtestFixture(t)
+
+	dataStore := &mockserver.DataStore{Params: params}
+	dataStore.ReadJsonFiles(path)
+	assert.NoError(t, checkNetwork(dataStore, params))
+	_, err := dataStore.Reload()
+	require.NoError(t, err)
+	assert.NoError(t, checkNetwork(dataStore, params))
+
+	boltStore, err := openBoltStore(filepath.Join(t.TempDir(), "chain.db"), []string{path}, "", params)
+	require.NoError(t, err)
+	defer boltStore.Close()
+	assert.NoError(t, checkNetwork(boltStore, params))
+
+	// without the network the addresses are decoded for mainnet
+	mainnetStore := &mockserver.DataStore{}
+	mainnetStore.ReadJsonFiles(path)
+	assert.ErrorContains(t, checkNetwork(mainnetStore, params), "is not a regtest address")
+}
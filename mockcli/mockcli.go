@@ -0,0 +1,384 @@
+// Package mockcli is a command-line client of the mock node following the
+// argument conventions of bitcoin-cli, so shell scripts written for
+// bitcoin-cli run against the mock without Bitcoin Core installed:
+//
+//	mockcli -regtest -rpcuser=user -rpcpassword=pass getblockhash 0
+//	mockcli -rpcconnect=127.0.0.1:8332 -named getblock blockhash=<hash> verbosity=2
+//
+// Like bitcoin-cli, the params listed as json by methodParams are parsed as
+// JSON and the others are sent as strings, string results are printed as is
+// and the other results as indented JSON.
+package mockcli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// param is a param of a served method.
+type param struct {
+	name string
+	// json params are parsed as JSON, the others are sent as strings
+	json bool
+}
+
+// methodParams lists the params of the served methods in order, like the
+// conversion table of bitcoin-cli. Methods missing here get all their
+// params as strings and can not be called with -named.
+var methodParams = map[string][]param{
+	"ping":               {{name: "in", json: true}},
+	"getbestblockhash":   {},
+	"getblock":           {{name: "blockhash"}, {name: "verbosity", json: true}},
+	"getblockcount":      {},
+	"getblockhash":       {{name: "height", json: true}},
+	"getblockheader":     {{name: "blockhash"}, {name: "verbose", json: true}},
+	"gettxout":           {{name: "txid"}, {name: "n", json: true}, {name: "include_mempool", json: true}},
+	"getrawtransaction":  {{name: "txid"}, {name: "verbose", json: true}, {name: "blockhash"}},
+	"getnetworkinfo":     {},
+	"getblockchaininfo":  {},
+	"getinfo":            {},
+	"setmocktime":        {{name: "timestamp", json: true}},
+	"sendrawtransaction": {{name: "hexstring"}, {name: "maxfeerate", json: true}},
+	"validateaddress":    {{name: "address"}},
+	"getaddressinfo":     {{name: "address"}},
+	"getdescriptorinfo":  {{name: "descriptor"}},
+	"deriveaddresses":    {{name: "descriptor"}, {name: "range", json: true}},
+
+	"mock_reload":      {},
+	"mock_dumpstate":   {{name: "path"}},
+	"mock_snapshot":    {},
+	"mock_restore":     {{name: "id", json: true}},
+	"mock_setfault":    {{name: "method"}, {name: "fault", json: true}},
+	"mock_clearfaults": {},
+	"mock_getfaults":   {},
+	"mock_advancetime": {{name: "seconds", json: true}},
+}
+
+// chainPorts are the default rpc ports of the networks, by -chain name.
+var chainPorts = map[string]int{
+	"main":     8332,
+	"test":     18332,
+	"testnet4": 48332,
+	"signet":   38332,
+	"regtest":  18443,
+}
+
+// Exit statuses of Run besides the codes of rpc errors, as in bitcoin-cli.
+const (
+	exitSuccess = 0
+	exitFailure = 1
+)
+
+type config struct {
+	chain          string
+	rpcConnect     string
+	rpcPort        int
+	rpcUser        string
+	rpcPassword    string
+	named          bool
+	stdin          bool
+	rpcWait        bool
+	rpcWaitTimeout time.Duration
+	clientTimeout  time.Duration
+}
+
+// Run runs the client with the command line args, without the program name,
+// and returns the exit status: 0 on success, the absolute rpc error code on
+// an rpc error and 1 on any other error. Extra args are read from stdin, one
+// per line, with -stdin.
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	cfg, args, err := parseFlags(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitSuccess
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitFailure
+	}
+
+	if cfg.stdin {
+		scanner := bufio.NewScanner(stdin)
+		for scanner.Scan() {
+			args = append(args, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			fmt.Fprintf(stderr, "error: failed to read stdin: %v\n", err)
+			return exitFailure
+		}
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "error: too few parameters (need at least command)")
+		return exitFailure
+	}
+
+	method := args[0]
+	var params []json.RawMessage
+	if cfg.named {
+		params, err = namedParams(method, args[1:])
+	} else {
+		params, err = positionalParams(method, args[1:])
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitFailure
+	}
+
+	response, err := call(cfg, method, params)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitFailure
+	}
+	if response.Error != nil {
+		fmt.Fprintf(stderr, "error code: %d\nerror message:\n%s\n", response.Error.Code, response.Error.Message)
+		if response.Error.Code == 0 {
+			return exitFailure
+		}
+		return min(abs(response.Error.Code), 255)
+	}
+	printResult(stdout, response.Result)
+	return exitSuccess
+}
+
+func parseFlags(args []string, output io.Writer) (config, []string, error) {
+	var cfg config
+	var chain string
+	var testnet, testnet4, regtest, signet bool
+	var rpcWaitTimeout, clientTimeout int
+
+	flags := flag.NewFlagSet("mockcli", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.Usage = func() {
+		fmt.Fprintln(output, "Usage: mockcli [options] <command> [params]")
+		fmt.Fprintln(output, "       mockcli [options] -named <command> [name=value]...")
+		fmt.Fprintln(output)
+		fmt.Fprintln(output, "Options:")
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&chain, "chain", "", "use the default rpc port of the chain: main, test, testnet4, signet or regtest")
+	flags.BoolVar(&testnet, "testnet", false, "use the default rpc port of testnet, same as -chain=test")
+	flags.BoolVar(&testnet4, "testnet4", false, "use the default rpc port of testnet4, same as -chain=testnet4")
+	flags.BoolVar(&regtest, "regtest", false, "use the default rpc port of regtest, same as -chain=regtest")
+	flags.BoolVar(&signet, "signet", false, "use the default rpc port of signet, same as -chain=signet")
+	flags.StringVar(&cfg.rpcConnect, "rpcconnect", "127.0.0.1", "send commands to the node at this host, optionally with a port")
+	flags.IntVar(&cfg.rpcPort, "rpcport", 0, "connect to the node on this port (default 8332, testnet 18332, testnet4 48332, signet 38332, regtest 18443)")
+	flags.StringVar(&cfg.rpcUser, "rpcuser", "", "username for json-rpc connections")
+	flags.StringVar(&cfg.rpcPassword, "rpcpassword", "", "password for json-rpc connections")
+	flags.BoolVar(&cfg.named, "named", false, "pass named instead of positional arguments")
+	flags.BoolVar(&cfg.stdin, "stdin", false, "read extra arguments from standard input, one per line")
+	flags.BoolVar(&cfg.rpcWait, "rpcwait", false, "wait for the rpc server to start")
+	flags.IntVar(&rpcWaitTimeout, "rpcwaittimeout", 0, "timeout in seconds to use with -rpcwait, 0 waits forever")
+	flags.IntVar(&clientTimeout, "rpcclienttimeout", 900, "timeout in seconds during http requests, 0 disables it")
+	if err := flags.Parse(args); err != nil {
+		return config{}, nil, err
+	}
+
+	cfg.chain = chain
+	selected := 0
+	for flagChain, set := range map[string]bool{"test": testnet, "testnet4": testnet4, "regtest": regtest, "signet": signet} {
+		if set {
+			cfg.chain = flagChain
+			selected++
+		}
+	}
+	if selected > 1 || (selected == 1 && chain != "") {
+		return config{}, nil, errors.New("invalid combination of -regtest, -signet, -testnet, -testnet4 and -chain, can use at most one")
+	}
+	if cfg.chain == "" {
+		cfg.chain = "main"
+	}
+	if _, ok := chainPorts[cfg.chain]; !ok {
+		return config{}, nil, fmt.Errorf("unknown chain %s", cfg.chain)
+	}
+	cfg.rpcWaitTimeout = time.Duration(rpcWaitTimeout) * time.Second
+	cfg.clientTimeout = time.Duration(clientTimeout) * time.Second
+	return cfg, flags.Args(), nil
+}
+
+// address returns the host:port of the node. -rpcport takes precedence over
+// a port in -rpcconnect, which takes precedence over the default port of the
+// chain.
+func (cfg config) address() string {
+	host, port := cfg.rpcConnect, strconv.Itoa(chainPorts[cfg.chain])
+	if connectHost, connectPort, err := net.SplitHostPort(cfg.rpcConnect); err == nil {
+		host, port = connectHost, connectPort
+	}
+	if cfg.rpcPort != 0 {
+		port = strconv.Itoa(cfg.rpcPort)
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// positionalParams converts the positional args of method to json params.
+func positionalParams(method string, args []string) ([]json.RawMessage, error) {
+	params := make([]json.RawMessage, len(args))
+	for i, arg := range args {
+		var p param
+		if i < len(methodParams[method]) {
+			p = methodParams[method][i]
+		}
+		value, err := paramValue(p, arg)
+		if err != nil {
+			return nil, err
+		}
+		params[i] = value
+	}
+	return params, nil
+}
+
+// namedParams converts the name=value args of method to json params in the
+// order of the method params. The mock only takes positional params, so the
+// skipped params are sent as null, the default of bitcoind.
+func namedParams(method string, args []string) ([]json.RawMessage, error) {
+	methodParamList, ok := methodParams[method]
+	if !ok {
+		return nil, fmt.Errorf("named params are not supported for %s", method)
+	}
+
+	var params []json.RawMessage
+	for _, arg := range args {
+		name, arg, ok := strings.Cut(arg, "=")
+		if !ok {
+			return nil, fmt.Errorf("no '=' in named argument '%s', this may be because a positional argument was given with -named", name)
+		}
+		index := -1
+		for i, p := range methodParamList {
+			if p.name == name {
+				index = i
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("unknown named parameter %s", name)
+		}
+		for len(params) <= index {
+			params = append(params, json.RawMessage("null"))
+		}
+		if string(params[index]) != "null" {
+			return nil, fmt.Errorf("parameter %s specified multiple times", name)
+		}
+		value, err := paramValue(methodParamList[index], arg)
+		if err != nil {
+			return nil, err
+		}
+		params[index] = value
+	}
+	return params, nil
+}
+
+func paramValue(p param, arg string) (json.RawMessage, error) {
+	if !p.json {
+		return json.Marshal(arg)
+	}
+	if !json.Valid([]byte(arg)) {
+		return nil, fmt.Errorf("error parsing JSON: %s", arg)
+	}
+	return json.RawMessage(arg), nil
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// call sends the request to the node, retrying the connection with -rpcwait.
+func call(cfg config, method string, params []json.RawMessage) (*rpcResponse, error) {
+	if params == nil {
+		params = []json.RawMessage{}
+	}
+	body, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	address := cfg.address()
+	var deadline time.Time
+	if cfg.rpcWaitTimeout > 0 {
+		deadline = time.Now().Add(cfg.rpcWaitTimeout)
+	}
+	httpClient := &http.Client{Timeout: cfg.clientTimeout}
+	for {
+		response, err := post(httpClient, cfg, "http://"+address+"/", body)
+		var netErr *net.OpError
+		if !errors.As(err, &netErr) {
+			return response, err
+		}
+		if !cfg.rpcWait || (!deadline.IsZero() && time.Now().After(deadline)) {
+			return nil, fmt.Errorf("could not connect to the server %s\n\nMake sure the mock node is running and -rpcconnect/-rpcport point to it", address)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func post(httpClient *http.Client, cfg config, url string, body []byte) (*rpcResponse, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.rpcUser != "" || cfg.rpcPassword != "" {
+		req.SetBasicAuth(cfg.rpcUser, cfg.rpcPassword)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, errors.New("authorization failed: incorrect rpcuser or rpcpassword")
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var response rpcResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("server response is not json-rpc (http status %d): %s",
+			resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return &response, nil
+}
+
+// printResult prints a result like bitcoin-cli: nothing for null, strings
+// unquoted and anything else as indented JSON.
+func printResult(stdout io.Writer, result json.RawMessage) {
+	if len(result) == 0 || string(result) == "null" {
+		return
+	}
+	var text string
+	if json.Unmarshal(result, &text) == nil {
+		fmt.Fprintln(stdout, text)
+		return
+	}
+	var indented bytes.Buffer
+	if json.Indent(&indented, result, "", "  ") != nil {
+		fmt.Fprintln(stdout, string(result))
+		return
+	}
+	fmt.Fprintln(stdout, indented.String())
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
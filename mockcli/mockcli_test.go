@@ -0,0 +1,128 @@
+package mockcli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gonative-cc/btc-mock-node/mocknode"
+	"github.com/gonative-cc/btc-mock-node/mockserver"
+)
+
+// TestMethodParamsMatchHandler fails when a method is added to the handler
+// without its params in methodParams.
+func TestMethodParamsMatchHandler(t *testing.T) {
+	handlerType := reflect.TypeOf(&mockserver.MockServerHandler{})
+	for i := 0; i < handlerType.NumMethod(); i++ {
+		method := handlerType.Method(i)
+		name := strings.ToLower(method.Name)
+		if rest, ok := strings.CutPrefix(method.Name, "Mock"); ok {
+			name = "mock_" + strings.ToLower(rest)
+		}
+		params, ok := methodParams[name]
+		if assert.True(t, ok, "%s is missing from methodParams", name) {
+			// the receiver is the first param
+			assert.Len(t, params, method.Type.NumIn()-1, name)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	node := mocknode.Start(t, mocknode.WithDataFiles("../data/mainnet_oldest_blocks.json"))
+	const genesisHash = "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+
+	run := func(stdin string, args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		args = append([]string{"-rpcconnect=" + node.Host, "-rpcuser=" + node.User, "-rpcpassword=" + node.Password}, args...)
+		status := Run(args, strings.NewReader(stdin), &stdout, &stderr)
+		return status, stdout.String(), stderr.String()
+	}
+
+	t.Run("Positional", func(t *testing.T) {
+		status, stdout, stderr := run("", "getblockcount")
+		assert.Equal(t, 0, status, stderr)
+		assert.Equal(t, "10\n", stdout)
+
+		status, stdout, stderr = run("", "getblockhash", "0")
+		assert.Equal(t, 0, status, stderr)
+		assert.Equal(t, genesisHash+"\n", stdout)
+
+		status, stdout, stderr = run("", "getblock", genesisHash, "1")
+		assert.Equal(t, 0, status, stderr)
+		var block map[string]any
+		require.NoError(t, json.Unmarshal([]byte(stdout), &block))
+		assert.Equal(t, genesisHash, block["hash"])
+		assert.Contains(t, stdout, "\n  \"hash\": ")
+	})
+
+	t.Run("Named", func(t *testing.T) {
+		status, stdout, stderr := run("", "-named", "getrawtransaction",
+			"txid=0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098", "verbose=true")
+		assert.Equal(t, 0, status, stderr)
+		assert.Contains(t, stdout, `"blockhash": "00000000839a8e6886ab5951d76f411475428afc90947ee320161bbf18eb6048"`)
+
+		status, _, stderr = run("", "-named", "getblockhash", "depth=0")
+		assert.Equal(t, 1, status)
+		assert.Equal(t, "error: unknown named parameter depth\n", stderr)
+	})
+
+	t.Run("Stdin", func(t *testing.T) {
+		status, stdout, stderr := run("0\n", "-stdin", "getblockhash")
+		assert.Equal(t, 0, status, stderr)
+		assert.Equal(t, genesisHash+"\n", stdout)
+	})
+
+	t.Run("RPCError", func(t *testing.T) {
+		status, stdout, stderr := run("", "getblockhash", "100")
+		assert.Equal(t, 1, status)
+		assert.Empty(t, stdout)
+		assert.Equal(t, "error code: -1\nerror message:\nBlock number out of range\n", stderr)
+
+		status, _, stderr = run("", "getblockheader", "00000000839a8e6886ab5951d76f411475428afc90947ee320161bbf18eb6049")
+		assert.Equal(t, 5, status)
+		assert.Contains(t, stderr, "error code: -5\n")
+
+		status, _, stderr = run("", "getblockhash", "zero")
+		assert.Equal(t, 1, status)
+		assert.Equal(t, "error: error parsing JSON: zero\n", stderr)
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		status, _, stderr := run("", "-rpcpassword=wrong", "getblockcount")
+		assert.Equal(t, 1, status)
+		assert.Contains(t, stderr, "authorization failed")
+	})
+
+	t.Run("NotRunning", func(t *testing.T) {
+		status := Run([]string{"-rpcconnect=127.0.0.1", "-rpcport=1", "getblockcount"}, nil, io.Discard, io.Discard)
+		assert.Equal(t, 1, status)
+	})
+}
+
+func TestAddress(t *testing.T) {
+	for _, test := range []struct {
+		args    []string
+		address string
+	}{
+		{nil, "127.0.0.1:8332"},
+		{[]string{"-regtest"}, "127.0.0.1:18443"},
+		{[]string{"-chain=signet"}, "127.0.0.1:38332"},
+		{[]string{"-testnet", "-rpcconnect=node.local"}, "node.local:18332"},
+		{[]string{"-testnet4"}, "127.0.0.1:48332"},
+		{[]string{"-rpcconnect=node.local:1234"}, "node.local:1234"},
+		{[]string{"-rpcconnect=node.local:1234", "-rpcport=4321"}, "node.local:4321"},
+	} {
+		cfg, _, err := parseFlags(test.args, io.Discard)
+		require.NoError(t, err)
+		assert.Equal(t, test.address, cfg.address(), test.args)
+	}
+
+	_, _, err := parseFlags([]string{"-regtest", "-chain=main"}, io.Discard)
+	assert.ErrorContains(t, err, "can use at most one")
+}
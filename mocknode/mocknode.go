@@ -0,0 +1,226 @@
+// Package mocknode runs a mock bitcoin node inside Go tests:
+//
+//	node := mocknode.Start(t, mocknode.WithDataFiles("testdata/chain.json"))
+//	blockCount, err := node.Client.GetBlockCount(ctx)
+//	node.Mine(6)
+//
+// The node is stopped by t.Cleanup.
+package mocknode
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/gonative-cc/btc-mock-node/client"
+	"github.com/gonative-cc/btc-mock-node/mockserver"
+)
+
+// Node is a running mock node.
+type Node struct {
+	// URL is the url of the json-rpc server, e.g. http://127.0.0.1:41234.
+	URL string
+	// Host is the host:port of the server, as used by rpcclient.ConnConfig.
+	Host string
+	// User and Password are the credentials the server requires.
+	User     string
+	Password string
+	// Client is a client of the node, authenticated with its credentials.
+	Client *client.Client
+	// Handler serves the RPCs, its Store holds the chain and its Clock is the
+	// time of the node.
+	Handler *mockserver.MockServerHandler
+
+	t     testing.TB
+	miner *mockserver.Miner
+}
+
+type options struct {
+	fixtures []fixture
+	user     string
+	password string
+}
+
+// fixture is one of the sources of the chain data, in order.
+type fixture struct {
+	path    string
+	data    []byte
+	content *mockserver.DataContent
+}
+
+// Option configures Start.
+type Option func(*options)
+
+// WithDataFiles loads data files, directories or glob patterns, see
+// mockserver.LoadDataFiles.
+func WithDataFiles(patterns ...string) Option {
+	return func(o *options) {
+		for _, pattern := range patterns {
+			o.fixtures = append(o.fixtures, fixture{path: pattern})
+		}
+	}
+}
+
+// WithData loads the content of a data file: a DataContent json object or a
+// raw block fixture.
+func WithData(data []byte) Option {
+	return func(o *options) {
+		o.fixtures = append(o.fixtures, fixture{data: data})
+	}
+}
+
+// WithContent loads chain data built in Go.
+func WithContent(content mockserver.DataContent) Option {
+	return func(o *options) {
+		o.fixtures = append(o.fixtures, fixture{content: &content})
+	}
+}
+
+// WithCredentials sets the rpcuser and rpcpassword of the node, mocknode and
+// mocknode by default.
+func WithCredentials(user, password string) Option {
+	return func(o *options) {
+		o.user, o.password = user, password
+	}
+}
+
+// Start runs a mock node serving the fixtures, merged in the order of the
+// options like data files. Without fixtures the chain is a fresh regtest
+// chain holding only its genesis block.
+func Start(t testing.TB, opts ...Option) *Node {
+	t.Helper()
+
+	o := options{user: "mocknode", password: "mocknode"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	content, err := loadFixtures(t.TempDir(), o.fixtures)
+	if err != nil {
+		t.Fatalf("mocknode: failed to load fixtures: %v", err)
+	}
+	dataStore := &mockserver.DataStore{}
+	if err := dataStore.Replace(content); err != nil {
+		t.Fatalf("mocknode: failed to store fixtures: %v", err)
+	}
+
+	clock := &mockserver.Clock{}
+	handler := &mockserver.MockServerHandler{Store: dataStore, Faults: mockserver.NewFaultInjector(), Clock: clock}
+	server := httptest.NewServer(basicAuth(o.user, o.password, mockserver.NewHTTPHandler(handler)))
+	t.Cleanup(server.Close)
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("mocknode: %v", err)
+	}
+	c, closer, err := client.New(context.Background(), server.URL, client.WithBasicAuth(o.user, o.password))
+	if err != nil {
+		t.Fatalf("mocknode: failed to create client: %v", err)
+	}
+	t.Cleanup(closer)
+
+	return &Node{
+		URL:      server.URL,
+		Host:     serverURL.Host,
+		User:     o.user,
+		Password: o.password,
+		Client:   c,
+		Handler:  handler,
+		t:        t,
+		miner:    &mockserver.Miner{Store: dataStore, Clock: clock},
+	}
+}
+
+func loadFixtures(dir string, fixtures []fixture) (mockserver.DataContent, error) {
+	if len(fixtures) == 0 {
+		params := &chaincfg.RegressionNetParams
+		return mockserver.BlocksToContent([]*wire.MsgBlock{params.GenesisBlock}, 0, params)
+	}
+
+	var paths []string
+	for i, fixture := range fixtures {
+		path := fixture.path
+		switch {
+		case fixture.data != nil:
+			path = filepath.Join(dir, fmt.Sprintf("fixture-%d.json", i))
+			if err := os.WriteFile(path, fixture.data, 0o600); err != nil {
+				return mockserver.DataContent{}, err
+			}
+		case fixture.content != nil:
+			path = filepath.Join(dir, fmt.Sprintf("fixture-%d.json", i))
+			if err := mockserver.WriteDataFile(path, *fixture.content); err != nil {
+				return mockserver.DataContent{}, err
+			}
+		}
+		paths = append(paths, path)
+	}
+	return mockserver.LoadDataFiles(paths...)
+}
+
+// basicAuth rejects the requests without the credentials, like bitcoind.
+func basicAuth(user, password string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if reqUser, reqPassword, ok := req.BasicAuth(); !ok || reqUser != user || reqPassword != password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="jsonrpc"`)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// Mine appends count blocks to the chain and returns their hashes. The first
+// block confirms the transactions submitted since the last block.
+func (n *Node) Mine(count int) []chainhash.Hash {
+	n.t.Helper()
+	hashes, err := n.miner.Mine(count)
+	if err != nil {
+		n.t.Fatalf("mocknode: failed to mine %d blocks: %v", count, err)
+	}
+	return hashes
+}
+
+// SubmitTx adds a raw transaction in hex to the mempool and returns its txid.
+// Only its timelocks and the maturity of the coinbases it spends are checked,
+// see mockserver.Miner.SubmitTransaction.
+func (n *Node) SubmitTx(txHex string) chainhash.Hash {
+	n.t.Helper()
+	txid, err := n.miner.SubmitTransaction(txHex)
+	if err != nil {
+		n.t.Fatalf("mocknode: failed to submit transaction: %v", err)
+	}
+	return *txid
+}
+
+// SubmitMsgTx is SubmitTx for a transaction built in Go.
+func (n *Node) SubmitMsgTx(tx *wire.MsgTx) chainhash.Hash {
+	n.t.Helper()
+	var raw bytes.Buffer
+	if err := tx.Serialize(&raw); err != nil {
+		n.t.Fatalf("mocknode: failed to serialize transaction: %v", err)
+	}
+	return n.SubmitTx(hex.EncodeToString(raw.Bytes()))
+}
+
+// Reorg replaces the last depth blocks with depth+1 new blocks and returns
+// their hashes. The replaced blocks stay as stale blocks, their transactions
+// are confirmed again by the first new block.
+func (n *Node) Reorg(depth int) []chainhash.Hash {
+	n.t.Helper()
+	hashes, err := n.miner.Reorg(depth)
+	if err != nil {
+		n.t.Fatalf("mocknode: failed to reorg %d blocks: %v", depth, err)
+	}
+	return hashes
+}
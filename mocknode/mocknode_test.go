@@ -0,0 +1,168 @@
+package mocknode
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStart(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("DataFiles", func(t *testing.T) {
+		node := Start(t, WithDataFiles("../data/mainnet_oldest_blocks.json"))
+		blockCount, err := node.Client.GetBlockCount(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int32(10), blockCount)
+	})
+
+	t.Run("Data", func(t *testing.T) {
+		data, err := os.ReadFile("../data/mainnet_oldest_blocks.json")
+		require.NoError(t, err)
+		node := Start(t, WithData(data))
+		blockCount, err := node.Client.GetBlockCount(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int32(10), blockCount)
+	})
+
+	t.Run("Regtest", func(t *testing.T) {
+		node := Start(t)
+		blockChainInfo, err := node.Client.GetBlockChainInfo(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "regtest", blockChainInfo.Chain)
+		assert.Equal(t, int32(0), blockChainInfo.Blocks)
+		assert.Equal(t, chaincfg.RegressionNetParams.GenesisHash.String(), blockChainInfo.BestBlockHash)
+	})
+
+	t.Run("Credentials", func(t *testing.T) {
+		node := Start(t, WithCredentials("alice", "secret"))
+
+		resp, err := http.Post(node.URL, "application/json",
+			strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "getblockcount", "params": []}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		rpcClient, err := rpcclient.New(&rpcclient.ConnConfig{
+			Host:         node.Host,
+			User:         node.User,
+			Pass:         node.Password,
+			HTTPPostMode: true,
+			DisableTLS:   true,
+		}, nil)
+		require.NoError(t, err)
+		defer rpcClient.Shutdown()
+		blockCount, err := rpcClient.GetBlockCount()
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), blockCount)
+	})
+}
+
+func TestMineSubmitReorg(t *testing.T) {
+	ctx := context.Background()
+	node := Start(t)
+	params := &chaincfg.RegressionNetParams
+
+	// mined blocks extend the chain with a valid proof of work
+	hashes := node.Mine(101)
+	require.Len(t, hashes, 101)
+	blockCount, err := node.Client.GetBlockCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int32(101), blockCount)
+
+	genesis, err := node.Client.GetBlockHeader(ctx, params.GenesisHash, true)
+	require.NoError(t, err)
+	assert.Equal(t, hashes[0].String(), genesis.NextHash)
+	assert.Equal(t, int64(102), genesis.Confirmations)
+
+	tipHeader, err := node.Client.GetBlockHeader(ctx, &hashes[100], true)
+	require.NoError(t, err)
+	assert.Equal(t, hashes[99].String(), tipHeader.PreviousHash)
+	assert.Equal(t, int64(1), tipHeader.Confirmations)
+	bits, err := strconv.ParseUint(tipHeader.Bits, 16, 32)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, blockchain.HashToBig(&hashes[100]).Cmp(blockchain.CompactToBig(uint32(bits))), 0)
+
+	// the coinbases pay to OP_TRUE, so anyone can spend them
+	verbosity := 2
+	firstBlock, err := node.Client.GetBlock(ctx, &hashes[0], &verbosity)
+	require.NoError(t, err)
+	require.Len(t, firstBlock.RawTx, 1)
+	coinbase := decodeTx(t, firstBlock.RawTx[0].Hex)
+	const fee = 1000
+	tx := wire.NewMsgTx(wire.TxVersion)
+	coinbaseHash := coinbase.TxHash()
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&coinbaseHash, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(coinbase.TxOut[0].Value-fee, []byte{txscript.OP_TRUE}))
+
+	txid := node.SubmitMsgTx(tx)
+	transaction, err := node.Client.GetRawTransaction(ctx, &txid, true, nil)
+	require.NoError(t, err)
+	assert.Empty(t, transaction.BlockHash)
+
+	minedHashes := node.Mine(1)
+	transaction, err = node.Client.GetRawTransaction(ctx, &txid, true, nil)
+	require.NoError(t, err)
+	assert.Equal(t, minedHashes[0].String(), transaction.BlockHash)
+	assert.Equal(t, uint64(1), transaction.Confirmations)
+
+	// the coinbase collects the fee
+	minedBlock, err := node.Client.GetBlock(ctx, &minedHashes[0], &verbosity)
+	require.NoError(t, err)
+	require.Len(t, minedBlock.RawTx, 2)
+	minedCoinbase := decodeTx(t, minedBlock.RawTx[0].Hex)
+	assert.Equal(t, blockchain.CalcBlockSubsidy(102, params)+fee, minedCoinbase.TxOut[0].Value)
+
+	// verbosity 1 lists the txids
+	verbosity = 1
+	minedBlock, err = node.Client.GetBlock(ctx, &minedHashes[0], &verbosity)
+	require.NoError(t, err)
+	assert.Equal(t, []string{minedCoinbase.TxHash().String(), txid.String()}, minedBlock.Tx)
+
+	// a reorg replaces the blocks and confirms the transaction again
+	reorgHashes := node.Reorg(2)
+	require.Len(t, reorgHashes, 3)
+	blockCount, err = node.Client.GetBlockCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int32(103), blockCount)
+
+	// the replaced blocks are stale, like in bitcoind
+	for _, staleHash := range []chainhash.Hash{hashes[100], minedHashes[0]} {
+		staleHeader, err := node.Client.GetBlockHeader(ctx, &staleHash, true)
+		require.NoError(t, err)
+		assert.Equal(t, int64(-1), staleHeader.Confirmations)
+		assert.Empty(t, staleHeader.NextHash)
+	}
+	forkHeader, err := node.Client.GetBlockHeader(ctx, &hashes[99], true)
+	require.NoError(t, err)
+	assert.Equal(t, reorgHashes[0].String(), forkHeader.NextHash)
+	assert.Equal(t, int64(4), forkHeader.Confirmations)
+
+	transaction, err = node.Client.GetRawTransaction(ctx, &txid, true, nil)
+	require.NoError(t, err)
+	assert.Equal(t, reorgHashes[0].String(), transaction.BlockHash)
+	assert.Equal(t, uint64(3), transaction.Confirmations)
+}
+
+func decodeTx(t *testing.T, txHex string) *wire.MsgTx {
+	t.Helper()
+	raw, err := hex.DecodeString(txHex)
+	require.NoError(t, err)
+	tx := &wire.MsgTx{}
+	require.NoError(t, tx.Deserialize(bytes.NewReader(raw)))
+	return tx
+}
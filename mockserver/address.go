@@ -0,0 +1,270 @@
+package mockserver
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+
+	"github.com/gonative-cc/btc-mock-node/client"
+)
+
+// maxDeriveAddresses is the most addresses deriveaddresses derives at once,
+// like in bitcoind.
+const maxDeriveAddresses = 1000000
+
+func invalidAddressOrKey(format string, args ...any) *RPCError {
+	return &RPCError{
+		Code:    btcjson.ErrRPCInvalidAddressOrKey,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// networkParams returns the network of the node, see MockServerHandler.Params.
+func (h *MockServerHandler) networkParams() (*chaincfg.Params, error) {
+	if h.Params != nil {
+		return h.Params, nil
+	}
+	view, err := h.view()
+	if err != nil {
+		return nil, err
+	}
+	defer view.Release()
+	return h.params(view), nil
+}
+
+// decodeAddress decodes an address of the network of params, with the error
+// messages of bitcoind.
+func decodeAddress(address string, params *chaincfg.Params) (btcutil.Address, error) {
+	decoded, err := btcutil.DecodeAddress(address, params)
+	// btcutil also takes hex public keys, which have no address in bitcoind
+	if _, isPubKey := decoded.(*btcutil.AddressPubKey); err == nil && !isPubKey && decoded.IsForNet(params) {
+		return decoded, nil
+	}
+
+	// a segwit address of another network
+	if separator := strings.LastIndexByte(address, '1'); separator > 0 {
+		hrp := strings.ToLower(address[:separator])
+		if hrp != params.Bech32HRPSegwit && chaincfg.IsBech32SegwitPrefix(hrp+"1") {
+			return nil, invalidAddressOrKey("Invalid or unsupported prefix for Segwit (Bech32) address (expected %s, got %s).",
+				params.Bech32HRPSegwit, hrp)
+		}
+	}
+	if _, _, err := base58.CheckDecode(address); err == nil {
+		return nil, invalidAddressOrKey("Invalid or unsupported Base58-encoded address.")
+	}
+	return nil, invalidAddressOrKey("Invalid or unsupported Segwit (Bech32) or Base58 encoding.")
+}
+
+// addressDescription is what validateaddress and getaddressinfo tell about
+// any address.
+type addressDescription struct {
+	scriptPubKey   string
+	isScript       bool
+	isWitness      bool
+	witnessVersion int
+	witnessProgram string
+}
+
+// describeAddress describes an address like bitcoind: taproot outputs count
+// as scripts, as they can commit to some.
+func describeAddress(address btcutil.Address) (addressDescription, error) {
+	script, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		return addressDescription{}, err
+	}
+	description := addressDescription{scriptPubKey: hex.EncodeToString(script)}
+	switch address := address.(type) {
+	case *btcutil.AddressScriptHash:
+		description.isScript = true
+	case *btcutil.AddressWitnessPubKeyHash:
+		description.isWitness = true
+		description.witnessProgram = hex.EncodeToString(address.WitnessProgram())
+	case *btcutil.AddressWitnessScriptHash:
+		description.isScript, description.isWitness = true, true
+		description.witnessProgram = hex.EncodeToString(address.WitnessProgram())
+	case *btcutil.AddressTaproot:
+		description.isScript, description.isWitness = true, true
+		description.witnessVersion = int(address.WitnessVersion())
+		description.witnessProgram = hex.EncodeToString(address.WitnessProgram())
+	}
+	return description, nil
+}
+
+// ValidateAddress returns whether an address is valid on the network of the
+// node and, if it is, its output script, like bitcoind's validateaddress.
+func (h *MockServerHandler) ValidateAddress(address string) (*client.ValidateAddressResult, error) {
+	params, err := h.networkParams()
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := decodeAddress(address, params)
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return &client.ValidateAddressResult{Error: rpcErr.Message}, nil
+	}
+	description, err := describeAddress(decoded)
+	if err != nil {
+		return &client.ValidateAddressResult{Error: err.Error()}, nil
+	}
+
+	result := &client.ValidateAddressResult{
+		IsValid:      true,
+		Address:      decoded.EncodeAddress(),
+		ScriptPubKey: description.scriptPubKey,
+		IsScript:     &description.isScript,
+		IsWitness:    &description.isWitness,
+	}
+	if description.isWitness {
+		result.WitnessVersion = &description.witnessVersion
+		result.WitnessProgram = &description.witnessProgram
+	}
+	return result, nil
+}
+
+// GetAddressInfo describes an address of the network of the node like
+// bitcoind's getaddressinfo. The mock has no wallet, so no address is ever
+// owned or solvable.
+func (h *MockServerHandler) GetAddressInfo(address string) (*btcjson.GetAddressInfoResult, error) {
+	params, err := h.networkParams()
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := decodeAddress(address, params)
+	if err != nil {
+		return nil, err
+	}
+	description, err := describeAddress(decoded)
+	if err != nil {
+		return nil, invalidAddressOrKey("%v", err)
+	}
+
+	result := &btcjson.GetAddressInfoResult{}
+	result.Address = decoded.EncodeAddress()
+	result.ScriptPubKey = description.scriptPubKey
+	result.IsScript = description.isScript
+	result.IsWitness = description.isWitness
+	if description.isWitness {
+		result.WitnessVersion = description.witnessVersion
+		result.WitnessProgram = &description.witnessProgram
+	}
+	result.Labels = []string{}
+	return result, nil
+}
+
+// GetDescriptorInfo analyses a descriptor, see descriptor for the supported
+// ones. The descriptor is returned without private keys, with its checksum.
+func (h *MockServerHandler) GetDescriptorInfo(descriptor string) (*btcjson.GetDescriptorInfoResult, error) {
+	params, err := h.networkParams()
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := splitDescriptorChecksum(descriptor, false)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := parseDescriptor(payload, params)
+	if err != nil {
+		return nil, err
+	}
+
+	canonical := parsed.String()
+	canonicalChecksum, _ := descriptorChecksum(canonical)
+	checksum, _ := descriptorChecksum(payload)
+	return &btcjson.GetDescriptorInfoResult{
+		Descriptor:     canonical + "#" + canonicalChecksum,
+		Checksum:       checksum,
+		IsRange:        parsed.isRange(),
+		IsSolvable:     parsed.key != nil,
+		HasPrivateKeys: parsed.key != nil && parsed.key.private,
+	}, nil
+}
+
+// DeriveAddresses returns the addresses of a descriptor with its checksum,
+// over derivationRange for a ranged descriptor: the end, or a [begin, end]
+// array, of the indexes to derive, both included.
+func (h *MockServerHandler) DeriveAddresses(descriptor string, derivationRange json.RawMessage) ([]string, error) {
+	params, err := h.networkParams()
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := splitDescriptorChecksum(descriptor, true)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := parseDescriptor(payload, params)
+	if err != nil {
+		return nil, err
+	}
+
+	hasRange := len(derivationRange) > 0 && string(derivationRange) != "null"
+	begin, end := int64(0), int64(0)
+	switch {
+	case parsed.isRange() && !hasRange:
+		return nil, invalidParameter("Range must be specified for a ranged descriptor")
+	case !parsed.isRange() && hasRange:
+		return nil, invalidParameter("Range should not be specified for an un-ranged descriptor")
+	case hasRange:
+		if begin, end, err = parseDerivationRange(derivationRange); err != nil {
+			return nil, err
+		}
+	}
+
+	addresses := make([]string, 0, end-begin+1)
+	for index := begin; index <= end; index++ {
+		address, err := parsed.addressAt(uint32(index), params)
+		var rpcErr *RPCError
+		if err != nil && !errors.As(err, &rpcErr) {
+			err = invalidAddressOrKey("%v", err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address.EncodeAddress())
+	}
+	return addresses, nil
+}
+
+func invalidParameter(message string) *RPCError {
+	return &RPCError{
+		Code:    btcjson.ErrRPCInvalidParameter,
+		Message: message,
+	}
+}
+
+// parseDerivationRange parses the end, or a [begin, end] array, of a range of
+// indexes, like bitcoind.
+func parseDerivationRange(derivationRange json.RawMessage) (int64, int64, error) {
+	var begin, end int64
+	if err := json.Unmarshal(derivationRange, &end); err != nil {
+		var bounds []int64
+		if err := json.Unmarshal(derivationRange, &bounds); err != nil || len(bounds) != 2 {
+			return 0, 0, invalidParameter("Range must be specified as end or as [begin,end]")
+		}
+		begin, end = bounds[0], bounds[1]
+	}
+
+	switch {
+	case begin < 0 || end < 0:
+		return 0, 0, invalidParameter("Range should be greater or equal than 0")
+	case begin > end:
+		return 0, 0, invalidParameter("Range specified as [begin,end] must not have begin after end")
+	case end > math.MaxInt32:
+		return 0, 0, invalidParameter("End of range is too high")
+	case end-begin >= maxDeriveAddresses:
+		return 0, 0, invalidParameter("Range is too large")
+	}
+	return begin, end, nil
+}
@@ -0,0 +1,175 @@
+package mockserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gonative-cc/btc-mock-node/client"
+)
+
+func TestAddressRPCs(t *testing.T) {
+	ctx := context.Background()
+	// the network is the one of the mainnet fixture
+	mockService := NewMockRPCServer("../data/mainnet_oldest_blocks.json")
+	defer mockService.Close()
+	client_handler := newTestClient(t, mockService.URL)
+
+	t.Run("ValidateAddress", func(t *testing.T) {
+		isScript, isWitness, witnessVersion, witnessProgram := false, true, 0, "751e76e8199196d454941c45d1b3a323f1433bd6"
+		result, err := client_handler.ValidateAddress(ctx, "BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4")
+		require.NoError(t, err)
+		assert.Equal(t, &client.ValidateAddressResult{
+			IsValid:        true,
+			Address:        "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+			ScriptPubKey:   "0014751e76e8199196d454941c45d1b3a323f1433bd6",
+			IsScript:       &isScript,
+			IsWitness:      &isWitness,
+			WitnessVersion: &witnessVersion,
+			WitnessProgram: &witnessProgram,
+		}, result)
+
+		result, err = client_handler.ValidateAddress(ctx, "3JvL6Ymt8MVWiCNHC7oWU6nLeHNJKLZGLN")
+		require.NoError(t, err)
+		assert.True(t, result.IsValid)
+		assert.True(t, *result.IsScript)
+		assert.False(t, *result.IsWitness)
+		assert.Nil(t, result.WitnessVersion)
+
+		result, err = client_handler.ValidateAddress(ctx, "bc1p2wsldez5mud2yam29q22wgfh9439spgduvct83k3pm50fcxa5dps59h4z5")
+		require.NoError(t, err)
+		assert.True(t, *result.IsScript)
+		assert.Equal(t, 1, *result.WitnessVersion)
+		assert.Equal(t, "512053a1f6e454df1aa2776a2814a721372d6258050de330b3c6d10ee8f4e0dda343", result.ScriptPubKey)
+
+		for address, expected := range map[string]string{
+			"tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx": "Invalid or unsupported prefix for Segwit (Bech32) address (expected bc, got tb).",
+			"mrCDrCybB6J1vRfbwM5hemdJz73FwDBC8r":         "Invalid or unsupported Base58-encoded address.",
+			testPubKey:                                   "Invalid or unsupported Segwit (Bech32) or Base58 encoding.",
+			"not an address":                             "Invalid or unsupported Segwit (Bech32) or Base58 encoding.",
+		} {
+			result, err := client_handler.ValidateAddress(ctx, address)
+			require.NoError(t, err)
+			assert.Equal(t, &client.ValidateAddressResult{Error: expected}, result, address)
+		}
+	})
+
+	t.Run("GetAddressInfo", func(t *testing.T) {
+		addressInfo, err := client_handler.GetAddressInfo(ctx, "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH")
+		require.NoError(t, err)
+		assert.Equal(t, "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac", addressInfo.ScriptPubKey)
+		assert.False(t, addressInfo.IsMine)
+		assert.False(t, addressInfo.IsWitness)
+		assert.Empty(t, addressInfo.Labels)
+
+		_, err = client_handler.GetAddressInfo(ctx, "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx")
+		assert.ErrorContains(t, err, "Invalid or unsupported prefix for Segwit (Bech32) address")
+	})
+
+	t.Run("GetDescriptorInfo", func(t *testing.T) {
+		descriptorInfo, err := client_handler.GetDescriptorInfo(ctx, "wpkh([d34db33f/84h/0h/0h]"+testXprv+"/0/*)")
+		require.NoError(t, err)
+		canonical := "wpkh([d34db33f/84h/0h/0h]" + testXpub + "/0/*)"
+		checksum, _ := descriptorChecksum(canonical)
+		assert.Equal(t, canonical+"#"+checksum, descriptorInfo.Descriptor)
+		assert.True(t, descriptorInfo.IsRange)
+		assert.True(t, descriptorInfo.IsSolvable)
+		assert.True(t, descriptorInfo.HasPrivateKeys)
+
+		descriptorInfo, err = client_handler.GetDescriptorInfo(ctx, "raw(deadbeef)")
+		require.NoError(t, err)
+		assert.Equal(t, "raw(deadbeef)#89f8spxm", descriptorInfo.Descriptor)
+		assert.Equal(t, "89f8spxm", descriptorInfo.Checksum)
+		assert.False(t, descriptorInfo.IsSolvable)
+
+		_, err = client_handler.GetDescriptorInfo(ctx, "wpkh(tpubD6NzVbkrYhZ4XgiXtGrdW5XDAPFCL9h7we1vwNCpn8tGbBcgfVYjXyhWo4E1xkh56hjod1RhGjxbaTLV3X4FyWuejifB9jusQ46QzG87VKp/*)")
+		assert.ErrorContains(t, err, "is not valid")
+	})
+
+	t.Run("DeriveAddresses", func(t *testing.T) {
+		desc := "wpkh(" + testXpub + "/0/*)"
+		checksum, _ := descriptorChecksum(desc)
+		addresses, err := client_handler.DeriveAddresses(ctx, desc+"#"+checksum, json.RawMessage(`[1,2]`))
+		require.NoError(t, err)
+
+		// the keys derived by hand
+		master, err := hdkeychain.NewKeyFromString(testXpub)
+		require.NoError(t, err)
+		external, err := master.Derive(0)
+		require.NoError(t, err)
+		var expected []string
+		for index := uint32(1); index <= 2; index++ {
+			key, err := external.Derive(index)
+			require.NoError(t, err)
+			pubKey, err := key.ECPubKey()
+			require.NoError(t, err)
+			address, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pubKey.SerializeCompressed()), &chaincfg.MainNetParams)
+			require.NoError(t, err)
+			expected = append(expected, address.EncodeAddress())
+		}
+		assert.Equal(t, expected, addresses)
+
+		addresses, err = client_handler.DeriveAddresses(ctx, desc+"#"+checksum, json.RawMessage(`2`))
+		require.NoError(t, err)
+		assert.Len(t, addresses, 3)
+		assert.Equal(t, expected, addresses[1:])
+
+		addresses, err = client_handler.DeriveAddresses(ctx, "pkh("+testPubKey+")#e48zzw02", nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"}, addresses)
+
+		for _, test := range []struct {
+			desc            string
+			derivationRange string
+			err             string
+		}{
+			{desc, "", "Missing checksum"},
+			{desc + "#" + checksum, "", "Range must be specified for a ranged descriptor"},
+			{"pkh(" + testPubKey + ")#e48zzw02", "1", "Range should not be specified for an un-ranged descriptor"},
+			{desc + "#" + checksum, "[2,1]", "Range specified as [begin,end] must not have begin after end"},
+			{desc + "#" + checksum, "-1", "Range should be greater or equal than 0"},
+			{desc + "#" + checksum, "[0,1000000]", "Range is too large"},
+			{desc + "#" + checksum, `"all"`, "Range must be specified as end or as [begin,end]"},
+		} {
+			var derivationRange json.RawMessage
+			if test.derivationRange != "" {
+				derivationRange = json.RawMessage(test.derivationRange)
+			}
+			_, err := client_handler.DeriveAddresses(ctx, test.desc, derivationRange)
+			assert.ErrorContains(t, err, test.err, test.desc, test.derivationRange)
+		}
+	})
+}
+
+func TestAddressNetwork(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+	dataContent, err := GenesisContent(params)
+	require.NoError(t, err)
+	dataStore := &DataStore{}
+	require.NoError(t, dataStore.Replace(dataContent))
+	mockService := httptest.NewServer(NewHTTPHandler(&MockServerHandler{Store: dataStore}))
+	defer mockService.Close()
+	client_handler := newTestClient(t, mockService.URL)
+	ctx := context.Background()
+
+	// addresses and keys are those of the network of the genesis block
+	result, err := client_handler.ValidateAddress(ctx, "bcrt1qw508d6qejxtdg4y5r3zarvary0c5xw7kygt080")
+	require.NoError(t, err)
+	assert.True(t, result.IsValid)
+	result, err = client_handler.ValidateAddress(ctx, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4")
+	require.NoError(t, err)
+	assert.Equal(t, "Invalid or unsupported prefix for Segwit (Bech32) address (expected bcrt, got bc).", result.Error)
+
+	addresses, err := client_handler.DeriveAddresses(ctx, "pkh("+testPubKey+")#e48zzw02", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mrCDrCybB6J1vRfbwM5hemdJz73FwDBC8r"}, addresses)
+	_, err = client_handler.GetDescriptorInfo(ctx, "pkh("+testXpub+"/0)")
+	assert.ErrorContains(t, err, "key '"+testXpub+"' is not valid")
+}
@@ -0,0 +1,213 @@
+package mockserver
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// difficultyOneBits is the target bitcoind reports the difficulty relative
+// to, on every network.
+const difficultyOneBits = 0x1d00ffff
+
+// Difficulty returns the difficulty of the compact target bits the way
+// bitcoind reports it.
+func Difficulty(bits uint32) float64 {
+	difficulty, _ := new(big.Rat).SetFrac(
+		blockchain.CompactToBig(difficultyOneBits),
+		blockchain.CompactToBig(bits),
+	).Float64()
+	return difficulty
+}
+
+// NewBlockHeaderResult builds the verbose header result of a block at height.
+// Confirmations and the next block hash depend on the rest of the chain and
+// are left for the caller.
+func NewBlockHeaderResult(header *wire.BlockHeader, height int32) btcjson.GetBlockHeaderVerboseResult {
+	blockHeader := btcjson.GetBlockHeaderVerboseResult{
+		Hash:       header.BlockHash().String(),
+		Height:     height,
+		Version:    header.Version,
+		VersionHex: fmt.Sprintf("%08x", uint32(header.Version)),
+		MerkleRoot: header.MerkleRoot.String(),
+		Time:       header.Timestamp.Unix(),
+		Nonce:      uint64(header.Nonce),
+		Bits:       fmt.Sprintf("%08x", header.Bits),
+		Difficulty: Difficulty(header.Bits),
+	}
+	// like bitcoind, the genesis block has no previous block hash
+	if header.PrevBlock != (wire.BlockHeader{}).PrevBlock {
+		blockHeader.PreviousHash = header.PrevBlock.String()
+	}
+	return blockHeader
+}
+
+// parseBlockHeader rebuilds the header of a block from its verbose result,
+// the inverse of NewBlockHeaderResult.
+func parseBlockHeader(blockHeader btcjson.GetBlockHeaderVerboseResult) (wire.BlockHeader, error) {
+	header := wire.BlockHeader{
+		Version:   blockHeader.Version,
+		Timestamp: time.Unix(blockHeader.Time, 0),
+		Nonce:     uint32(blockHeader.Nonce),
+	}
+	if blockHeader.PreviousHash != "" {
+		prevBlock, err := chainhash.NewHashFromStr(blockHeader.PreviousHash)
+		if err != nil {
+			return wire.BlockHeader{}, fmt.Errorf("block %s: invalid previous block hash: %w", blockHeader.Hash, err)
+		}
+		header.PrevBlock = *prevBlock
+	}
+	merkleRoot, err := chainhash.NewHashFromStr(blockHeader.MerkleRoot)
+	if err != nil {
+		return wire.BlockHeader{}, fmt.Errorf("block %s: invalid merkle root: %w", blockHeader.Hash, err)
+	}
+	header.MerkleRoot = *merkleRoot
+	if header.Bits, err = parseBits(blockHeader); err != nil {
+		return wire.BlockHeader{}, err
+	}
+	return header, nil
+}
+
+// NewTxRawResult builds the verbose result of a transaction, with addresses
+// encoded for params. The block related fields are left for the caller.
+func NewTxRawResult(tx *wire.MsgTx, params *chaincfg.Params) (btcjson.TxRawResult, error) {
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return btcjson.TxRawResult{}, err
+	}
+
+	weight := blockchain.GetTransactionWeight(btcutil.NewTx(tx))
+	return btcjson.TxRawResult{
+		Hex:      hex.EncodeToString(buf.Bytes()),
+		Txid:     tx.TxHash().String(),
+		Hash:     tx.WitnessHash().String(),
+		Size:     int32(tx.SerializeSize()),
+		Vsize:    int32((weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor),
+		Weight:   int32(weight),
+		Version:  uint32(tx.Version),
+		LockTime: tx.LockTime,
+		Vin:      newVinList(tx),
+		Vout:     newVoutList(tx, params),
+	}, nil
+}
+
+func newVinList(tx *wire.MsgTx) []btcjson.Vin {
+	vinList := make([]btcjson.Vin, len(tx.TxIn))
+	if blockchain.IsCoinBaseTx(tx) {
+		txIn := tx.TxIn[0]
+		vinList[0].Coinbase = hex.EncodeToString(txIn.SignatureScript)
+		vinList[0].Sequence = txIn.Sequence
+		if tx.HasWitness() {
+			vinList[0].Witness = txIn.Witness.ToHexStrings()
+		}
+		return vinList
+	}
+
+	for i, txIn := range tx.TxIn {
+		// the disassembly contains [error] inline if the script doesn't parse
+		asm, _ := txscript.DisasmString(txIn.SignatureScript)
+
+		vinList[i].Txid = txIn.PreviousOutPoint.Hash.String()
+		vinList[i].Vout = txIn.PreviousOutPoint.Index
+		vinList[i].Sequence = txIn.Sequence
+		vinList[i].ScriptSig = &btcjson.ScriptSig{
+			Asm: asm,
+			Hex: hex.EncodeToString(txIn.SignatureScript),
+		}
+		if tx.HasWitness() {
+			vinList[i].Witness = txIn.Witness.ToHexStrings()
+		}
+	}
+	return vinList
+}
+
+func newVoutList(tx *wire.MsgTx, params *chaincfg.Params) []btcjson.Vout {
+	voutList := make([]btcjson.Vout, len(tx.TxOut))
+	for i, txOut := range tx.TxOut {
+		voutList[i] = btcjson.Vout{
+			Value:        btcutil.Amount(txOut.Value).ToBTC(),
+			N:            uint32(i),
+			ScriptPubKey: NewScriptPubKeyResult(txOut.PkScript, params),
+		}
+	}
+	return voutList
+}
+
+// NewScriptPubKeyResult describes an output script like bitcoind does: the
+// address is only set for scripts paying to a single address, and not for
+// bare public keys.
+func NewScriptPubKeyResult(pkScript []byte, params *chaincfg.Params) btcjson.ScriptPubKeyResult {
+	// the disassembly contains [error] inline if the script doesn't parse
+	asm, _ := txscript.DisasmString(pkScript)
+	// an error means the script has no addresses to extract
+	scriptClass, addresses, _, _ := txscript.ExtractPkScriptAddrs(pkScript, params)
+
+	scriptPubKey := btcjson.ScriptPubKeyResult{
+		Asm:  asm,
+		Hex:  hex.EncodeToString(pkScript),
+		Type: scriptClass.String(),
+	}
+	if len(addresses) == 1 && scriptClass != txscript.PubKeyTy && scriptClass != txscript.MultiSigTy {
+		scriptPubKey.Address = addresses[0].EncodeAddress()
+	}
+	return scriptPubKey
+}
+
+// BlocksToContent converts a chain of blocks into DataContent. The blocks
+// must be consecutive, the first one at startHeight. Confirmations are
+// counted from the last block, which is taken as the chain tip.
+func BlocksToContent(blocks []*wire.MsgBlock, startHeight int32, params *chaincfg.Params) (DataContent, error) {
+	var dataContent DataContent
+	for i, block := range blocks {
+		var nextHash string
+		if i+1 < len(blocks) {
+			nextHash = blocks[i+1].BlockHash().String()
+		}
+		blockHeader, transactions, err := blockToContent(block, startHeight+int32(i), int64(len(blocks)-i), nextHash, params)
+		if err != nil {
+			return DataContent{}, err
+		}
+		dataContent.BlockHeaders = append(dataContent.BlockHeaders, blockHeader)
+		dataContent.Transactions = append(dataContent.Transactions, transactions...)
+	}
+	return dataContent, nil
+}
+
+// blockToContent converts a block of the active chain at height into its
+// header and transactions.
+func blockToContent(
+	block *wire.MsgBlock,
+	height int32,
+	confirmations int64,
+	nextHash string,
+	params *chaincfg.Params,
+) (btcjson.GetBlockHeaderVerboseResult, []btcjson.TxRawResult, error) {
+	blockHeader := NewBlockHeaderResult(&block.Header, height)
+	blockHeader.Confirmations = confirmations
+	blockHeader.NextHash = nextHash
+
+	transactions := make([]btcjson.TxRawResult, 0, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		transaction, err := NewTxRawResult(tx, params)
+		if err != nil {
+			return btcjson.GetBlockHeaderVerboseResult{}, nil, fmt.Errorf("block %s: %w", blockHeader.Hash, err)
+		}
+		transaction.BlockHash = blockHeader.Hash
+		transaction.Confirmations = uint64(confirmations)
+		// this is not a typo, they are identical in bitcoind as well
+		transaction.Time = blockHeader.Time
+		transaction.Blocktime = blockHeader.Time
+		transactions = append(transactions, transaction)
+	}
+	return blockHeader, transactions, nil
+}
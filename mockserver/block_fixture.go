@@ -0,0 +1,209 @@
+package mockserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// peekFirstByte returns the first byte of reader that is not white space,
+// without consuming it. It returns 0 for an empty stream.
+func peekFirstByte(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err == io.EOF {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if !strings.ContainsRune(" \t\r\n", rune(b)) {
+			return b, reader.UnreadByte()
+		}
+	}
+}
+
+// readBlockHexArray decodes a json array of serialized blocks in hex.
+func readBlockHexArray(reader io.Reader) ([]*wire.MsgBlock, error) {
+	var blocks []*wire.MsgBlock
+	decoder := json.NewDecoder(reader)
+	err := decodeArray(decoder, func() error {
+		var blockHex string
+		if err := decoder.Decode(&blockHex); err != nil {
+			return err
+		}
+		block, err := decodeBlockHex(blockHex)
+		if err != nil {
+			return fmt.Errorf("block %d: %w", len(blocks), err)
+		}
+		blocks = append(blocks, block)
+		return nil
+	})
+	return blocks, err
+}
+
+// readBlockHexLines decodes one serialized block in hex per line. Empty lines
+// and lines starting with `#` are skipped.
+func readBlockHexLines(reader *bufio.Reader) ([]*wire.MsgBlock, error) {
+	var blocks []*wire.MsgBlock
+	for lineNumber := 1; ; lineNumber++ {
+		// blocks can be megabytes long, so lines are not read with a Scanner
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			block, decodeErr := decodeBlockHex(line)
+			if decodeErr != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, decodeErr)
+			}
+			blocks = append(blocks, block)
+		}
+
+		if err == io.EOF {
+			return blocks, nil
+		}
+	}
+}
+
+func decodeBlockHex(blockHex string) (*wire.MsgBlock, error) {
+	raw, err := hex.DecodeString(blockHex)
+	if err != nil {
+		return nil, err
+	}
+	block := &wire.MsgBlock{}
+	if err := block.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// orderBlockChain sorts the blocks of a fixture, which may be listed in any
+// order, into a single chain following the previous block hashes.
+func orderBlockChain(blocks []*wire.MsgBlock) ([]*wire.MsgBlock, error) {
+	hashes := make(map[chainhash.Hash]bool, len(blocks))
+	children := make(map[chainhash.Hash]*wire.MsgBlock, len(blocks))
+	for _, block := range blocks {
+		hash := block.BlockHash()
+		if hashes[hash] {
+			return nil, fmt.Errorf("block %s is listed twice", hash)
+		}
+		hashes[hash] = true
+
+		if sibling, ok := children[block.Header.PrevBlock]; ok {
+			return nil, fmt.Errorf("blocks %s and %s have the same parent", sibling.BlockHash(), hash)
+		}
+		children[block.Header.PrevBlock] = block
+	}
+
+	var first *wire.MsgBlock
+	for _, block := range blocks {
+		if hashes[block.Header.PrevBlock] {
+			continue
+		}
+		if first != nil {
+			return nil, fmt.Errorf("blocks %s and %s are not connected", first.BlockHash(), block.BlockHash())
+		}
+		first = block
+	}
+	if first == nil {
+		return nil, errors.New("blocks form a cycle")
+	}
+
+	chain := make([]*wire.MsgBlock, 0, len(blocks))
+	for block := first; block != nil; block = children[block.BlockHash()] {
+		chain = append(chain, block)
+	}
+	return chain, nil
+}
+
+// firstBlockHeight derives the height of the first block of a fixture chain:
+// from its parent when it was loaded from an earlier file, 0 for a genesis
+// block, or from the BIP34 height in the coinbase of version 2+ blocks.
+func (m *dataMerger) firstBlockHeight(block *wire.MsgBlock) (int32, error) {
+	if index, ok := m.blockHeaderIndex[block.Header.PrevBlock.String()]; ok {
+		return m.content.BlockHeaders[index].Height + 1, nil
+	}
+	if block.Header.PrevBlock == (chainhash.Hash{}) {
+		return 0, nil
+	}
+	if block.Header.Version >= 2 && len(block.Transactions) > 0 {
+		height, err := blockchain.ExtractCoinbaseHeight(btcutil.NewTx(block.Transactions[0]))
+		if err == nil {
+			return height, nil
+		}
+	}
+	return 0, fmt.Errorf("can not derive the height of block %s, its parent is unknown", block.BlockHash())
+}
+
+// addBlocks converts the blocks of a raw block fixture and adds them to the
+// merged content.
+func (l DataLoader) addBlocks(file string, blocks []*wire.MsgBlock, merger *dataMerger) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	chain, err := orderBlockChain(blocks)
+	if err != nil {
+		return err
+	}
+	startHeight, err := merger.firstBlockHeight(chain[0])
+	if err != nil {
+		return err
+	}
+	dataContent, err := BlocksToContent(chain, startHeight, l.params())
+	if err != nil {
+		return err
+	}
+
+	for _, blockHeader := range dataContent.BlockHeaders {
+		merger.addBlockHeader(file, blockHeader)
+	}
+	for _, transaction := range dataContent.Transactions {
+		merger.addTransaction(file, transaction)
+	}
+	merger.extendChain(dataContent.BlockHeaders[0])
+	return nil
+}
+
+// extendChain links the first block of a raw block fixture to its parent
+// from an earlier file, and counts the confirmations of the active chain
+// again from its new tip.
+func (m *dataMerger) extendChain(first btcjson.GetBlockHeaderVerboseResult) {
+	if index, ok := m.blockHeaderIndex[first.PreviousHash]; ok && m.content.BlockHeaders[index].NextHash == "" {
+		m.content.BlockHeaders[index].NextHash = first.Hash
+	}
+
+	// stale blocks have -1 confirmations and keep them
+	tipHeight := int32(-1)
+	for _, blockHeader := range m.content.BlockHeaders {
+		if blockHeader.Confirmations >= 0 {
+			tipHeight = max(tipHeight, blockHeader.Height)
+		}
+	}
+	confirmations := make(map[string]int64, len(m.content.BlockHeaders))
+	for i, blockHeader := range m.content.BlockHeaders {
+		if blockHeader.Confirmations >= 0 {
+			m.content.BlockHeaders[i].Confirmations = int64(tipHeight-blockHeader.Height) + 1
+			confirmations[blockHeader.Hash] = m.content.BlockHeaders[i].Confirmations
+		}
+	}
+	for i, transaction := range m.content.Transactions {
+		if blockConfirmations, ok := confirmations[transaction.BlockHash]; ok {
+			m.content.Transactions[i].Confirmations = uint64(blockConfirmations)
+		}
+	}
+}
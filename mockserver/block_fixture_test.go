@@ -0,0 +1,171 @@
+package mockserver
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func blockHex(t *testing.T, block *wire.MsgBlock) string {
+	var buf bytes.Buffer
+	require.NoError(t, block.Serialize(&buf))
+	return hex.EncodeToString(buf.Bytes())
+}
+
+// bip34Block builds a version 2 block, not connected to any known block,
+// with height in its coinbase and a segwit spend.
+func bip34Block(t *testing.T, height int64) *wire.MsgBlock {
+	heightScript, err := txscript.NewScriptBuilder().AddInt64(height).Script()
+	require.NoError(t, err)
+	address, err := btcutil.NewAddressWitnessPubKeyHash(make([]byte, 20), &chaincfg.RegressionNetParams)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(address)
+	require.NoError(t, err)
+
+	coinbase := wire.NewMsgTx(2)
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex), heightScript, nil))
+	coinbase.AddTxOut(wire.NewTxOut(50e8, pkScript))
+
+	spend := wire.NewMsgTx(2)
+	spend.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{7}, 1), nil, wire.TxWitness{{1, 2}, {3}}))
+	spend.AddTxOut(wire.NewTxOut(1e8, pkScript))
+
+	block := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   2,
+			PrevBlock: chainhash.Hash{9},
+			Timestamp: time.Unix(1700000000, 0),
+			Bits:      chaincfg.RegressionNetParams.PowLimitBits,
+		},
+		Transactions: []*wire.MsgTx{coinbase, spend},
+	}
+	merkles := blockchain.BuildMerkleTreeStore([]*btcutil.Tx{btcutil.NewTx(coinbase), btcutil.NewTx(spend)}, false)
+	block.Header.MerkleRoot = *merkles[len(merkles)-1]
+	return block
+}
+
+func TestLoadBlockFixtures(t *testing.T) {
+	blocks := fixtureBlocks(t)
+	expected, err := BlocksToContent(blocks, 0, &chaincfg.MainNetParams)
+	require.NoError(t, err)
+
+	t.Run("HexLines", func(t *testing.T) {
+		// blocks in any order, with comments and empty lines
+		lines := []string{"# mainnet blocks 0 to 10", ""}
+		for _, i := range []int{3, 0, 1, 2, 10, 9, 8, 7, 4, 5, 6} {
+			lines = append(lines, blockHex(t, blocks[i]))
+		}
+		path := filepath.Join(t.TempDir(), "blocks.hex")
+		require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600))
+
+		dataContent, err := LoadDataFiles(path)
+		require.NoError(t, err)
+		assert.Equal(t, expected, dataContent)
+	})
+
+	t.Run("JsonArray", func(t *testing.T) {
+		var hexes []string
+		for _, block := range blocks {
+			hexes = append(hexes, blockHex(t, block))
+		}
+		byteValue, err := json.Marshal(hexes)
+		require.NoError(t, err)
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "blocks.json"), byteValue, 0o600))
+
+		dataContent, err := LoadDataFiles(dir)
+		require.NoError(t, err)
+		assert.Equal(t, expected, dataContent)
+	})
+
+	t.Run("HeightFromEarlierFile", func(t *testing.T) {
+		dir := t.TempDir()
+		base := writeDataFile(t, dir, "base.json", DataContent{
+			BlockHeaders: []btcjson.GetBlockHeaderVerboseResult{expected.BlockHeaders[5]},
+		})
+		var lines []string
+		for _, block := range blocks[6:] {
+			lines = append(lines, blockHex(t, block))
+		}
+		path := filepath.Join(dir, "blocks.hex")
+		require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600))
+
+		dataContent, err := LoadDataFiles(base, path)
+		require.NoError(t, err)
+		require.Len(t, dataContent.BlockHeaders, 6)
+		for i, blockHeader := range dataContent.BlockHeaders {
+			assert.Equal(t, int32(5+i), blockHeader.Height)
+		}
+	})
+
+	t.Run("ContinueEarlierFile", func(t *testing.T) {
+		// the earlier file ends at its tip, without a next block
+		dir := t.TempDir()
+		baseContent, err := BlocksToContent(blocks[:2], 0, &chaincfg.MainNetParams)
+		require.NoError(t, err)
+		base := writeDataFile(t, dir, "base.json", baseContent)
+		path := filepath.Join(dir, "blocks.hex")
+		lines := blockHex(t, blocks[2]) + "\n" + blockHex(t, blocks[3])
+		require.NoError(t, os.WriteFile(path, []byte(lines), 0o600))
+
+		dataContent, err := LoadDataFiles(base, path)
+		require.NoError(t, err)
+		expected, err := BlocksToContent(blocks[:4], 0, &chaincfg.MainNetParams)
+		require.NoError(t, err)
+		assert.Equal(t, expected, dataContent)
+	})
+
+	t.Run("HeightFromCoinbase", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "blocks.hex")
+		require.NoError(t, os.WriteFile(path, []byte(blockHex(t, bip34Block(t, 500))), 0o600))
+
+		loader := DataLoader{Params: &chaincfg.RegressionNetParams}
+		dataContent, err := loader.Load(path)
+		require.NoError(t, err)
+		require.Len(t, dataContent.BlockHeaders, 1)
+		assert.Equal(t, int32(500), dataContent.BlockHeaders[0].Height)
+
+		require.Len(t, dataContent.Transactions, 2)
+		spend := dataContent.Transactions[1]
+		assert.NotEqual(t, spend.Txid, spend.Hash)
+		assert.Less(t, spend.Vsize, spend.Size)
+		assert.Equal(t, []string{"0102", "03"}, spend.Vin[0].Witness)
+		assert.Equal(t, "witness_v0_keyhash", spend.Vout[0].ScriptPubKey.Type)
+		assert.True(t, strings.HasPrefix(spend.Vout[0].ScriptPubKey.Address, "bcrt1"))
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		fork := *blocks[2]
+		fork.Header.Nonce++
+		for name, content := range map[string]string{
+			"UnknownHeight": blockHex(t, blocks[5]),
+			"NotConnected":  blockHex(t, blocks[0]) + "\n" + blockHex(t, blocks[2]),
+			"Fork":          strings.Join([]string{blockHex(t, blocks[0]), blockHex(t, blocks[1]), blockHex(t, blocks[2]), blockHex(t, &fork)}, "\n"),
+			"InvalidHex":    "zz",
+			"Truncated":     blockHex(t, blocks[1])[:100],
+		} {
+			t.Run(name, func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "blocks.hex")
+				require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+				_, err := LoadDataFiles(path)
+				assert.Error(t, err)
+			})
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package mockserver
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// BlockProducer mines a block at intervals, confirming the mempool, so the
+// chain of a long running mock moves like a live one without a test driving
+// it. The intervals run on the Clock of the miner: while a mock time is set
+// the next block is only mined once the mock time is set or advanced past
+// it.
+type BlockProducer struct {
+	miner    *Miner
+	interval time.Duration
+	poisson  bool
+	random   *rand.Rand
+}
+
+// NewBlockProducer creates a producer mining with miner every interval. With
+// poisson the time to the next block is drawn from random, seeded from the
+// wall clock when nil, with an exponential distribution of mean interval
+// instead, like the block times of mainnet.
+func NewBlockProducer(miner *Miner, interval time.Duration, poisson bool, random *rand.Rand) *BlockProducer {
+	if random == nil {
+		random = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return &BlockProducer{
+		miner:    miner,
+		interval: interval,
+		poisson:  poisson,
+		random:   random,
+	}
+}
+
+// nextDelay returns the time to the next block.
+func (p *BlockProducer) nextDelay() time.Duration {
+	if !p.poisson {
+		return p.interval
+	}
+	return time.Duration(p.random.ExpFloat64() * float64(p.interval))
+}
+
+// Run mines the blocks until ctx is done. A failure to mine a block is
+// logged and the next block is tried after the next interval. A clock moved
+// past several intervals at once mines a single block.
+func (p *BlockProducer) Run(ctx context.Context) {
+	clock := p.miner.Clock
+	for {
+		if clock.waitUntil(ctx, clock.Now().Add(p.nextDelay())) != nil {
+			return
+		}
+
+		hashes, err := p.miner.Mine(1)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to mine block")
+		} else {
+			log.Info().Msgf("Mined block %s", hashes[0])
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package mockserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"math/rand"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockProducer(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+	dataContent, err := BlocksToContent([]*wire.MsgBlock{params.GenesisBlock}, 0, params)
+	require.NoError(t, err)
+	dataStore := &DataStore{}
+	require.NoError(t, dataStore.Replace(dataContent))
+	serverHandler := &MockServerHandler{Store: dataStore, Clock: &Clock{}}
+	mockService := httptest.NewServer(NewHTTPHandler(serverHandler))
+	defer mockService.Close()
+	client_handler := newTestClient(t, mockService.URL)
+	ctx := context.Background()
+
+	_, err = client_handler.SendRawTransaction(ctx, "not hex", nil)
+	assert.ErrorContains(t, err, "TX decode failed")
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}))
+	var raw bytes.Buffer
+	require.NoError(t, tx.Serialize(&raw))
+	txid, err := client_handler.SendRawTransaction(ctx, hex.EncodeToString(raw.Bytes()), nil)
+	require.NoError(t, err)
+	assert.Equal(t, tx.TxHash(), *txid)
+
+	// the pending transaction is confirmed by the next produced block
+	miner := &Miner{Store: dataStore, Clock: serverHandler.Clock}
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stopped := make(chan struct{})
+	go func() {
+		NewBlockProducer(miner, 20*time.Millisecond, false, nil).Run(runCtx)
+		close(stopped)
+	}()
+	// the producer runs until it mined 3 blocks, however slow the machine
+	require.Eventually(t, func() bool {
+		blockCount, err := serverHandler.GetBlockCount()
+		return err == nil && blockCount >= 3
+	}, 10*time.Second, 10*time.Millisecond)
+	cancel()
+	<-stopped
+
+	blockCount, err := client_handler.GetBlockCount(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, blockCount, int32(3))
+	transaction, err := client_handler.GetRawTransaction(ctx, txid, true, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(blockCount), transaction.Confirmations)
+}
+
+func TestBlockProducerPoisson(t *testing.T) {
+	const interval = 10 * time.Minute
+	producer := NewBlockProducer(nil, interval, true, rand.New(rand.NewSource(1)))
+	var total time.Duration
+	const samples = 10000
+	for range samples {
+		total += producer.nextDelay()
+	}
+	assert.InEpsilon(t, float64(interval), float64(total/samples), 0.05)
+
+	assert.Equal(t, interval, NewBlockProducer(nil, interval, false, nil).nextDelay())
+}
+
+// TestBlockProducerMockTime checks the blocks follow the mock time: a block
+// is only mined once the clock is moved past its interval.
+func TestBlockProducerMockTime(t *testing.T) {
+	dataContent, err := GenesisContent(&chaincfg.RegressionNetParams)
+	require.NoError(t, err)
+	dataStore := &DataStore{}
+	require.NoError(t, dataStore.Replace(dataContent))
+	clock := &Clock{}
+	clock.SetMockTime(time.Unix(1700000000, 0))
+	serverHandler := &MockServerHandler{Store: dataStore, Clock: clock}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stopped := make(chan struct{})
+	go func() {
+		NewBlockProducer(&Miner{Store: dataStore, Clock: clock}, time.Minute, false, nil).Run(ctx)
+		close(stopped)
+	}()
+
+	// the clock moves in steps of a second until the next block, whenever
+	// the producer started waiting
+	for _, height := range []int32{1, 2} {
+		require.Eventually(t, func() bool {
+			blockCount, err := serverHandler.GetBlockCount()
+			if err == nil && blockCount == height {
+				return true
+			}
+			clock.Advance(time.Second)
+			return false
+		}, 10*time.Second, time.Millisecond)
+	}
+	// without the clock moving no further block is mined
+	time.Sleep(50 * time.Millisecond)
+	blockCount, err := serverHandler.GetBlockCount()
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), blockCount)
+	cancel()
+	<-stopped
+}
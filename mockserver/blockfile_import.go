@@ -0,0 +1,310 @@
+package mockserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// blockFileParams are the networks whose blk*.dat files can be imported,
+// recognized by the magic bytes in front of every block.
+var blockFileParams = []*chaincfg.Params{
+	&chaincfg.MainNetParams,
+	&chaincfg.TestNet3Params,
+	&TestNet4Params,
+	&chaincfg.RegressionNetParams,
+	&chaincfg.SigNetParams,
+}
+
+// blockFileEntry locates a block in a blk*.dat file.
+type blockFileEntry struct {
+	file   string
+	offset int
+	size   int
+	header wire.BlockHeader
+	hash   chainhash.Hash
+
+	height int32
+	work   *big.Int
+	// orphan is set for blocks not connected to a genesis block
+	orphan bool
+}
+
+// ImportBlockFiles reads the blocks stored in Bitcoin Core blk*.dat files and
+// converts the best chain into DataContent. Every path is either a blocks
+// directory (all blk*.dat files inside it) or a single file. Files obfuscated
+// with the key in the `xor.dat` file next to them are decoded. The network is
+// detected from the magic bytes of the blocks.
+//
+// Files are read twice: the first pass indexes the block headers to find the
+// chain with the most work starting at the genesis block of the network, the
+// second one converts the blocks of that chain one file at a time, so only
+// the blocks of a single file are decoded at once. Blocks not connected to
+// the genesis block and stale forks are left out.
+func ImportBlockFiles(paths ...string) (DataContent, *chaincfg.Params, error) {
+	files, err := expandBlockFiles(paths)
+	if err != nil {
+		return DataContent{}, nil, err
+	}
+
+	var params *chaincfg.Params
+	entries := make(map[chainhash.Hash]*blockFileEntry)
+	var order []*blockFileEntry
+	for _, file := range files {
+		data, err := readBlockFile(file)
+		if err != nil {
+			return DataContent{}, nil, err
+		}
+		err = scanBlockFile(data, func(net wire.BitcoinNet, offset, size int) error {
+			if params == nil {
+				if params = blockFileNetwork(net); params == nil {
+					return fmt.Errorf("unknown network magic %08x", uint32(net))
+				}
+			} else if net != params.Net {
+				return fmt.Errorf("block at offset %d is not a %s block", offset, params.Name)
+			}
+
+			entry := &blockFileEntry{file: file, offset: offset, size: size, height: -1}
+			if err := entry.header.Deserialize(bytes.NewReader(data[offset : offset+size])); err != nil {
+				return fmt.Errorf("block at offset %d: %w", offset, err)
+			}
+			entry.hash = entry.header.BlockHash()
+			if _, ok := entries[entry.hash]; !ok {
+				entries[entry.hash] = entry
+				order = append(order, entry)
+			}
+			return nil
+		})
+		if err != nil {
+			return DataContent{}, nil, fmt.Errorf("failed to read block file %s: %w", file, err)
+		}
+	}
+	if params == nil {
+		return DataContent{}, nil, errors.New("no blocks found in block files")
+	}
+
+	bestChain := findBestChain(entries, order, params)
+	if len(bestChain) == 0 {
+		return DataContent{}, nil, fmt.Errorf("block files do not contain the %s genesis block", params.Name)
+	}
+
+	// the blocks are converted in file order, their transactions are put
+	// back in height order at the end
+	dataContent := DataContent{BlockHeaders: make([]btcjson.GetBlockHeaderVerboseResult, len(bestChain))}
+	blockTxs := make([][]btcjson.TxRawResult, len(bestChain))
+	err = readBestChain(bestChain, params,
+		func(blockHeader btcjson.GetBlockHeaderVerboseResult, transactions []btcjson.TxRawResult) {
+			dataContent.BlockHeaders[blockHeader.Height] = blockHeader
+			blockTxs[blockHeader.Height] = transactions
+		})
+	if err != nil {
+		return DataContent{}, nil, err
+	}
+	for height, transactions := range blockTxs {
+		dataContent.Transactions = append(dataContent.Transactions, transactions...)
+		blockTxs[height] = nil
+	}
+	return dataContent, params, nil
+}
+
+// expandBlockFiles resolves blocks directories into their blk*.dat files.
+func expandBlockFiles(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no block files given")
+	}
+
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		matches, err := filepath.Glob(filepath.Join(path, "blk*.dat"))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("directory %s contains no blk*.dat files", path)
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// readBlockFile reads a blk*.dat file, removing the xor obfuscation Bitcoin
+// Core applies when an `xor.dat` key is present in the same directory.
+func readBlockFile(file string) ([]byte, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := os.ReadFile(filepath.Join(filepath.Dir(file), "xor.dat"))
+	if errors.Is(err, os.ErrNotExist) || len(key) == 0 {
+		return data, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range data {
+		data[i] ^= key[i%len(key)]
+	}
+	return data, nil
+}
+
+// scanBlockFile calls visit with the network magic, offset and size of every
+// block record in data. Scanning stops at the zero padding Bitcoin Core
+// preallocates at the end of the files.
+func scanBlockFile(data []byte, visit func(net wire.BitcoinNet, offset, size int) error) error {
+	for offset := 0; offset+8 <= len(data); {
+		net := wire.BitcoinNet(binary.LittleEndian.Uint32(data[offset:]))
+		if net == 0 {
+			return nil
+		}
+		size := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		offset += 8
+		if size < wire.MaxBlockHeaderPayload || offset+size > len(data) {
+			return fmt.Errorf("truncated block at offset %d", offset)
+		}
+		if err := visit(net, offset, size); err != nil {
+			return err
+		}
+		offset += size
+	}
+	return nil
+}
+
+func blockFileNetwork(net wire.BitcoinNet) *chaincfg.Params {
+	for _, params := range blockFileParams {
+		if params.Net == net {
+			return params
+		}
+	}
+	return nil
+}
+
+// findBestChain computes the height and cumulative work of every block
+// connected to the genesis block of params and returns the chain ending at
+// the block with the most work, ordered from genesis to tip. On equal work
+// the block seen first wins, like in bitcoind.
+func findBestChain(
+	entries map[chainhash.Hash]*blockFileEntry,
+	order []*blockFileEntry,
+	params *chaincfg.Params,
+) []*blockFileEntry {
+	resolve := func(entry *blockFileEntry) bool {
+		// walk back to the first block with a known height, then fill in the
+		// heights on the way forward, without recursing over the whole chain
+		var path []*blockFileEntry
+		for entry.height < 0 {
+			parent, hasParent := entries[entry.header.PrevBlock]
+			isGenesis := entry.hash == *params.GenesisHash
+			if entry.orphan || (!hasParent && !isGenesis) {
+				for _, orphan := range path {
+					orphan.orphan = true
+				}
+				entry.orphan = true
+				return false
+			}
+			path = append(path, entry)
+			if isGenesis {
+				break
+			}
+			entry = parent
+		}
+
+		for i := len(path) - 1; i >= 0; i-- {
+			current := path[i]
+			work := blockchain.CalcWork(current.header.Bits)
+			if current.hash == *params.GenesisHash {
+				current.height = 0
+				current.work = work
+				continue
+			}
+			parent := entries[current.header.PrevBlock]
+			current.height = parent.height + 1
+			current.work = work.Add(work, parent.work)
+		}
+		return true
+	}
+
+	var tip *blockFileEntry
+	for _, entry := range order {
+		if !resolve(entry) {
+			continue
+		}
+		if tip == nil || entry.work.Cmp(tip.work) > 0 {
+			tip = entry
+		}
+	}
+	if tip == nil {
+		return nil
+	}
+
+	bestChain := make([]*blockFileEntry, tip.height+1)
+	for entry := tip; ; entry = entries[entry.header.PrevBlock] {
+		bestChain[entry.height] = entry
+		if entry.height == 0 {
+			break
+		}
+	}
+	return bestChain
+}
+
+// readBestChain converts the blocks of the best chain, reading every block
+// file once, and passes each block to emit as soon as its file is read.
+func readBestChain(
+	bestChain []*blockFileEntry,
+	params *chaincfg.Params,
+	emit func(btcjson.GetBlockHeaderVerboseResult, []btcjson.TxRawResult),
+) error {
+	byFile := make(map[string][]*blockFileEntry)
+	var files []string
+	for _, entry := range bestChain {
+		if _, ok := byFile[entry.file]; !ok {
+			files = append(files, entry.file)
+		}
+		byFile[entry.file] = append(byFile[entry.file], entry)
+	}
+
+	tipHeight := int32(len(bestChain) - 1)
+	for _, file := range files {
+		data, err := readBlockFile(file)
+		if err != nil {
+			return err
+		}
+		for _, entry := range byFile[file] {
+			block := &wire.MsgBlock{}
+			if err := block.Deserialize(bytes.NewReader(data[entry.offset : entry.offset+entry.size])); err != nil {
+				return fmt.Errorf("failed to decode block at height %d in %s: %w", entry.height, file, err)
+			}
+			var nextHash string
+			if entry.height < tipHeight {
+				nextHash = bestChain[entry.height+1].hash.String()
+			}
+			blockHeader, transactions, err := blockToContent(block, entry.height, int64(tipHeight-entry.height+1), nextHash, params)
+			if err != nil {
+				return err
+			}
+			emit(blockHeader, transactions)
+		}
+	}
+	return nil
+}
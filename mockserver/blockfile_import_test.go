@@ -0,0 +1,179 @@
+package mockserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixtureBlocks rebuilds the raw mainnet blocks 0 to 10 from the headers and
+// transaction hex in data/mainnet_oldest_blocks.json.
+func fixtureBlocks(t *testing.T) []*wire.MsgBlock {
+	dataContent, err := LoadDataFiles("../data/mainnet_oldest_blocks.json")
+	require.NoError(t, err)
+
+	blocks := []*wire.MsgBlock{chaincfg.MainNetParams.GenesisBlock}
+	for height := int32(1); height <= 10; height++ {
+		var blockHeader btcjson.GetBlockHeaderVerboseResult
+		for _, candidate := range dataContent.BlockHeaders {
+			if candidate.Height == height {
+				blockHeader = candidate
+			}
+		}
+
+		prevBlock, err := chainhash.NewHashFromStr(blockHeader.PreviousHash)
+		require.NoError(t, err)
+		merkleRoot, err := chainhash.NewHashFromStr(blockHeader.MerkleRoot)
+		require.NoError(t, err)
+		bits, err := hex.DecodeString(blockHeader.Bits)
+		require.NoError(t, err)
+
+		block := &wire.MsgBlock{Header: wire.BlockHeader{
+			Version:    blockHeader.Version,
+			PrevBlock:  *prevBlock,
+			MerkleRoot: *merkleRoot,
+			Timestamp:  time.Unix(blockHeader.Time, 0),
+			Bits:       binary.BigEndian.Uint32(bits),
+			Nonce:      uint32(blockHeader.Nonce),
+		}}
+		for _, transaction := range dataContent.Transactions {
+			if transaction.BlockHash != blockHeader.Hash {
+				continue
+			}
+			raw, err := hex.DecodeString(transaction.Hex)
+			require.NoError(t, err)
+			tx := &wire.MsgTx{}
+			require.NoError(t, tx.Deserialize(bytes.NewReader(raw)))
+			block.Transactions = append(block.Transactions, tx)
+		}
+		require.Equal(t, blockHeader.Hash, block.BlockHash().String())
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// writeBlockFile stores blocks in the blk*.dat format, obfuscated with key
+// when it is not empty.
+func writeBlockFile(t *testing.T, path string, net wire.BitcoinNet, key []byte, blocks ...*wire.MsgBlock) {
+	var buf bytes.Buffer
+	for _, block := range blocks {
+		record := make([]byte, 8)
+		binary.LittleEndian.PutUint32(record, uint32(net))
+		binary.LittleEndian.PutUint32(record[4:], uint32(block.SerializeSize()))
+		buf.Write(record)
+		require.NoError(t, block.Serialize(&buf))
+	}
+	// preallocated zero padding
+	buf.Write(make([]byte, 64))
+
+	data := buf.Bytes()
+	for i := range data {
+		if len(key) > 0 {
+			data[i] ^= key[i%len(key)]
+		}
+	}
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+func TestConvertFixtureBlocks(t *testing.T) {
+	expected, err := LoadDataFiles("../data/mainnet_oldest_blocks.json")
+	require.NoError(t, err)
+
+	blocks := fixtureBlocks(t)
+	for _, transaction := range expected.Transactions {
+		var tx *wire.MsgTx
+		for _, block := range blocks {
+			for _, candidate := range block.Transactions {
+				if candidate.TxHash().String() == transaction.Txid {
+					tx = candidate
+				}
+			}
+		}
+		require.NotNil(t, tx)
+
+		converted, err := NewTxRawResult(tx, &chaincfg.MainNetParams)
+		assert.NoError(t, err)
+		transaction.BlockHash, transaction.Confirmations = "", 0
+		transaction.Time, transaction.Blocktime = 0, 0
+		assert.Equal(t, transaction, converted)
+	}
+}
+
+func TestImportBlockFiles(t *testing.T) {
+	blocks := fixtureBlocks(t)
+	dir := t.TempDir()
+	net := chaincfg.MainNetParams.Net
+
+	// a competing block at height 10 with the same work, stored after the
+	// original one, and a block whose parent is unknown
+	fork := *blocks[10]
+	fork.Header.Nonce++
+	orphan := *blocks[5]
+	orphan.Header.PrevBlock = chainhash.Hash{1}
+
+	key := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "xor.dat"), key, 0o600))
+	// blocks are not stored in height order
+	writeBlockFile(t, filepath.Join(dir, "blk00000.dat"), net, key, blocks[0], blocks[2], blocks[1], blocks[10], &orphan)
+	writeBlockFile(t, filepath.Join(dir, "blk00001.dat"), net, key, blocks[3:10]...)
+	writeBlockFile(t, filepath.Join(dir, "blk00002.dat"), net, key, &fork)
+
+	dataContent, params, err := ImportBlockFiles(dir)
+	require.NoError(t, err)
+	assert.Equal(t, chaincfg.MainNetParams.Name, params.Name)
+
+	expected, err := BlocksToContent(blocks, 0, params)
+	require.NoError(t, err)
+	assert.Equal(t, expected, dataContent)
+
+	// the headers match the fixture apart from the confirmations, and the
+	// imported tip has no next block
+	fixture, err := LoadDataFiles("../data/mainnet_oldest_blocks.json")
+	require.NoError(t, err)
+	state := newChainState(dataContent)
+	for _, blockHeader := range fixture.BlockHeaders {
+		imported, ok := state.BlockHeaderByHash(blockHeader.Hash)
+		require.True(t, ok)
+		assert.Equal(t, int64(11-blockHeader.Height), imported.Confirmations)
+		imported.Confirmations = blockHeader.Confirmations
+		if blockHeader.Height == 10 {
+			imported.NextHash = blockHeader.NextHash
+		}
+		assert.Equal(t, blockHeader, imported)
+	}
+
+	t.Run("MixedNetworks", func(t *testing.T) {
+		dir := t.TempDir()
+		writeBlockFile(t, filepath.Join(dir, "blk00000.dat"), net, nil, blocks[0])
+		writeBlockFile(t, filepath.Join(dir, "blk00001.dat"), chaincfg.RegressionNetParams.Net, nil, blocks[1])
+
+		_, _, err := ImportBlockFiles(dir)
+		assert.Error(t, err)
+	})
+
+	t.Run("MissingGenesis", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "blk00000.dat")
+		writeBlockFile(t, path, net, nil, blocks[1:]...)
+
+		_, _, err := ImportBlockFiles(path)
+		assert.Error(t, err)
+
+		// a block without a parent is not the genesis block of the network
+		genesis := *blocks[0]
+		genesis.Header.Nonce++
+		writeBlockFile(t, path, net, nil, &genesis)
+		_, _, err = ImportBlockFiles(path)
+		assert.ErrorContains(t, err, "block files do not contain the mainnet genesis block")
+	})
+}
@@ -0,0 +1,683 @@
+package mockserver
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// headersBucket maps block hash -> json block header
+	headersBucket = []byte("headers")
+	// heightsBucket maps big endian height -> block hash
+	heightsBucket = []byte("heights")
+	// headerOrderBucket maps sequence -> block hash, in data content order
+	headerOrderBucket = []byte("header_order")
+	// transactionsBucket maps txid -> json transaction
+	transactionsBucket = []byte("transactions")
+	// txOrderBucket maps sequence -> txid, in data content order
+	txOrderBucket = []byte("tx_order")
+	// blockTxsBucket maps block hash + 0x00 + sequence -> txid, the mempool
+	// under the empty hash
+	blockTxsBucket = []byte("block_txs")
+	// chainWorkBucket maps block hash -> big endian total work of the chain
+	// up to the block
+	chainWorkBucket = []byte("chain_work")
+	// metaBucket holds single values such as the network info
+	metaBucket = []byte("meta")
+
+	networkInfoKey = []byte("network_info")
+	// confirmationsHeightKey holds the big endian height of the best block
+	// when the stored confirmations were counted, see confirmationCount
+	confirmationsHeightKey = []byte("confirmations_height")
+
+	allBuckets = [][]byte{
+		headersBucket, heightsBucket, headerOrderBucket,
+		transactionsBucket, txOrderBucket, blockTxsBucket, chainWorkBucket, metaBucket,
+	}
+)
+
+// BoltStore is a ChainStore persisted in a bbolt database file, so large
+// fixtures only need to be imported once. Lookups are served from bbolt read
+// transactions without loading the whole content, only Content does.
+// Append, used for mining and the mempool, only writes the new entries.
+// Update reads the whole content and compares every entry, so it is meant
+// for rare rewrites such as reorgs. Replace rewrites all records.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+var _ ChainStore = (*BoltStore)(nil)
+
+// OpenBoltStore opens or creates the database at path.
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt store %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		// databases written before the chain work was stored get it now
+		missingChainWork := tx.Bucket(chainWorkBucket) == nil
+		for _, name := range allBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		if missingChainWork {
+			if err := putChainWork(tx, (&boltView{tx: tx}).Content().BlockHeaders); err != nil {
+				return err
+			}
+		}
+		// databases written before the confirmations were derived get the
+		// mempool index, their confirmations are counted from the best block
+		if tx.Bucket(metaBucket).Get(confirmationsHeightKey) == nil {
+			if err := putMempool(tx); err != nil {
+				return err
+			}
+			return putConfirmationsHeight(tx)
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize bolt store %s: %w", path, err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// View starts a read transaction which is kept open until the view is
+// released.
+func (b *BoltStore) View() (ChainView, error) {
+	tx, err := b.db.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	return &boltView{tx: tx}, nil
+}
+
+// Replace swaps the whole content of the store.
+func (b *BoltStore) Replace(dataContent DataContent) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return writeBoltContent(tx, dataContent)
+	})
+}
+
+// Update reads the whole current content into memory, applies fn and writes
+// the entries which differ from the stored ones back in a single write
+// transaction. Its cost grows with the chain, Append is the incremental
+// write. Like for DataStore.Update, fn must not modify slices nested inside
+// the entries in place.
+func (b *BoltStore) Update(fn func(dataContent *DataContent) error) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		current := (&boltView{tx: tx}).Content()
+		dataContent := DataContent{
+			BlockHeaders: slices.Clone(current.BlockHeaders),
+			Transactions: slices.Clone(current.Transactions),
+			NetworkInfo:  current.NetworkInfo,
+		}
+		if err := fn(&dataContent); err != nil {
+			return err
+		}
+		if err := patchBoltContent(tx, current, dataContent); err != nil {
+			return err
+		}
+		return putConfirmationsHeight(tx)
+	})
+}
+
+// Append applies fn to a view of the write transaction and writes the
+// blocks and transactions fn returns, see ChainStore.Append. Only the new
+// entries, the parent of the first block and the order of the transactions
+// from the first one which moved to the end are written. The stored
+// confirmations are kept, see confirmationCount.
+func (b *BoltStore) Append(fn func(view ChainView) (DataContent, error)) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		added, err := fn(&boltView{tx: tx})
+		if err != nil {
+			return err
+		}
+		return appendBoltContent(tx, added)
+	})
+}
+
+// Close closes the database file.
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
+
+// writeBoltContent clears all buckets and stores dataContent.
+func writeBoltContent(tx *bolt.Tx, dataContent DataContent) error {
+	for _, name := range allBuckets {
+		if err := tx.DeleteBucket(name); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return err
+		}
+	}
+
+	headers := tx.Bucket(headersBucket)
+	heights := tx.Bucket(heightsBucket)
+	headerOrder := tx.Bucket(headerOrderBucket)
+	for i, blockHeader := range dataContent.BlockHeaders {
+		value, err := json.Marshal(blockHeader)
+		if err != nil {
+			return err
+		}
+		hash := []byte(blockHeader.Hash)
+		if err := headers.Put(hash, value); err != nil {
+			return err
+		}
+		if err := heights.Put(heightKey(blockHeader.Height), hash); err != nil {
+			return err
+		}
+		if err := headerOrder.Put(sequenceKey(uint64(i)), hash); err != nil {
+			return err
+		}
+	}
+	if err := putChainWork(tx, dataContent.BlockHeaders); err != nil {
+		return err
+	}
+
+	transactions := tx.Bucket(transactionsBucket)
+	txOrder := tx.Bucket(txOrderBucket)
+	blockTxs := tx.Bucket(blockTxsBucket)
+	for i, transaction := range dataContent.Transactions {
+		value, err := json.Marshal(transaction)
+		if err != nil {
+			return err
+		}
+		txid := []byte(transaction.Txid)
+		if err := transactions.Put(txid, value); err != nil {
+			return err
+		}
+		if err := txOrder.Put(sequenceKey(uint64(i)), txid); err != nil {
+			return err
+		}
+		key := append(blockTxsPrefix(transaction.BlockHash), sequenceKey(uint64(i))...)
+		if err := blockTxs.Put(key, txid); err != nil {
+			return err
+		}
+	}
+
+	value, err := json.Marshal(dataContent.NetworkInfo)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(metaBucket).Put(networkInfoKey, value); err != nil {
+		return err
+	}
+	return putConfirmationsHeight(tx)
+}
+
+// appendBoltContent writes the blocks and transactions of added, see
+// BoltStore.Append.
+func appendBoltContent(tx *bolt.Tx, added DataContent) error {
+	headers := tx.Bucket(headersBucket)
+	if len(added.BlockHeaders) > 0 {
+		parentHash := []byte(added.BlockHeaders[0].PreviousHash)
+		if raw := headers.Get(parentHash); raw != nil {
+			var parent btcjson.GetBlockHeaderVerboseResult
+			if err := json.Unmarshal(raw, &parent); err != nil {
+				return err
+			}
+			parent.NextHash = added.BlockHeaders[0].Hash
+			value, err := json.Marshal(parent)
+			if err != nil {
+				return err
+			}
+			if err := headers.Put(parentHash, value); err != nil {
+				return err
+			}
+		}
+	}
+	if err := patchBoltHeaders(tx, bucketLength(tx.Bucket(headerOrderBucket)), nil, added.BlockHeaders); err != nil {
+		return err
+	}
+
+	// the stored transactions which move to the end, usually the mempool
+	// at the end already, are searched from the end, and the order is
+	// rewritten from the first of them on
+	txOrder := tx.Bucket(txOrderBucket)
+	from := bucketLength(txOrder)
+	moving := make(map[string]bool, len(added.Transactions))
+	for _, transaction := range added.Transactions {
+		if tx.Bucket(transactionsBucket).Get([]byte(transaction.Txid)) != nil {
+			moving[transaction.Txid] = true
+		}
+	}
+	cursor := txOrder.Cursor()
+	for key, txid := cursor.Last(); key != nil && len(moving) > 0; key, txid = cursor.Prev() {
+		if moving[string(txid)] {
+			delete(moving, string(txid))
+			from = int(binary.BigEndian.Uint64(key))
+		}
+	}
+
+	view := &boltView{tx: tx}
+	var current []btcjson.TxRawResult
+	for key, txid := cursor.Seek(sequenceKey(uint64(from))); key != nil; key, txid = cursor.Next() {
+		transaction, ok := view.storedTransaction(string(txid))
+		if !ok {
+			return fmt.Errorf("transaction %s is not stored", txid)
+		}
+		current = append(current, transaction)
+	}
+	transactions := appendContent(DataContent{Transactions: current}, DataContent{Transactions: added.Transactions})
+	return patchBoltTransactions(tx, from, current, transactions.Transactions)
+}
+
+// putMempool indexes the mempool transactions under the empty block hash.
+func putMempool(tx *bolt.Tx) error {
+	view := &boltView{tx: tx}
+	cursor := tx.Bucket(txOrderBucket).Cursor()
+	for key, txid := cursor.First(); key != nil; key, txid = cursor.Next() {
+		if transaction, ok := view.storedTransaction(string(txid)); ok && transaction.BlockHash == "" {
+			if err := tx.Bucket(blockTxsBucket).Put(append(blockTxsPrefix(""), key...), txid); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// putConfirmationsHeight records that the stored confirmations are counted
+// from the current best block.
+func putConfirmationsHeight(tx *bolt.Tx) error {
+	key, _ := tx.Bucket(heightsBucket).Cursor().Last()
+	if key == nil {
+		key = heightKey(-1)
+	}
+	return tx.Bucket(metaBucket).Put(confirmationsHeightKey, bytes.Clone(key))
+}
+
+// patchBoltContent turns the stored content current into dataContent. Only
+// the entries which differ from the stored ones are written, and the order
+// buckets are only rewritten from the first entry which moved. The
+// confirmations of dataContent are stored as they are, so after blocks were
+// appended every entry below them is rewritten with its derived count.
+func patchBoltContent(tx *bolt.Tx, current, dataContent DataContent) error {
+	if err := patchBoltHeaders(tx, 0, current.BlockHeaders, dataContent.BlockHeaders); err != nil {
+		return err
+	}
+	if err := patchBoltTransactions(tx, 0, current.Transactions, dataContent.Transactions); err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(dataContent.NetworkInfo)
+	if err != nil {
+		return err
+	}
+	return putChanged(tx.Bucket(metaBucket), networkInfoKey, value)
+}
+
+// patchBoltHeaders turns the stored headers current, from the sequence offset
+// on, into blockHeaders.
+func patchBoltHeaders(tx *bolt.Tx, offset int, current, blockHeaders []btcjson.GetBlockHeaderVerboseResult) error {
+	headers := tx.Bucket(headersBucket)
+	heights := tx.Bucket(heightsBucket)
+	headerOrder := tx.Bucket(headerOrderBucket)
+	chainWork := tx.Bucket(chainWorkBucket)
+
+	moved := firstMoved(current, blockHeaders, func(a, b btcjson.GetBlockHeaderVerboseResult) bool {
+		return a.Hash == b.Hash
+	})
+	kept := make(map[string]bool, len(blockHeaders)-moved)
+	for _, blockHeader := range blockHeaders[moved:] {
+		kept[blockHeader.Hash] = true
+	}
+	// the heights of removed headers are pointed to the header left at
+	// that height, if any, below
+	removedHeights := make(map[int32]bool)
+	for _, blockHeader := range current[moved:] {
+		if kept[blockHeader.Hash] {
+			continue
+		}
+		if err := headers.Delete([]byte(blockHeader.Hash)); err != nil {
+			return err
+		}
+		if err := chainWork.Delete([]byte(blockHeader.Hash)); err != nil {
+			return err
+		}
+		if err := heights.Delete(heightKey(blockHeader.Height)); err != nil {
+			return err
+		}
+		removedHeights[blockHeader.Height] = true
+	}
+	if err := truncateBucket(headerOrder, offset+len(blockHeaders)); err != nil {
+		return err
+	}
+
+	var added []btcjson.GetBlockHeaderVerboseResult
+	for i, blockHeader := range blockHeaders {
+		value, err := json.Marshal(blockHeader)
+		if err != nil {
+			return err
+		}
+		hash := []byte(blockHeader.Hash)
+		if chainWork.Get(hash) == nil {
+			added = append(added, blockHeader)
+		}
+		if err := putChanged(headers, hash, value); err != nil {
+			return err
+		}
+		if i < moved && !removedHeights[blockHeader.Height] {
+			continue
+		}
+		// later headers win a height, like in writeBoltContent
+		if err := heights.Put(heightKey(blockHeader.Height), hash); err != nil {
+			return err
+		}
+		if i >= moved {
+			if err := headerOrder.Put(sequenceKey(uint64(offset+i)), hash); err != nil {
+				return err
+			}
+		}
+	}
+	return putChainWork(tx, added)
+}
+
+// putChainWork stores the chain work of blockHeaders, see headerChainWork.
+// The parents of the headers must be stored or among blockHeaders.
+func putChainWork(tx *bolt.Tx, blockHeaders []btcjson.GetBlockHeaderVerboseResult) error {
+	chainWork := tx.Bucket(chainWorkBucket)
+	// parents are one height below their blocks
+	blockHeaders = slices.Clone(blockHeaders)
+	slices.SortStableFunc(blockHeaders, func(a, b btcjson.GetBlockHeaderVerboseResult) int {
+		return cmp.Compare(a.Height, b.Height)
+	})
+	for _, blockHeader := range blockHeaders {
+		var parentWork *big.Int
+		if blockHeader.PreviousHash != "" && tx.Bucket(headersBucket).Get([]byte(blockHeader.PreviousHash)) != nil {
+			if value := chainWork.Get([]byte(blockHeader.PreviousHash)); value != nil {
+				parentWork = new(big.Int).SetBytes(value)
+			}
+		}
+		work := headerChainWork(blockHeader, parentWork)
+		if err := chainWork.Put([]byte(blockHeader.Hash), work.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchBoltTransactions turns the stored transactions current, from the
+// sequence offset on, into transactions.
+func patchBoltTransactions(tx *bolt.Tx, offset int, current, transactions []btcjson.TxRawResult) error {
+	txs := tx.Bucket(transactionsBucket)
+	txOrder := tx.Bucket(txOrderBucket)
+	blockTxs := tx.Bucket(blockTxsBucket)
+
+	moved := firstMoved(current, transactions, func(a, b btcjson.TxRawResult) bool {
+		return a.Txid == b.Txid
+	})
+	kept := make(map[string]bool, len(transactions)-moved)
+	for _, transaction := range transactions[moved:] {
+		kept[transaction.Txid] = true
+	}
+	// the block keys hold the sequence, so they are all deleted before the
+	// new ones are put
+	for i, transaction := range current {
+		if i >= moved && !kept[transaction.Txid] {
+			if err := txs.Delete([]byte(transaction.Txid)); err != nil {
+				return err
+			}
+		}
+		if i < moved && transaction.BlockHash == transactions[i].BlockHash {
+			continue
+		}
+		key := append(blockTxsPrefix(transaction.BlockHash), sequenceKey(uint64(offset+i))...)
+		if err := blockTxs.Delete(key); err != nil {
+			return err
+		}
+	}
+	if err := truncateBucket(txOrder, offset+len(transactions)); err != nil {
+		return err
+	}
+
+	for i, transaction := range transactions {
+		value, err := json.Marshal(transaction)
+		if err != nil {
+			return err
+		}
+		txid := []byte(transaction.Txid)
+		if err := putChanged(txs, txid, value); err != nil {
+			return err
+		}
+		if i >= moved {
+			if err := txOrder.Put(sequenceKey(uint64(offset+i)), txid); err != nil {
+				return err
+			}
+		}
+		if i < moved && transaction.BlockHash == current[i].BlockHash {
+			continue
+		}
+		key := append(blockTxsPrefix(transaction.BlockHash), sequenceKey(uint64(offset+i))...)
+		if err := blockTxs.Put(key, txid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// firstMoved returns the index of the first entry of entries which is not
+// the entry of current at the same index, by same.
+func firstMoved[T any](current, entries []T, same func(a, b T) bool) int {
+	for i := range min(len(current), len(entries)) {
+		if !same(current[i], entries[i]) {
+			return i
+		}
+	}
+	return min(len(current), len(entries))
+}
+
+// putChanged puts value under key unless it is already stored.
+func putChanged(bucket *bolt.Bucket, key, value []byte) error {
+	if bytes.Equal(bucket.Get(key), value) {
+		return nil
+	}
+	return bucket.Put(key, value)
+}
+
+// truncateBucket deletes the sequence keys from n on.
+func truncateBucket(bucket *bolt.Bucket, n int) error {
+	var keys [][]byte
+	cursor := bucket.Cursor()
+	for key, _ := cursor.Seek(sequenceKey(uint64(n))); key != nil; key, _ = cursor.Next() {
+		keys = append(keys, bytes.Clone(key))
+	}
+	for _, key := range keys {
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bucketLength returns the number of sequence keys of an order bucket.
+func bucketLength(bucket *bolt.Bucket) int {
+	key, _ := bucket.Cursor().Last()
+	if key == nil {
+		return 0
+	}
+	return int(binary.BigEndian.Uint64(key)) + 1
+}
+
+func heightKey(height int32) []byte {
+	key := make([]byte, 4)
+	binary.BigEndian.PutUint32(key, uint32(height))
+	return key
+}
+
+func sequenceKey(sequence uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, sequence)
+	return key
+}
+
+// blockTxsPrefix returns the key prefix of the transactions of a block. The
+// separator keeps a hash from matching the keys of a longer hash.
+func blockTxsPrefix(blockHash string) []byte {
+	return append([]byte(blockHash), 0)
+}
+
+// boltView is a ChainView backed by a bbolt read transaction.
+type boltView struct {
+	tx *bolt.Tx
+}
+
+var _ ChainView = (*boltView)(nil)
+
+// get unmarshals the json value stored under key into v.
+func (v *boltView) get(bucket, key []byte, value any) bool {
+	raw := v.tx.Bucket(bucket).Get(key)
+	if raw == nil {
+		return false
+	}
+	return json.Unmarshal(raw, value) == nil
+}
+
+// count returns the derivation of the confirmations from the best block.
+// Databases without the height of the stored confirmations count them from
+// the best block.
+func (v *boltView) count() confirmationCount {
+	key, _ := v.tx.Bucket(heightsBucket).Cursor().Last()
+	if key == nil {
+		return confirmationCount{storedHeight: -1, bestHeight: -1}
+	}
+	count := confirmationCount{bestHeight: int32(binary.BigEndian.Uint32(key))}
+	count.storedHeight = count.bestHeight
+	if value := v.tx.Bucket(metaBucket).Get(confirmationsHeightKey); value != nil {
+		count.storedHeight = int32(binary.BigEndian.Uint32(value))
+	}
+	return count
+}
+
+// storedHeader returns a header with its stored confirmations.
+func (v *boltView) storedHeader(hash string) (btcjson.GetBlockHeaderVerboseResult, bool) {
+	var blockHeader btcjson.GetBlockHeaderVerboseResult
+	ok := v.get(headersBucket, []byte(hash), &blockHeader)
+	return blockHeader, ok
+}
+
+// storedTransaction returns a transaction with its stored confirmations.
+func (v *boltView) storedTransaction(txid string) (btcjson.TxRawResult, bool) {
+	var transaction btcjson.TxRawResult
+	ok := v.get(transactionsBucket, []byte(txid), &transaction)
+	return transaction, ok
+}
+
+// countTransaction derives the confirmations of a stored transaction.
+func (v *boltView) countTransaction(count confirmationCount, transaction btcjson.TxRawResult) btcjson.TxRawResult {
+	if count.current() {
+		return transaction
+	}
+	blockHeader, ok := v.storedHeader(transaction.BlockHash)
+	return count.transaction(transaction, blockHeader, ok)
+}
+
+func (v *boltView) BlockHeaderByHash(hash string) (btcjson.GetBlockHeaderVerboseResult, bool) {
+	blockHeader, ok := v.storedHeader(hash)
+	if !ok {
+		return blockHeader, false
+	}
+	return v.count().blockHeader(blockHeader), true
+}
+
+func (v *boltView) BlockHeaderByHeight(height int32) (btcjson.GetBlockHeaderVerboseResult, bool) {
+	hash := v.tx.Bucket(heightsBucket).Get(heightKey(height))
+	if hash == nil {
+		return btcjson.GetBlockHeaderVerboseResult{}, false
+	}
+	return v.BlockHeaderByHash(string(hash))
+}
+
+func (v *boltView) BestBlockHeader() (btcjson.GetBlockHeaderVerboseResult, bool) {
+	_, hash := v.tx.Bucket(heightsBucket).Cursor().Last()
+	if hash == nil {
+		return btcjson.GetBlockHeaderVerboseResult{}, false
+	}
+	return v.BlockHeaderByHash(string(hash))
+}
+
+func (v *boltView) Transaction(txid string) (btcjson.TxRawResult, bool) {
+	transaction, ok := v.storedTransaction(txid)
+	if !ok {
+		return transaction, false
+	}
+	return v.countTransaction(v.count(), transaction), true
+}
+
+func (v *boltView) BlockTransactions(blockHash string) []btcjson.TxRawResult {
+	count := v.count()
+	var transactions []btcjson.TxRawResult
+	prefix := blockTxsPrefix(blockHash)
+	cursor := v.tx.Bucket(blockTxsBucket).Cursor()
+	for key, txid := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, txid = cursor.Next() {
+		if transaction, ok := v.storedTransaction(string(txid)); ok {
+			transactions = append(transactions, v.countTransaction(count, transaction))
+		}
+	}
+	return transactions
+}
+
+func (v *boltView) Mempool() []btcjson.TxRawResult {
+	return v.BlockTransactions("")
+}
+
+func (v *boltView) ChainWork(blockHash string) (*big.Int, bool) {
+	value := v.tx.Bucket(chainWorkBucket).Get([]byte(blockHash))
+	if value == nil {
+		return nil, false
+	}
+	return new(big.Int).SetBytes(value), true
+}
+
+func (v *boltView) NetworkInfo() btcjson.GetNetworkInfoResult {
+	var networkInfo btcjson.GetNetworkInfoResult
+	v.get(metaBucket, networkInfoKey, &networkInfo)
+	return networkInfo
+}
+
+// Content reads the whole content into memory, in the order it was stored,
+// with the derived confirmations.
+func (v *boltView) Content() DataContent {
+	var dataContent DataContent
+	count := v.count()
+
+	// the stored headers, to derive the confirmations of their transactions
+	blockHeaders := make(map[string]btcjson.GetBlockHeaderVerboseResult)
+	cursor := v.tx.Bucket(headerOrderBucket).Cursor()
+	for key, hash := cursor.First(); key != nil; key, hash = cursor.Next() {
+		if blockHeader, ok := v.storedHeader(string(hash)); ok {
+			blockHeaders[blockHeader.Hash] = blockHeader
+			dataContent.BlockHeaders = append(dataContent.BlockHeaders, count.blockHeader(blockHeader))
+		}
+	}
+
+	cursor = v.tx.Bucket(txOrderBucket).Cursor()
+	for key, txid := cursor.First(); key != nil; key, txid = cursor.Next() {
+		if transaction, ok := v.storedTransaction(string(txid)); ok {
+			blockHeader, ok := blockHeaders[transaction.BlockHash]
+			dataContent.Transactions = append(dataContent.Transactions, count.transaction(transaction, blockHeader, ok))
+		}
+	}
+
+	dataContent.NetworkInfo = v.NetworkInfo()
+	return dataContent
+}
+
+// Release ends the read transaction.
+func (v *boltView) Release() {
+	_ = v.tx.Rollback()
+}
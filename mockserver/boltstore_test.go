@@ -0,0 +1,208 @@
+package mockserver
+
+import (
+	"bytes"
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+// TestBoltStore checks the bolt store serves the same data as the in-memory
+// store, also after reopening the database.
+func TestBoltStore(t *testing.T) {
+	dataContent, err := LoadDataFiles("../data/mainnet_oldest_blocks.json")
+	assert.NoError(t, err)
+
+	dataStore := &DataStore{}
+	assert.NoError(t, dataStore.Replace(dataContent))
+
+	dbPath := filepath.Join(t.TempDir(), "chain.db")
+	boltStore, err := OpenBoltStore(dbPath)
+	assert.NoError(t, err)
+	assert.NoError(t, boltStore.Replace(dataContent))
+	assert.NoError(t, boltStore.Close())
+
+	boltStore, err = OpenBoltStore(dbPath)
+	assert.NoError(t, err)
+	defer boltStore.Close()
+
+	expected, err := dataStore.View()
+	assert.NoError(t, err)
+	defer expected.Release()
+	actual, err := boltStore.View()
+	assert.NoError(t, err)
+	defer actual.Release()
+
+	for _, blockHeader := range dataContent.BlockHeaders {
+		byHash, ok := actual.BlockHeaderByHash(blockHeader.Hash)
+		assert.True(t, ok)
+		assert.Equal(t, blockHeader, byHash)
+
+		byHeight, ok := actual.BlockHeaderByHeight(blockHeader.Height)
+		assert.True(t, ok)
+		assert.Equal(t, blockHeader, byHeight)
+
+		assert.Equal(t, expected.BlockTransactions(blockHeader.Hash), actual.BlockTransactions(blockHeader.Hash))
+	}
+	for _, transaction := range dataContent.Transactions {
+		stored, ok := actual.Transaction(transaction.Txid)
+		assert.True(t, ok)
+		assert.Equal(t, transaction, stored)
+	}
+
+	expectedBest, _ := expected.BestBlockHeader()
+	actualBest, ok := actual.BestBlockHeader()
+	assert.True(t, ok)
+	assert.Equal(t, expectedBest, actualBest)
+	assert.Equal(t, expected.NetworkInfo(), actual.NetworkInfo())
+	assert.Equal(t, expected.Content(), actual.Content())
+
+	_, ok = actual.BlockHeaderByHash("00")
+	assert.False(t, ok)
+
+	t.Run("Update", func(t *testing.T) {
+		err := boltStore.Update(func(dataContent *DataContent) error {
+			dataContent.BlockHeaders = append(dataContent.BlockHeaders, btcjson.GetBlockHeaderVerboseResult{
+				Hash:   "aa",
+				Height: 11,
+			})
+			return nil
+		})
+		assert.NoError(t, err)
+
+		view, err := boltStore.View()
+		assert.NoError(t, err)
+		defer view.Release()
+
+		bestBlockHeader, ok := view.BestBlockHeader()
+		assert.True(t, ok)
+		assert.Equal(t, "aa", bestBlockHeader.Hash)
+	})
+
+	t.Run("Handler", func(t *testing.T) {
+		serverHandler := &MockServerHandler{Store: boltStore}
+		_, err := serverHandler.MockReload()
+		assert.Error(t, err)
+
+		blockCount, err := serverHandler.GetBlockCount()
+		assert.NoError(t, err)
+		assert.Equal(t, int32(11), blockCount)
+	})
+}
+
+// TestBoltStoreMining mines on a large bolt store and checks it ends up with
+// the same content as the in-memory store mined the same way.
+func TestBoltStoreMining(t *testing.T) {
+	dataContent, err := GenesisContent(&chaincfg.RegressionNetParams)
+	require.NoError(t, err)
+	dataStore := &DataStore{}
+	require.NoError(t, dataStore.Replace(dataContent))
+	_, err = (&Miner{Store: dataStore}).Mine(2000)
+	require.NoError(t, err)
+
+	boltStore, err := OpenBoltStore(filepath.Join(t.TempDir(), "chain.db"))
+	require.NoError(t, err)
+	defer boltStore.Close()
+	require.NoError(t, boltStore.Replace(dataStore.current().Content()))
+
+	// both miners start from the same extra nonce, so they mine the same
+	// blocks
+	expectedMiner := &Miner{Store: dataStore}
+	actualMiner := &Miner{Store: boltStore}
+	for _, miner := range []*Miner{expectedMiner, actualMiner} {
+		_, err = miner.Mine(3)
+		require.NoError(t, err)
+		_, err = miner.Reorg(2)
+		require.NoError(t, err)
+		_, err = miner.Mine(1)
+		require.NoError(t, err)
+	}
+
+	expected := dataStore.current()
+	actual, err := boltStore.View()
+	require.NoError(t, err)
+	defer actual.Release()
+	assert.Equal(t, expected.Content(), actual.Content())
+	expectedBest, _ := expected.BestBlockHeader()
+	actualBest, ok := actual.BestBlockHeader()
+	require.True(t, ok)
+	assert.Equal(t, expectedBest, actualBest)
+	for height := int32(0); height <= expectedBest.Height; height++ {
+		expectedHeader, _ := expected.BlockHeaderByHeight(height)
+		actualHeader, ok := actual.BlockHeaderByHeight(height)
+		require.True(t, ok)
+		assert.Equal(t, expectedHeader, actualHeader)
+		assert.Equal(t, expected.BlockTransactions(expectedHeader.Hash), actual.BlockTransactions(actualHeader.Hash))
+	}
+}
+
+// TestBoltStoreAppend checks mining only writes the new entries: the blocks
+// below keep their stored confirmations, which are derived from the best
+// block, also after reopening the database.
+func TestBoltStoreAppend(t *testing.T) {
+	dataContent, err := LoadDataFiles("../data/mainnet_oldest_blocks.json")
+	require.NoError(t, err)
+	blockHeader := dataContent.BlockHeaders[5]
+	transaction := dataContent.Transactions[5]
+
+	dbPath := filepath.Join(t.TempDir(), "chain.db")
+	boltStore, err := OpenBoltStore(dbPath)
+	require.NoError(t, err)
+	require.NoError(t, boltStore.Replace(dataContent))
+	stored := func() (storedHeader, storedTransaction []byte) {
+		require.NoError(t, boltStore.db.View(func(tx *bolt.Tx) error {
+			storedHeader = bytes.Clone(tx.Bucket(headersBucket).Get([]byte(blockHeader.Hash)))
+			storedTransaction = bytes.Clone(tx.Bucket(transactionsBucket).Get([]byte(transaction.Txid)))
+			return nil
+		}))
+		return storedHeader, storedTransaction
+	}
+	storedHeader, storedTransaction := stored()
+
+	// a transaction spending an unknown output goes to the mempool and is
+	// confirmed by the next block
+	spend := wire.NewMsgTx(wire.TxVersion)
+	spend.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+	spend.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}))
+	var raw bytes.Buffer
+	require.NoError(t, spend.Serialize(&raw))
+	miner := &Miner{Store: boltStore}
+	txid, err := miner.SubmitTransaction(hex.EncodeToString(raw.Bytes()))
+	require.NoError(t, err)
+	hashes, err := miner.Mine(3)
+	require.NoError(t, err)
+
+	minedHeader, minedTransaction := stored()
+	assert.Equal(t, storedHeader, minedHeader)
+	assert.Equal(t, storedTransaction, minedTransaction)
+	require.NoError(t, boltStore.Close())
+
+	boltStore, err = OpenBoltStore(dbPath)
+	require.NoError(t, err)
+	defer boltStore.Close()
+	view, err := boltStore.View()
+	require.NoError(t, err)
+	defer view.Release()
+
+	counted, ok := view.BlockHeaderByHash(blockHeader.Hash)
+	require.True(t, ok)
+	assert.Equal(t, blockHeader.Confirmations+3, counted.Confirmations)
+	countedTransaction, ok := view.Transaction(transaction.Txid)
+	require.True(t, ok)
+	assert.Equal(t, transaction.Confirmations+3, countedTransaction.Confirmations)
+
+	assert.Empty(t, view.Mempool())
+	blockTransactions := view.BlockTransactions(hashes[0].String())
+	require.Len(t, blockTransactions, 2)
+	assert.Equal(t, txid.String(), blockTransactions[1].Txid)
+	assert.Equal(t, uint64(3), blockTransactions[1].Confirmations)
+}
@@ -0,0 +1,203 @@
+package mockserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrCassetteMismatch is the json-rpc error code returned for calls that do
+// not match the next interaction of a cassette.
+const ErrCassetteMismatch btcjson.RPCErrorCode = -32099
+
+// Interaction is a json-rpc call and the exact response it got.
+type Interaction struct {
+	Method string            `json:"method"`
+	Params json.RawMessage   `json:"params,omitempty"`
+	Result json.RawMessage   `json:"result,omitempty"`
+	Error  *btcjson.RPCError `json:"error,omitempty"`
+}
+
+// Cassette is a recorded RPC conversation, in call order.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// ReadCassette reads a cassette file, optionally gzip or zstd compressed.
+func ReadCassette(path string) (Cassette, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Cassette{}, err
+	}
+	defer file.Close()
+
+	reader, closeReader, err := decompress(file)
+	if err != nil {
+		return Cassette{}, fmt.Errorf("failed to decompress cassette %s: %w", path, err)
+	}
+	defer closeReader()
+
+	var cassette Cassette
+	if err := json.NewDecoder(reader).Decode(&cassette); err != nil {
+		return Cassette{}, fmt.Errorf("failed to unmarshal cassette %s: %w", path, err)
+	}
+	return cassette, nil
+}
+
+// WriteCassette stores a cassette file, compressed like WriteDataFile.
+func WriteCassette(path string, cassette Cassette) error {
+	return writeJSONFile(path, cassette)
+}
+
+// CassettePlayer is an http.Handler replaying a cassette. Every call must
+// match the method and params of the next interaction, which is answered
+// with the recorded response. Any other call is answered with an
+// ErrCassetteMismatch error holding a diff between the expected and the
+// actual call, and is reported by Err. The player does not move past a
+// mismatch, so a wrong call does not shift the rest of the conversation.
+type CassettePlayer struct {
+	mu         sync.Mutex
+	cassette   Cassette
+	next       int
+	mismatches []error
+}
+
+// NewCassettePlayer creates a player at the start of the cassette.
+func NewCassettePlayer(cassette Cassette) *CassettePlayer {
+	return &CassettePlayer{cassette: cassette}
+}
+
+func (p *CassettePlayer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var response any
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		var requests []rpcRequest
+		if err := json.Unmarshal(body, &requests); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		responses := make([]rpcReply, len(requests))
+		for i, request := range requests {
+			responses[i] = p.play(request)
+		}
+		response = responses
+	} else {
+		var request rpcRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		response = p.play(request)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// rpcReply is a json-rpc response written by the mock itself.
+type rpcReply struct {
+	JSONRPC string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id"`
+	Result  json.RawMessage   `json:"result,omitempty"`
+	Error   *btcjson.RPCError `json:"error,omitempty"`
+}
+
+// play answers a call with the next interaction if it matches.
+func (p *CassettePlayer) play(request rpcRequest) rpcReply {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	response := rpcReply{JSONRPC: "2.0", ID: request.ID}
+	call := Interaction{Method: request.Method, Params: request.Params}
+
+	var mismatch error
+	if p.next >= len(p.cassette.Interactions) {
+		mismatch = fmt.Errorf("unexpected call %s after the end of the cassette", describeCall(call))
+	} else if expected := p.cassette.Interactions[p.next]; !sameCall(expected, call) {
+		mismatch = fmt.Errorf("call %d does not match the cassette:\n%s", p.next, diffCalls(expected, call))
+	}
+	if mismatch != nil {
+		log.Error().Msg(mismatch.Error())
+		p.mismatches = append(p.mismatches, mismatch)
+		response.Error = &btcjson.RPCError{Code: ErrCassetteMismatch, Message: mismatch.Error()}
+		return response
+	}
+
+	interaction := p.cassette.Interactions[p.next]
+	p.next++
+	response.Result, response.Error = interaction.Result, interaction.Error
+	if response.Result == nil && response.Error == nil {
+		response.Result = json.RawMessage("null")
+	}
+	return response
+}
+
+// Err reports every mismatched call and the interactions not played yet.
+// Tests call it at the end to check the whole conversation took place.
+func (p *CassettePlayer) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	errs := append([]error(nil), p.mismatches...)
+	if remaining := len(p.cassette.Interactions) - p.next; remaining > 0 {
+		errs = append(errs, fmt.Errorf("%d interactions not played, next is %s",
+			remaining, describeCall(p.cassette.Interactions[p.next])))
+	}
+	return errors.Join(errs...)
+}
+
+// sameCall compares method and params, the params as json values so the
+// formatting does not matter.
+func sameCall(expected, actual Interaction) bool {
+	if expected.Method != actual.Method {
+		return false
+	}
+	var expectedParams, actualParams any
+	_ = json.Unmarshal(nullIfEmpty(expected.Params), &expectedParams)
+	_ = json.Unmarshal(nullIfEmpty(actual.Params), &actualParams)
+	return reflect.DeepEqual(expectedParams, actualParams)
+}
+
+func nullIfEmpty(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return json.RawMessage("null")
+	}
+	return raw
+}
+
+func describeCall(call Interaction) string {
+	return fmt.Sprintf("%s %s", call.Method, nullIfEmpty(call.Params))
+}
+
+// diffCalls renders a unified diff of the expected and actual calls.
+func diffCalls(expected, actual Interaction) string {
+	render := func(call Interaction) string {
+		var params any
+		_ = json.Unmarshal(nullIfEmpty(call.Params), &params)
+		rendered, _ := json.MarshalIndent(map[string]any{"method": call.Method, "params": params}, "", "  ")
+		return string(rendered) + "\n"
+	}
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(render(expected)),
+		B:        difflib.SplitLines(render(actual)),
+		FromFile: "expected",
+		ToFile:   "actual",
+		Context:  3,
+	})
+	return diff
+}
@@ -0,0 +1,105 @@
+package mockserver
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/gonative-cc/btc-mock-node/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClient connects a client to the json-rpc server at url.
+func newTestClient(t *testing.T, url string) *client.Client {
+	client_handler, close_handler, err := client.New(context.Background(), url)
+	require.NoError(t, err)
+	t.Cleanup(close_handler)
+	return client_handler
+}
+
+func TestCassette(t *testing.T) {
+	ctx := context.Background()
+	blockHash, err := chainhash.NewHashFromStr("0000000071966c2b1d065fd446b1e485b2c9d9594acd2007ccbd5441cfc89444")
+	require.NoError(t, err)
+
+	// the conversation of the code under test
+	conversation := func(client_handler *client.Client) (int32, int32, error) {
+		blockCount, err := client_handler.GetBlockCount(ctx)
+		require.NoError(t, err)
+		blockHeader, err := client_handler.GetBlockHeader(ctx, blockHash, true)
+		require.NoError(t, err)
+		_, txErr := client_handler.GetRawTransaction(ctx, &chainhash.Hash{}, true, nil)
+		return blockCount, blockHeader.Height, txErr
+	}
+
+	// record it against a live mock
+	upstream := NewMockRPCServer("../data/mainnet_oldest_blocks.json")
+	defer upstream.Close()
+	recorder := NewRecorder(upstream.URL)
+	proxy := httptest.NewServer(recorder)
+	defer proxy.Close()
+	blockCount, height, txErr := conversation(newTestClient(t, proxy.URL))
+	assert.Error(t, txErr)
+
+	path := filepath.Join(t.TempDir(), "cassette.json.gz")
+	require.NoError(t, recorder.SaveCassette(path))
+	cassette, err := ReadCassette(path)
+	require.NoError(t, err)
+	require.Len(t, cassette.Interactions, 3)
+
+	newPlayer := func() (*CassettePlayer, *client.Client) {
+		player := NewCassettePlayer(cassette)
+		server := httptest.NewServer(player)
+		t.Cleanup(server.Close)
+		return player, newTestClient(t, server.URL)
+	}
+
+	t.Run("Replay", func(t *testing.T) {
+		player, client_handler := newPlayer()
+		replayedCount, replayedHeight, replayedErr := conversation(client_handler)
+		assert.Equal(t, blockCount, replayedCount)
+		assert.Equal(t, height, replayedHeight)
+		assert.EqualError(t, replayedErr, txErr.Error())
+		assert.NoError(t, player.Err())
+	})
+
+	t.Run("OutOfOrder", func(t *testing.T) {
+		player, client_handler := newPlayer()
+		_, err := client_handler.GetBlockHeader(ctx, blockHash, true)
+		assert.ErrorContains(t, err, "call 0 does not match")
+		assert.ErrorContains(t, err, `-  "method": "MockServerHandler.GetBlockCount"`)
+		assert.ErrorContains(t, err, `+  "method": "MockServerHandler.GetBlockHeader"`)
+
+		// the player still expects the first call
+		conversation(client_handler)
+		assert.ErrorContains(t, player.Err(), "call 0 does not match")
+	})
+
+	t.Run("DifferentParams", func(t *testing.T) {
+		player, client_handler := newPlayer()
+		_, err := client_handler.GetBlockCount(ctx)
+		require.NoError(t, err)
+		_, err = client_handler.GetBlockHeader(ctx, blockHash, false)
+		assert.ErrorContains(t, err, "-    true")
+		assert.ErrorContains(t, err, "+    false")
+		assert.Error(t, player.Err())
+	})
+
+	t.Run("Unexpected", func(t *testing.T) {
+		player, client_handler := newPlayer()
+		conversation(client_handler)
+		_, err := client_handler.GetBlockCount(ctx)
+		assert.ErrorContains(t, err, "after the end of the cassette")
+		assert.Error(t, player.Err())
+	})
+
+	t.Run("NotPlayed", func(t *testing.T) {
+		player, client_handler := newPlayer()
+		_, err := client_handler.GetBlockCount(ctx)
+		require.NoError(t, err)
+		assert.ErrorContains(t, player.Err(), "2 interactions not played, next is MockServerHandler.GetBlockHeader")
+	})
+}
@@ -0,0 +1,194 @@
+package mockserver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+// Clock is the time of the mock. It follows the wall clock until a mock time
+// is set, like with the setmocktime RPC of bitcoind, and then only moves when
+// set or advanced, so time-sensitive tests are deterministic. A nil Clock is
+// the wall clock.
+type Clock struct {
+	mu sync.Mutex
+	// mockTime is the time of the clock, the wall clock when zero
+	mockTime time.Time
+	// changes is closed when the mock time is set or advanced, see changed
+	changes chan struct{}
+	// snapshots are the mock times saved with the chain snapshots, by id
+	snapshots map[uint64]time.Time
+}
+
+// Now returns the time of the clock.
+func (c *Clock) Now() time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.mockTime.IsZero() {
+		return time.Now()
+	}
+	return c.mockTime
+}
+
+// MockTime returns the mock time, false while the clock follows the wall
+// clock.
+func (c *Clock) MockTime() (time.Time, bool) {
+	if c == nil {
+		return time.Time{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.mockTime, !c.mockTime.IsZero()
+}
+
+// SetMockTime stops the clock at mockTime, the zero time sets the clock back
+// to the wall clock.
+func (c *Clock) SetMockTime(mockTime time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mockTime = mockTime
+	c.notify()
+}
+
+// Advance moves the clock forward by d and returns the new time. A clock
+// following the wall clock is stopped at the wall clock time plus d.
+func (c *Clock) Advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.mockTime.IsZero() {
+		c.mockTime = time.Now().Truncate(time.Second)
+	}
+	c.mockTime = c.mockTime.Add(d)
+	c.notify()
+	return c.mockTime
+}
+
+// changed returns a channel closed the next time the mock time is set or
+// advanced, so waits on the clock can check it again. The channel of a nil
+// Clock is never closed.
+func (c *Clock) changed() <-chan struct{} {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.changes == nil {
+		c.changes = make(chan struct{})
+	}
+	return c.changes
+}
+
+// waitUntil blocks until the clock reaches deadline or ctx is done. The
+// deadline is checked again whenever the mock time moves, the timer only
+// fires it while the clock follows the wall clock.
+func (c *Clock) waitUntil(ctx context.Context, deadline time.Time) error {
+	for {
+		changed := c.changed()
+		wait := deadline.Sub(c.Now())
+		if wait <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-changed:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// notify wakes the waits on changed, c.mu must be held.
+func (c *Clock) notify() {
+	if c.changes != nil {
+		close(c.changes)
+		c.changes = nil
+	}
+}
+
+// saveMockTime saves the mock time with the chain snapshot id.
+func (c *Clock) saveMockTime(id uint64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.snapshots == nil {
+		c.snapshots = make(map[uint64]time.Time)
+	}
+	c.snapshots[id] = c.mockTime
+}
+
+// restoreMockTime sets the clock back to the mock time saved with the chain
+// snapshot id and, like the store, discards it and the later ones. A clock
+// without a saved time is left alone.
+func (c *Clock) restoreMockTime(id uint64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	mockTime, ok := c.snapshots[id]
+	if !ok {
+		return
+	}
+	for snapshotID := range c.snapshots {
+		if snapshotID >= id {
+			delete(c.snapshots, snapshotID)
+		}
+	}
+	c.mockTime = mockTime
+	c.notify()
+}
+
+var errNoClock = &RPCError{
+	Code:    btcjson.ErrRPCMisc,
+	Message: "Mock time is not enabled",
+}
+
+// SetMockTime sets the time of the node to timestamp in unix seconds, 0 sets
+// it back to the wall clock, like bitcoind's setmocktime. Mined blocks,
+// mempool entries, the initial block download and the time offset reported by
+// getnetworkinfo follow the time of the node.
+func (h *MockServerHandler) SetMockTime(timestamp int64) error {
+	if h.Clock == nil {
+		return errNoClock
+	}
+	if timestamp < 0 {
+		return &RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Mocktime cannot be negative: %d.", timestamp),
+		}
+	}
+
+	var mockTime time.Time
+	if timestamp > 0 {
+		mockTime = time.Unix(timestamp, 0)
+	}
+	h.Clock.SetMockTime(mockTime)
+	return nil
+}
+
+// MockAdvanceTime moves the time of the node forward by seconds and returns
+// the new mock time in unix seconds. A node following the wall clock is
+// stopped at the wall clock time plus seconds.
+func (h *MockServerHandler) MockAdvanceTime(seconds int64) (int64, error) {
+	if h.Clock == nil {
+		return 0, errNoClock
+	}
+	if seconds < 0 {
+		return 0, &RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Time can not go backwards, use setmocktime",
+		}
+	}
+	return h.Clock.Advance(time.Duration(seconds) * time.Second).Unix(), nil
+}
@@ -0,0 +1,122 @@
+package mockserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClock(t *testing.T) {
+	var wallClock *Clock
+	assert.WithinDuration(t, time.Now(), wallClock.Now(), time.Second)
+	_, ok := wallClock.MockTime()
+	assert.False(t, ok)
+
+	clock := &Clock{}
+	assert.WithinDuration(t, time.Now(), clock.Now(), time.Second)
+	mockTime := time.Unix(1700000000, 0)
+	clock.SetMockTime(mockTime)
+	assert.Equal(t, mockTime, clock.Now())
+	assert.Equal(t, mockTime.Add(time.Hour), clock.Advance(time.Hour))
+	assert.Equal(t, mockTime.Add(time.Hour), clock.Now())
+
+	clock.SetMockTime(time.Time{})
+	_, ok = clock.MockTime()
+	assert.False(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), clock.Advance(time.Minute), time.Second)
+}
+
+func TestMockTime(t *testing.T) {
+	ctx := context.Background()
+	params := &chaincfg.RegressionNetParams
+	dataContent, err := BlocksToContent([]*wire.MsgBlock{params.GenesisBlock}, 0, params)
+	require.NoError(t, err)
+	dataStore := &DataStore{}
+	require.NoError(t, dataStore.Replace(dataContent))
+	clock := &Clock{}
+	serverHandler := &MockServerHandler{Store: dataStore, Clock: clock}
+	miner := &Miner{Store: dataStore, Clock: clock}
+	mockService := httptest.NewServer(NewHTTPHandler(serverHandler))
+	defer mockService.Close()
+	client_handler := newTestClient(t, mockService.URL)
+
+	const mockTime = 1700000000
+	require.NoError(t, client_handler.SetMockTime(ctx, mockTime))
+	assert.ErrorContains(t, client_handler.SetMockTime(ctx, -1), "Mocktime cannot be negative")
+	_, err = client_handler.MockAdvanceTime(ctx, -1)
+	assert.ErrorContains(t, err, "Time can not go backwards")
+
+	t.Run("MinedBlocks", func(t *testing.T) {
+		// blocks are mined at the mock time, past the median time past
+		hashes, err := miner.Mine(3)
+		require.NoError(t, err)
+		var times []int64
+		for _, hash := range hashes {
+			blockHeader, err := client_handler.GetBlockHeader(ctx, &hash, true)
+			require.NoError(t, err)
+			times = append(times, blockHeader.Time)
+		}
+		assert.Equal(t, []int64{mockTime, mockTime + 1, mockTime + 1}, times)
+	})
+
+	t.Run("Timelock", func(t *testing.T) {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+		tx.TxIn[0].Sequence = 0
+		tx.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}))
+		tx.LockTime = mockTime + 100
+		var raw bytes.Buffer
+		require.NoError(t, tx.Serialize(&raw))
+
+		_, err := miner.SubmitTransaction(hex.EncodeToString(raw.Bytes()))
+		assert.ErrorContains(t, err, "non-final")
+
+		// the median time past moves past the lock time
+		newTime, err := client_handler.MockAdvanceTime(ctx, 200)
+		require.NoError(t, err)
+		assert.Equal(t, int64(mockTime+200), newTime)
+		_, err = miner.Mine(11)
+		require.NoError(t, err)
+		txid, err := miner.SubmitTransaction(hex.EncodeToString(raw.Bytes()))
+		require.NoError(t, err)
+
+		transaction, err := client_handler.GetRawTransaction(ctx, txid, true, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(mockTime+200), transaction.Time)
+	})
+
+	t.Run("TimeOffset", func(t *testing.T) {
+		require.NoError(t, client_handler.SetMockTime(ctx, time.Now().Add(time.Hour).Unix()))
+		networkInfo, err := client_handler.GetNetworkInfo(ctx)
+		require.NoError(t, err)
+		assert.InDelta(t, 3600, networkInfo.TimeOffset, 1)
+
+		require.NoError(t, client_handler.SetMockTime(ctx, 0))
+		networkInfo, err = client_handler.GetNetworkInfo(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), networkInfo.TimeOffset)
+	})
+
+	t.Run("InitialBlockDownload", func(t *testing.T) {
+		clock.SetMockTime(time.Unix(mockTime, 0))
+		syncingStore := NewSyncingStore(dataStore, 5, 1, clock)
+		assert.Equal(t, int32(5), syncingStore.SyncedHeight())
+		clock.Advance(3 * time.Second)
+		assert.Equal(t, int32(8), syncingStore.SyncedHeight())
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		serverHandler := &MockServerHandler{Store: dataStore}
+		assert.ErrorContains(t, serverHandler.SetMockTime(mockTime), "Mock time is not enabled")
+	})
+}
@@ -0,0 +1,199 @@
+package mockserver
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// dataFileExtensions are the files picked up from a data directory.
+var dataFileExtensions = []string{
+	"*.json", "*.json.gz", "*.json.zst",
+	"*.hex", "*.hex.gz", "*.hex.zst",
+}
+
+// ExpandDataPaths resolves data file arguments into an ordered list of files.
+// Every argument is either a file, a directory (all `*.json` and `*.hex` files
+// inside it, optionally `.gz` or `.zst` compressed, sorted by name) or a glob
+// pattern (matches sorted by name). Argument order is preserved and a file
+// listed more than once is only returned the first time it is seen.
+func ExpandDataPaths(patterns ...string) ([]string, error) {
+	if len(patterns) == 0 {
+		return nil, errors.New("no data files given")
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		path = filepath.Clean(path)
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, pattern := range patterns {
+		if info, err := os.Stat(pattern); err == nil {
+			if !info.IsDir() {
+				add(pattern)
+				continue
+			}
+			var matches []string
+			for _, extension := range dataFileExtensions {
+				extensionMatches, err := filepath.Glob(filepath.Join(pattern, extension))
+				if err != nil {
+					return nil, err
+				}
+				matches = append(matches, extensionMatches...)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("directory %s contains no data files", pattern)
+			}
+			sort.Strings(matches)
+			for _, match := range matches {
+				add(match)
+			}
+			continue
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data file pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no data files match %q", pattern)
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && !info.IsDir() {
+				add(match)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// LoadDataFiles reads every file resolved by ExpandDataPaths and merges them
+// into a single DataContent. Files are merged in order with these rules:
+//   - block headers are keyed by hash and transactions by txid; the first file
+//     defining an entry decides its position in the merged content
+//   - an entry repeated with identical content is skipped
+//   - an entry repeated with different content is a conflict; all conflicts
+//     are collected and returned together as an error
+//   - a non-empty `network_info` in a later file replaces the earlier one, so
+//     overlays can override the base chain network info
+func LoadDataFiles(patterns ...string) (DataContent, error) {
+	return DataLoader{}.Load(patterns...)
+}
+
+// DataLoader loads data files. Files are decoded as a stream, entry by entry,
+// and may be gzip or zstd compressed.
+//
+// Besides DataContent json objects, a data file can be a raw block fixture:
+// serialized blocks in hex, either one per line or as a json array of
+// strings. The blocks of a fixture must form a single chain, listed in any
+// order. Its first block is either a genesis block, the child of a block
+// loaded from an earlier file, or a version 2+ block with the BIP34 height in
+// its coinbase. Every header and transaction field is derived from the blocks.
+type DataLoader struct {
+	// Params is the network used to encode the addresses of raw block
+	// fixtures, mainnet when nil.
+	Params *chaincfg.Params
+
+	// Progress, when set, is called from the loading goroutine after every
+	// progressInterval bytes read and once at the end of every file.
+	Progress func(LoadProgress)
+}
+
+func (l DataLoader) params() *chaincfg.Params {
+	if l.Params == nil {
+		return &chaincfg.MainNetParams
+	}
+	return l.Params
+}
+
+// Load reads and merges the data files, see LoadDataFiles.
+func (l DataLoader) Load(patterns ...string) (DataContent, error) {
+	files, err := ExpandDataPaths(patterns...)
+	if err != nil {
+		return DataContent{}, err
+	}
+
+	merger := newDataMerger()
+	for _, file := range files {
+		if err := l.readDataFile(file, merger); err != nil {
+			return DataContent{}, err
+		}
+	}
+
+	if len(merger.conflicts) > 0 {
+		return DataContent{}, errors.Join(merger.conflicts...)
+	}
+	return merger.content, nil
+}
+
+// dataMerger accumulates DataContent from several files, remembering which
+// file every entry came from so conflicts can name both sides.
+type dataMerger struct {
+	content   DataContent
+	conflicts []error
+
+	blockHeaderIndex map[string]int
+	blockHeaderFile  map[string]string
+	transactionIndex map[string]int
+	transactionFile  map[string]string
+}
+
+func newDataMerger() *dataMerger {
+	return &dataMerger{
+		blockHeaderIndex: make(map[string]int),
+		blockHeaderFile:  make(map[string]string),
+		transactionIndex: make(map[string]int),
+		transactionFile:  make(map[string]string),
+	}
+}
+
+func (m *dataMerger) addBlockHeader(file string, blockHeader btcjson.GetBlockHeaderVerboseResult) {
+	index, ok := m.blockHeaderIndex[blockHeader.Hash]
+	if !ok {
+		m.blockHeaderIndex[blockHeader.Hash] = len(m.content.BlockHeaders)
+		m.blockHeaderFile[blockHeader.Hash] = file
+		m.content.BlockHeaders = append(m.content.BlockHeaders, blockHeader)
+		return
+	}
+	if !reflect.DeepEqual(m.content.BlockHeaders[index], blockHeader) {
+		m.conflicts = append(m.conflicts, fmt.Errorf(
+			"block header %s in %s conflicts with %s",
+			blockHeader.Hash, file, m.blockHeaderFile[blockHeader.Hash],
+		))
+	}
+}
+
+func (m *dataMerger) addTransaction(file string, transaction btcjson.TxRawResult) {
+	index, ok := m.transactionIndex[transaction.Txid]
+	if !ok {
+		m.transactionIndex[transaction.Txid] = len(m.content.Transactions)
+		m.transactionFile[transaction.Txid] = file
+		m.content.Transactions = append(m.content.Transactions, transaction)
+		return
+	}
+	if !reflect.DeepEqual(m.content.Transactions[index], transaction) {
+		m.conflicts = append(m.conflicts, fmt.Errorf(
+			"transaction %s in %s conflicts with %s",
+			transaction.Txid, file, m.transactionFile[transaction.Txid],
+		))
+	}
+}
+
+func (m *dataMerger) setNetworkInfo(networkInfo btcjson.GetNetworkInfoResult) {
+	if !reflect.ValueOf(networkInfo).IsZero() {
+		m.content.NetworkInfo = networkInfo
+	}
+}
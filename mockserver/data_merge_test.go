@@ -0,0 +1,184 @@
+package mockserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeDataFile stores dataContent as a json data file in dir.
+func writeDataFile(t *testing.T, dir, name string, dataContent DataContent) string {
+	path := filepath.Join(dir, name)
+	byteValue, err := json.Marshal(dataContent)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, byteValue, 0o600))
+	return path
+}
+
+func TestLoadDataFiles(t *testing.T) {
+	base := DataContent{
+		BlockHeaders: []btcjson.GetBlockHeaderVerboseResult{
+			{Hash: "aa", Height: 1},
+			{Hash: "bb", Height: 2, PreviousHash: "aa"},
+		},
+		Transactions: []btcjson.TxRawResult{
+			{Txid: "t1", BlockHash: "aa"},
+		},
+		NetworkInfo: btcjson.GetNetworkInfoResult{SubVersion: "/base/"},
+	}
+	overlay := DataContent{
+		BlockHeaders: []btcjson.GetBlockHeaderVerboseResult{
+			{Hash: "bb", Height: 2, PreviousHash: "aa"},
+			{Hash: "cc", Height: 3, PreviousHash: "bb"},
+		},
+		Transactions: []btcjson.TxRawResult{
+			{Txid: "t2", BlockHash: "cc"},
+		},
+	}
+
+	t.Run("MergeFilesInOrder", func(t *testing.T) {
+		dir := t.TempDir()
+		basePath := writeDataFile(t, dir, "base.json", base)
+		overlayPath := writeDataFile(t, dir, "overlay.json", overlay)
+
+		dataContent, err := LoadDataFiles(basePath, overlayPath)
+		assert.NoError(t, err)
+
+		assert.Equal(t, []btcjson.GetBlockHeaderVerboseResult{
+			base.BlockHeaders[0], base.BlockHeaders[1], overlay.BlockHeaders[1],
+		}, dataContent.BlockHeaders)
+		assert.Equal(t, []btcjson.TxRawResult{
+			base.Transactions[0], overlay.Transactions[0],
+		}, dataContent.Transactions)
+		// overlay has no network info so the base one is kept
+		assert.Equal(t, "/base/", dataContent.NetworkInfo.SubVersion)
+	})
+
+	t.Run("LaterNetworkInfoWins", func(t *testing.T) {
+		dir := t.TempDir()
+		withInfo := overlay
+		withInfo.NetworkInfo = btcjson.GetNetworkInfoResult{SubVersion: "/overlay/"}
+		basePath := writeDataFile(t, dir, "base.json", base)
+		overlayPath := writeDataFile(t, dir, "overlay.json", withInfo)
+
+		dataContent, err := LoadDataFiles(basePath, overlayPath)
+		assert.NoError(t, err)
+		assert.Equal(t, "/overlay/", dataContent.NetworkInfo.SubVersion)
+	})
+
+	t.Run("DirectoryAndGlob", func(t *testing.T) {
+		dir := t.TempDir()
+		writeDataFile(t, dir, "01-base.json", base)
+		writeDataFile(t, dir, "02-overlay.json", overlay)
+
+		fromDir, err := LoadDataFiles(dir)
+		assert.NoError(t, err)
+		fromGlob, err := LoadDataFiles(filepath.Join(dir, "*-*.json"))
+		assert.NoError(t, err)
+
+		assert.Len(t, fromDir.BlockHeaders, 3)
+		assert.Equal(t, fromDir, fromGlob)
+	})
+
+	t.Run("ConflictingDuplicates", func(t *testing.T) {
+		dir := t.TempDir()
+		conflicting := overlay
+		conflicting.BlockHeaders = []btcjson.GetBlockHeaderVerboseResult{
+			{Hash: "bb", Height: 5},
+		}
+		conflicting.Transactions = []btcjson.TxRawResult{
+			{Txid: "t1", BlockHash: "bb"},
+		}
+		basePath := writeDataFile(t, dir, "base.json", base)
+		conflictingPath := writeDataFile(t, dir, "conflicting.json", conflicting)
+
+		_, err := LoadDataFiles(basePath, conflictingPath)
+		assert.ErrorContains(t, err, "block header bb")
+		assert.ErrorContains(t, err, "transaction t1")
+	})
+
+	t.Run("NoMatches", func(t *testing.T) {
+		_, err := LoadDataFiles(filepath.Join(t.TempDir(), "*.json"))
+		assert.Error(t, err)
+	})
+}
+
+func TestDataLoaderStreaming(t *testing.T) {
+	expected, err := LoadDataFiles("../data/mainnet_oldest_blocks.json")
+	assert.NoError(t, err)
+	assert.Len(t, expected.BlockHeaders, 11)
+
+	plain, err := os.ReadFile("../data/mainnet_oldest_blocks.json")
+	assert.NoError(t, err)
+
+	t.Run("Gzip", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "data.json.gz")
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		_, err := writer.Write(plain)
+		assert.NoError(t, err)
+		assert.NoError(t, writer.Close())
+		assert.NoError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+
+		dataContent, err := LoadDataFiles(path)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, dataContent)
+	})
+
+	t.Run("Zstd", func(t *testing.T) {
+		dir := t.TempDir()
+		encoder, err := zstd.NewWriter(nil)
+		assert.NoError(t, err)
+		compressed := encoder.EncodeAll(plain, nil)
+		assert.NoError(t, encoder.Close())
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "data.json.zst"), compressed, 0o600))
+
+		// compressed files are picked up from directories too
+		dataContent, err := LoadDataFiles(dir)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, dataContent)
+	})
+
+	t.Run("UnknownKeysAndNull", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "data.json")
+		assert.NoError(t, os.WriteFile(path, []byte(
+			`{"utxos": [{"a": [1, 2]}], "block_headers": [{"hash": "aa", "height": 1}], "transactions": null}`,
+		), 0o600))
+
+		dataContent, err := LoadDataFiles(path)
+		assert.NoError(t, err)
+		assert.Equal(t, []btcjson.GetBlockHeaderVerboseResult{{Hash: "aa", Height: 1}}, dataContent.BlockHeaders)
+		assert.Empty(t, dataContent.Transactions)
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "data.json")
+		assert.NoError(t, os.WriteFile(path, []byte(`{"block_headers": [{"hash": "aa"}`), 0o600))
+
+		_, err := LoadDataFiles(path)
+		assert.Error(t, err)
+	})
+
+	t.Run("Progress", func(t *testing.T) {
+		var reports []LoadProgress
+		loader := DataLoader{Progress: func(progress LoadProgress) {
+			reports = append(reports, progress)
+		}}
+		_, err := loader.Load("../data/mainnet_oldest_blocks.json")
+		assert.NoError(t, err)
+
+		last := reports[len(reports)-1]
+		assert.True(t, last.Done)
+		assert.Equal(t, int64(len(plain)), last.BytesRead)
+		assert.Equal(t, int64(len(plain)), last.TotalBytes)
+		assert.Equal(t, 11, last.BlockHeaders)
+		assert.Equal(t, 10, last.Transactions)
+	})
+}
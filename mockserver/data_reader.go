@@ -1,10 +1,7 @@
 package mockserver
 
 import (
-	"encoding/json"
-	"io"
 	"log"
-	"os"
 
 	"github.com/btcsuite/btcd/btcjson"
 )
@@ -15,50 +12,43 @@ type DataContent struct {
 	NetworkInfo  btcjson.GetNetworkInfoResult          `json:"network_info"`
 }
 
-type DataStore struct {
-	DataContent DataContent
-
-	BlockHeaderMap          map[int32]btcjson.GetBlockHeaderVerboseResult
-	BlockHeaderBlockHashMap map[string]btcjson.GetBlockHeaderVerboseResult
-	TransactionMap          map[string]btcjson.TxRawResult
-}
-
+// ReadJson populates the store from a single json data file.
 func (d *DataStore) ReadJson(jsonFilePath string) {
-	jsonFile, err := os.Open(jsonFilePath)
-	if err != nil {
-		log.Fatalf("Failed to open file: %v", err)
-	}
-	defer jsonFile.Close()
+	d.ReadJsonFiles(jsonFilePath)
+}
 
-	// Read the file contents
-	byteValue, err := io.ReadAll(jsonFile)
+// ReadJsonFiles populates the store from several json data files, directories
+// or glob patterns, merged with the precedence rules of LoadDataFiles. Raw
+// block fixtures are decoded for the network of Params. The loading progress
+// of large files is logged.
+func (d *DataStore) ReadJsonFiles(patterns ...string) {
+	fileStates := statDataFiles(patterns)
+	dataContent, err := DataLoader{Params: d.Params, Progress: LogProgress()}.Load(patterns...)
 	if err != nil {
-		log.Fatalf("Failed to read file: %v", err)
+		log.Fatalf("Failed to load data files: %v", err)
 	}
 
-	// Unmarshal the JSON data into the struct
-	var dataContent DataContent
-	if err := json.Unmarshal(byteValue, &dataContent); err != nil {
-		log.Fatalf("Failed to unmarshal JSON: %v", err)
-	}
-
-	// populate the BlockHeaderMap from dataContent
-	d.BlockHeaderMap = make(map[int32]btcjson.GetBlockHeaderVerboseResult)
-	for _, blockHeader := range dataContent.BlockHeaders {
-		d.BlockHeaderMap[blockHeader.Height] = blockHeader
-	}
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+	d.dataFilePaths = patterns
+	d.fileStates = fileStates
+	d.state.Store(newChainState(dataContent))
+}
 
-	// populate the BlockHeaderBlockHashMap from dataContent
-	d.BlockHeaderBlockHashMap = make(map[string]btcjson.GetBlockHeaderVerboseResult)
-	for _, blockHeader := range dataContent.BlockHeaders {
-		d.BlockHeaderBlockHashMap[blockHeader.Hash] = blockHeader
-	}
+// Reload reads the data files again and swaps the content in place. On error
+// the current content is kept.
+func (d *DataStore) Reload() (DataDiff, error) {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
 
-	// populate the TransactionMap from dataContent
-	d.TransactionMap = make(map[string]btcjson.TxRawResult)
-	for _, transaction := range dataContent.Transactions {
-		d.TransactionMap[transaction.Txid] = transaction
+	fileStates := statDataFiles(d.dataFilePaths)
+	dataContent, err := DataLoader{Params: d.Params}.Load(d.dataFilePaths...)
+	if err != nil {
+		return DataDiff{}, err
 	}
 
-	d.DataContent = dataContent
+	diff := diffDataContent(d.current().Content(), dataContent)
+	d.fileStates = fileStates
+	d.state.Store(newChainState(dataContent))
+	return diff, nil
 }
@@ -0,0 +1,272 @@
+package mockserver
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/klauspost/compress/zstd"
+	"github.com/rs/zerolog/log"
+)
+
+// progressInterval is the number of bytes read between two progress reports.
+const progressInterval = 64 << 20
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// LoadProgress describes how far the loader got in the current file.
+type LoadProgress struct {
+	File string
+	// BytesRead and TotalBytes are counted on disk, before decompression
+	BytesRead  int64
+	TotalBytes int64
+	// BlockHeaders and Transactions are the number of entries decoded so far
+	// from all files
+	BlockHeaders int
+	Transactions int
+	Done         bool
+}
+
+// LogProgress is a DataLoader progress callback for large files, logging at
+// most once every few seconds and at the end of every file. Files smaller
+// than progressInterval are not logged.
+func LogProgress() func(LoadProgress) {
+	var lastLog time.Time
+	return func(progress LoadProgress) {
+		if progress.TotalBytes < progressInterval {
+			return
+		}
+		if !progress.Done && time.Since(lastLog) < 5*time.Second {
+			return
+		}
+		lastLog = time.Now()
+
+		percent := 100.0
+		if progress.TotalBytes > 0 {
+			percent = float64(progress.BytesRead) / float64(progress.TotalBytes) * 100
+		}
+		log.Info().Msgf("Loading %s: %.1f%% (%d blocks, %d transactions)",
+			progress.File, percent, progress.BlockHeaders, progress.Transactions)
+	}
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// decompress wraps reader in a gzip or zstd decoder when the stream starts
+// with the matching magic bytes, otherwise it is returned as is.
+func decompress(reader io.Reader) (io.Reader, func(), error) {
+	buffered := bufio.NewReader(reader)
+	magic, err := buffered.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, nil, err
+		}
+		return gzipReader, func() { gzipReader.Close() }, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zstdReader, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zstdReader, zstdReader.Close, nil
+	default:
+		return buffered, func() {}, nil
+	}
+}
+
+// readDataFile decodes a json data file entry by entry into the merger, so
+// the file is never held in memory as a whole. Files that do not hold a json
+// object are raw block fixtures, which are decoded and converted at once.
+func (l DataLoader) readDataFile(jsonFilePath string, merger *dataMerger) error {
+	jsonFile, err := os.Open(jsonFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer jsonFile.Close()
+
+	info, err := jsonFile.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", jsonFilePath, err)
+	}
+
+	counter := &countingReader{reader: jsonFile}
+	reader, closeReader, err := decompress(counter)
+	if err != nil {
+		return fmt.Errorf("failed to decompress file %s: %w", jsonFilePath, err)
+	}
+	defer closeReader()
+
+	var nextReport int64
+	report := func(done bool) {
+		if l.Progress == nil || (!done && counter.count < nextReport) {
+			return
+		}
+		nextReport = counter.count + progressInterval
+		l.Progress(LoadProgress{
+			File:         jsonFilePath,
+			BytesRead:    counter.count,
+			TotalBytes:   info.Size(),
+			BlockHeaders: len(merger.content.BlockHeaders),
+			Transactions: len(merger.content.Transactions),
+			Done:         done,
+		})
+	}
+
+	buffered := bufio.NewReader(reader)
+	first, err := peekFirstByte(buffered)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", jsonFilePath, err)
+	}
+	if first != '{' {
+		// raw block fixtures, either a json array or one block per line
+		var blocks []*wire.MsgBlock
+		if first == '[' {
+			blocks, err = readBlockHexArray(buffered)
+		} else {
+			blocks, err = readBlockHexLines(buffered)
+		}
+		if err == nil {
+			err = l.addBlocks(jsonFilePath, blocks, merger)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read blocks %s: %w", jsonFilePath, err)
+		}
+		report(true)
+		return nil
+	}
+
+	decoder := json.NewDecoder(buffered)
+	err = decodeDataContent(decoder, dataContentVisitor{
+		blockHeader: func(blockHeader btcjson.GetBlockHeaderVerboseResult) {
+			merger.addBlockHeader(jsonFilePath, blockHeader)
+			report(false)
+		},
+		transaction: func(transaction btcjson.TxRawResult) {
+			merger.addTransaction(jsonFilePath, transaction)
+			report(false)
+		},
+		networkInfo: merger.setNetworkInfo,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal JSON %s: %w", jsonFilePath, err)
+	}
+
+	report(true)
+	return nil
+}
+
+// dataContentVisitor receives the entries of a data file as they are decoded.
+type dataContentVisitor struct {
+	blockHeader func(btcjson.GetBlockHeaderVerboseResult)
+	transaction func(btcjson.TxRawResult)
+	networkInfo func(btcjson.GetNetworkInfoResult)
+}
+
+// decodeDataContent walks a DataContent json object, decoding the array
+// entries one at a time. Unknown keys are skipped.
+func decodeDataContent(decoder *json.Decoder, visitor dataContentVisitor) error {
+	if err := expectDelim(decoder, '{'); err != nil {
+		return err
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+
+		switch key {
+		case "block_headers":
+			err = decodeArray(decoder, func() error {
+				var blockHeader btcjson.GetBlockHeaderVerboseResult
+				if err := decoder.Decode(&blockHeader); err != nil {
+					return err
+				}
+				visitor.blockHeader(blockHeader)
+				return nil
+			})
+		case "transactions":
+			err = decodeArray(decoder, func() error {
+				var transaction btcjson.TxRawResult
+				if err := decoder.Decode(&transaction); err != nil {
+					return err
+				}
+				visitor.transaction(transaction)
+				return nil
+			})
+		case "network_info":
+			var networkInfo btcjson.GetNetworkInfoResult
+			if err = decoder.Decode(&networkInfo); err == nil {
+				visitor.networkInfo(networkInfo)
+			}
+		default:
+			var skipped json.RawMessage
+			err = decoder.Decode(&skipped)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	return expectDelim(decoder, '}')
+}
+
+// decodeArray calls decodeElement for every element of a json array. A null
+// value is treated as an empty array.
+func decodeArray(decoder *json.Decoder, decodeElement func() error) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected array, got %v", token)
+	}
+
+	for decoder.More() {
+		if err := decodeElement(); err != nil {
+			return err
+		}
+	}
+
+	return expectDelim(decoder, ']')
+}
+
+func expectDelim(decoder *json.Decoder, expected json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != expected {
+		return fmt.Errorf("expected %v, got %v", expected, token)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package mockserver
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/rs/zerolog/log"
+
+	"github.com/gonative-cc/btc-mock-node/client"
+)
+
+// DataDiff summarizes the changes between two versions of the data content,
+// shared with the client package.
+type DataDiff = client.DataDiff
+
+// diffDataContent compares the old and new content entry by entry.
+func diffDataContent(oldContent, newContent DataContent) DataDiff {
+	var diff DataDiff
+
+	oldBlockHeaders := make(map[string]btcjson.GetBlockHeaderVerboseResult)
+	for _, blockHeader := range oldContent.BlockHeaders {
+		oldBlockHeaders[blockHeader.Hash] = blockHeader
+	}
+	for _, blockHeader := range newContent.BlockHeaders {
+		oldBlockHeader, ok := oldBlockHeaders[blockHeader.Hash]
+		if !ok {
+			diff.AddedBlocks = append(diff.AddedBlocks, blockHeader.Hash)
+		} else if !reflect.DeepEqual(oldBlockHeader, blockHeader) {
+			diff.ChangedBlocks = append(diff.ChangedBlocks, blockHeader.Hash)
+		}
+		delete(oldBlockHeaders, blockHeader.Hash)
+	}
+	for _, blockHeader := range oldContent.BlockHeaders {
+		if _, ok := oldBlockHeaders[blockHeader.Hash]; ok {
+			diff.RemovedBlocks = append(diff.RemovedBlocks, blockHeader.Hash)
+		}
+	}
+
+	oldTransactions := make(map[string]btcjson.TxRawResult)
+	for _, transaction := range oldContent.Transactions {
+		oldTransactions[transaction.Txid] = transaction
+	}
+	for _, transaction := range newContent.Transactions {
+		oldTransaction, ok := oldTransactions[transaction.Txid]
+		if !ok {
+			diff.AddedTransactions = append(diff.AddedTransactions, transaction.Txid)
+		} else if !reflect.DeepEqual(oldTransaction, transaction) {
+			diff.ChangedTransactions = append(diff.ChangedTransactions, transaction.Txid)
+		}
+		delete(oldTransactions, transaction.Txid)
+	}
+	for _, transaction := range oldContent.Transactions {
+		if _, ok := oldTransactions[transaction.Txid]; ok {
+			diff.RemovedTransactions = append(diff.RemovedTransactions, transaction.Txid)
+		}
+	}
+
+	diff.NetworkInfoChanged = !reflect.DeepEqual(oldContent.NetworkInfo, newContent.NetworkInfo)
+	return diff
+}
+
+// fileState is the part of a file's metadata used to detect changes.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// statDataFiles resolves the data file patterns and stats every file, so new,
+// removed and modified files all show up as a different result.
+func statDataFiles(patterns []string) map[string]fileState {
+	states := make(map[string]fileState)
+	files, err := ExpandDataPaths(patterns...)
+	if err != nil {
+		return states
+	}
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			states[file] = fileState{size: info.Size(), modTime: info.ModTime()}
+		}
+	}
+	return states
+}
+
+// dataFilesChanged returns the data file states and reports whether any data
+// file was added, removed or modified since the last load attempt.
+func (d *DataStore) dataFilesChanged() (map[string]fileState, bool) {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+
+	fileStates := statDataFiles(d.dataFilePaths)
+	return fileStates, !reflect.DeepEqual(fileStates, d.fileStates)
+}
+
+// WatchDataFiles polls the data files every interval and reloads the store
+// when any of them was added, removed or modified. A failed reload is only
+// retried once the files change again. It blocks until ctx is done.
+func (d *DataStore) WatchDataFiles(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		fileStates, changed := d.dataFilesChanged()
+		if !changed {
+			continue
+		}
+
+		diff, err := d.Reload()
+		if err != nil {
+			// the broken files are not reloaded on every tick
+			d.writeMu.Lock()
+			d.fileStates = fileStates
+			d.writeMu.Unlock()
+			log.Error().Err(err).Msg("Failed to reload data files, keeping previous data")
+			continue
+		}
+		log.Info().Msgf("Reloaded data files: %s", diff)
+	}
+}
@@ -0,0 +1,117 @@
+package mockserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	initial := DataContent{
+		BlockHeaders: []btcjson.GetBlockHeaderVerboseResult{{Hash: "aa", Height: 1}},
+		Transactions: []btcjson.TxRawResult{{Txid: "t1", BlockHash: "aa"}},
+	}
+	path := writeDataFile(t, dir, "data.json", initial)
+
+	serverHandler := NewMockServerHandler(path)
+
+	updated := DataContent{
+		BlockHeaders: []btcjson.GetBlockHeaderVerboseResult{
+			{Hash: "aa", Height: 1, NextHash: "bb"},
+			{Hash: "bb", Height: 2, PreviousHash: "aa"},
+		},
+	}
+	writeDataFile(t, dir, "data.json", updated)
+
+	diff, err := serverHandler.MockReload()
+	assert.NoError(t, err)
+	assert.Equal(t, &DataDiff{
+		AddedBlocks:         []string{"bb"},
+		ChangedBlocks:       []string{"aa"},
+		RemovedTransactions: []string{"t1"},
+	}, diff)
+
+	blockCount, err := serverHandler.GetBlockCount()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), blockCount)
+
+	t.Run("KeepDataOnError", func(t *testing.T) {
+		writeDataFile(t, dir, "data.json", DataContent{
+			BlockHeaders: []btcjson.GetBlockHeaderVerboseResult{
+				{Hash: "bb", Height: 2},
+				{Hash: "bb", Height: 7},
+			},
+		})
+		_, err := serverHandler.MockReload()
+		assert.Error(t, err)
+
+		blockCount, err := serverHandler.GetBlockCount()
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), blockCount)
+	})
+}
+
+func TestWatchDataFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeDataFile(t, dir, "01-base.json", DataContent{
+		BlockHeaders: []btcjson.GetBlockHeaderVerboseResult{{Hash: "aa", Height: 1}},
+	})
+
+	serverHandler := NewMockServerHandler(dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go serverHandler.Store.(*DataStore).WatchDataFiles(ctx, 10*time.Millisecond)
+
+	// a new file in the watched directory is picked up
+	writeDataFile(t, dir, "02-overlay.json", DataContent{
+		BlockHeaders: []btcjson.GetBlockHeaderVerboseResult{{Hash: "bb", Height: 2}},
+	})
+
+	assert.Eventually(t, func() bool {
+		blockCount, err := serverHandler.GetBlockCount()
+		return err == nil && blockCount == 2
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+// TestWatchDataFilesFailedReload checks a failed reload is only retried once
+// the data files change again.
+func TestWatchDataFilesFailedReload(t *testing.T) {
+	dir := t.TempDir()
+	writeDataFile(t, dir, "01-base.json", DataContent{
+		BlockHeaders: []btcjson.GetBlockHeaderVerboseResult{{Hash: "aa", Height: 1}},
+	})
+
+	serverHandler := NewMockServerHandler(dir)
+	dataStore := serverHandler.Store.(*DataStore)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dataStore.WatchDataFiles(ctx, 10*time.Millisecond)
+
+	writeDataFile(t, dir, "02-overlay.json", DataContent{
+		BlockHeaders: []btcjson.GetBlockHeaderVerboseResult{
+			{Hash: "bb", Height: 2},
+			{Hash: "bb", Height: 7},
+		},
+	})
+	assert.Eventually(t, func() bool {
+		_, changed := dataStore.dataFilesChanged()
+		return !changed
+	}, 2*time.Second, 10*time.Millisecond)
+	blockCount, err := serverHandler.GetBlockCount()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), blockCount)
+
+	writeDataFile(t, dir, "02-overlay.json", DataContent{
+		BlockHeaders: []btcjson.GetBlockHeaderVerboseResult{{Hash: "bb", Height: 2}},
+	})
+	assert.Eventually(t, func() bool {
+		blockCount, err := serverHandler.GetBlockCount()
+		return err == nil && blockCount == 2
+	}, 2*time.Second, 10*time.Millisecond)
+}
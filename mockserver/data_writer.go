@@ -0,0 +1,113 @@
+package mockserver
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/gonative-cc/btc-mock-node/client"
+)
+
+// DumpStateResult is the result of mock_dumpstate, shared with the client
+// package.
+type DumpStateResult = client.DumpStateResult
+
+// WriteDataFile stores dataContent as a data file that LoadDataFiles reads
+// back unchanged. The file is gzip or zstd compressed when path ends in `.gz`
+// or `.zst`. It is written to a temporary file first and renamed into place,
+// so a watcher never sees it half written.
+func WriteDataFile(path string, dataContent DataContent) error {
+	return writeJSONFile(path, dataContent)
+}
+
+// writeJSONFile stores value as indented json, see WriteDataFile.
+func writeJSONFile(path string, value any) (err error) {
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmpFile.Close()
+			os.Remove(tmpFile.Name())
+		}
+	}()
+
+	writer, closeWriter, err := compress(tmpFile, path)
+	if err != nil {
+		return err
+	}
+	// indented like the fixtures in data/, so dumps diff well under review
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(value); err != nil {
+		return err
+	}
+	if err := closeWriter(); err != nil {
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), path)
+}
+
+// compress wraps writer in the encoder matching the extension of path.
+func compress(writer io.Writer, path string) (io.Writer, func() error, error) {
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		gzipWriter := gzip.NewWriter(writer)
+		return gzipWriter, gzipWriter.Close, nil
+	case strings.HasSuffix(path, ".zst"):
+		zstdWriter, err := zstd.NewWriter(writer)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zstdWriter, zstdWriter.Close, nil
+	default:
+		return writer, func() error { return nil }, nil
+	}
+}
+
+// WriteJSON stores the current content of the store as a data file, with
+// the derived confirmations, see WriteDataFile.
+func (d *DataStore) WriteJSON(path string) error {
+	return WriteDataFile(path, d.current().Content())
+}
+
+// MockDumpState is an admin method that writes the current chain state to a
+// data file, which can be loaded again as a fixture.
+func (h *MockServerHandler) MockDumpState(path string) (*DumpStateResult, error) {
+	if path == "" {
+		return nil, &RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Missing file path",
+		}
+	}
+
+	view, err := h.view()
+	if err != nil {
+		return nil, err
+	}
+	defer view.Release()
+
+	dataContent := view.Content()
+	if err := WriteDataFile(path, dataContent); err != nil {
+		return nil, &RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: fmt.Sprintf("Unable to write data file: %v", err),
+		}
+	}
+	return &DumpStateResult{
+		Path:         path,
+		BlockHeaders: len(dataContent.BlockHeaders),
+		Transactions: len(dataContent.Transactions),
+	}, nil
+}
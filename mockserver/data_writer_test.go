@@ -0,0 +1,72 @@
+package mockserver
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteDataFile(t *testing.T) {
+	expected, err := LoadDataFiles("../data/mainnet_oldest_blocks.json")
+	require.NoError(t, err)
+
+	for _, name := range []string{"dump.json", "dump.json.gz", "dump.json.zst"} {
+		t.Run(name, func(t *testing.T) {
+			dataStore := &DataStore{}
+			require.NoError(t, dataStore.Replace(expected))
+
+			path := filepath.Join(t.TempDir(), name)
+			require.NoError(t, dataStore.WriteJSON(path))
+
+			dataContent, err := LoadDataFiles(path)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, dataContent)
+		})
+	}
+
+	t.Run("Mined", func(t *testing.T) {
+		dataStore := &DataStore{}
+		require.NoError(t, dataStore.Replace(expected))
+		_, err := (&Miner{Store: dataStore}).Mine(2)
+		require.NoError(t, err)
+
+		// the confirmations of the blocks below the mined ones are counted
+		// from the new best block
+		path := filepath.Join(t.TempDir(), "dump.json")
+		require.NoError(t, dataStore.WriteJSON(path))
+		dataContent, err := LoadDataFiles(path)
+		assert.NoError(t, err)
+		assert.Equal(t, dataStore.current().Content(), dataContent)
+		assert.Equal(t, expected.BlockHeaders[0].Confirmations+2, dataContent.BlockHeaders[0].Confirmations)
+	})
+}
+
+func TestMockDumpState(t *testing.T) {
+	serverHandler := NewMockServerHandler("../data/mainnet_oldest_blocks.json")
+	// a block added during the test is part of the dump
+	require.NoError(t, serverHandler.Store.Update(func(dataContent *DataContent) error {
+		appendTestBlock(dataContent)
+		return nil
+	}))
+
+	path := filepath.Join(t.TempDir(), "dump.json")
+	result, err := serverHandler.MockDumpState(path)
+	require.NoError(t, err)
+	assert.Equal(t, &DumpStateResult{Path: path, BlockHeaders: 12, Transactions: 13}, result)
+
+	restored := NewMockServerHandler(path)
+	expectedHash, err := serverHandler.GetBestBlockHash()
+	require.NoError(t, err)
+	bestBlockHash, err := restored.GetBestBlockHash()
+	assert.NoError(t, err)
+	assert.Equal(t, expectedHash, bestBlockHash)
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := serverHandler.MockDumpState("")
+		assert.Error(t, err)
+		_, err = serverHandler.MockDumpState(filepath.Join(t.TempDir(), "missing", "dump.json"))
+		assert.Error(t, err)
+	})
+}
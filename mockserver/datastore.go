@@ -0,0 +1,564 @@
+package mockserver
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"math/big"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// ChainStore is the storage backend behind the handler. Implementations
+// must be safe for concurrent use.
+type ChainStore interface {
+	// View returns a point-in-time view of the chain. The view must be
+	// released once the caller is done with it.
+	View() (ChainView, error)
+	// Update applies fn to the current content and stores the result
+	// atomically. fn gets the confirmations counted from the current best
+	// block and the result is stored as counted from its own best block.
+	// Stores may read and compare the whole content, Append is the
+	// incremental write. If fn returns an error nothing is stored.
+	Update(fn func(dataContent *DataContent) error) error
+	// Append applies fn to a view of the current content and appends the
+	// blocks and transactions fn returns atomically. The first block
+	// becomes the next block of its parent, and transactions already
+	// stored, such as the mempool transactions confirmed by a new block,
+	// move to the end. Unlike Update only the new entries are written. The
+	// view is only valid during fn. If fn returns an error nothing is
+	// stored.
+	Append(fn func(view ChainView) (DataContent, error)) error
+	// Replace swaps the whole content of the store.
+	Replace(dataContent DataContent) error
+	// Close releases the resources held by the store.
+	Close() error
+}
+
+// ChainView is a read-only, point-in-time view of the chain data. A view
+// never changes after it was taken, so an RPC handler should take a single
+// view and answer the whole request from it.
+type ChainView interface {
+	// BlockHeaderByHash returns the header of the block with the given hash.
+	BlockHeaderByHash(hash string) (btcjson.GetBlockHeaderVerboseResult, bool)
+	// BlockHeaderByHeight returns the header of the block at height.
+	BlockHeaderByHeight(height int32) (btcjson.GetBlockHeaderVerboseResult, bool)
+	// BestBlockHeader returns the header of the block with the highest height.
+	BestBlockHeader() (btcjson.GetBlockHeaderVerboseResult, bool)
+	// Transaction returns the transaction with the given txid.
+	Transaction(txid string) (btcjson.TxRawResult, bool)
+	// BlockTransactions returns the transactions of a block in block order.
+	BlockTransactions(blockHash string) []btcjson.TxRawResult
+	// Mempool returns the transactions without a block, in the order they
+	// were stored.
+	Mempool() []btcjson.TxRawResult
+	// ChainWork returns the total work of the chain up to the block with the
+	// given hash, see headerChainWork.
+	ChainWork(blockHash string) (*big.Int, bool)
+	// NetworkInfo returns the network info served by getnetworkinfo.
+	NetworkInfo() btcjson.GetNetworkInfoResult
+	// Content returns the data content the view was built from. It must not
+	// be modified.
+	Content() DataContent
+	// Release frees the resources held by the view.
+	Release()
+}
+
+// confirmationCount derives the confirmations of the stored entries from the
+// best block at read time, so appending blocks does not rewrite the entries
+// below them. The stored confirmations were counted when the best block was
+// at storedHeight: the entries up to it get the blocks appended since, the
+// blocks above it count from the best block at bestHeight, like in bitcoind.
+// Stale blocks, their transactions and the mempool keep the stored counts.
+type confirmationCount struct {
+	storedHeight int32
+	bestHeight   int32
+}
+
+// current reports whether the stored confirmations are the derived ones.
+func (c confirmationCount) current() bool {
+	return c.storedHeight == c.bestHeight
+}
+
+func (c confirmationCount) blockHeader(
+	blockHeader btcjson.GetBlockHeaderVerboseResult,
+) btcjson.GetBlockHeaderVerboseResult {
+	switch {
+	case blockHeader.Confirmations < 0:
+	case blockHeader.Height > c.storedHeight:
+		blockHeader.Confirmations = int64(c.bestHeight-blockHeader.Height) + 1
+	case blockHeader.Confirmations > 0:
+		blockHeader.Confirmations += int64(c.bestHeight - c.storedHeight)
+	}
+	return blockHeader
+}
+
+// transaction derives the confirmations of transaction, given the stored
+// header of its block when it is loaded.
+func (c confirmationCount) transaction(
+	transaction btcjson.TxRawResult,
+	blockHeader btcjson.GetBlockHeaderVerboseResult,
+	ok bool,
+) btcjson.TxRawResult {
+	switch {
+	case transaction.BlockHash == "" || (ok && blockHeader.Confirmations < 0):
+	case ok && blockHeader.Height > c.storedHeight:
+		transaction.Confirmations = uint64(c.bestHeight-blockHeader.Height) + 1
+	case transaction.Confirmations > 0:
+		transaction.Confirmations += uint64(c.bestHeight - c.storedHeight)
+	}
+	return transaction
+}
+
+// chainState is an immutable ChainView over a DataContent. All indexes are
+// built once in newChainState, or carried over from the previous state by
+// next, and point into the content instead of copying the entries, so
+// lookups never scan the content and the content is held in memory only
+// once.
+type chainState struct {
+	content DataContent
+	// confirmationsHeight is the height of the best block when the
+	// confirmations of the content were counted, see confirmationCount
+	confirmationsHeight int32
+
+	blockHeaderMap          map[int32]int
+	blockHeaderBlockHashMap map[string]int
+	transactionMap          map[string]int
+	// blockTransactionsMap holds the indexes into content.Transactions of
+	// the transactions of every block, in block order, and of the mempool
+	// under the empty hash
+	blockTransactionsMap map[string][]int
+	// bestBlockHeader is the index of the header with the highest height, -1
+	// for an empty chain
+	bestBlockHeader int
+	// chainWork holds the total work of the chain up to every header, by
+	// index
+	chainWork []*big.Int
+}
+
+var _ ChainView = (*chainState)(nil)
+
+// newChainState builds the indexes for dataContent. dataContent is owned by
+// the state afterwards.
+func newChainState(dataContent DataContent) *chainState {
+	// populate the blockHeaderMap and blockHeaderBlockHashMap from dataContent
+	blockHeaderMap := make(map[int32]int, len(dataContent.BlockHeaders))
+	blockHeaderBlockHashMap := make(map[string]int, len(dataContent.BlockHeaders))
+	for index, blockHeader := range dataContent.BlockHeaders {
+		blockHeaderMap[blockHeader.Height] = index
+		blockHeaderBlockHashMap[blockHeader.Hash] = index
+	}
+
+	// populate the transactionMap and blockTransactionsMap from dataContent
+	transactionMap := make(map[string]int, len(dataContent.Transactions))
+	blockTransactionsMap := make(map[string][]int)
+	for index, transaction := range dataContent.Transactions {
+		transactionMap[transaction.Txid] = index
+		blockTransactionsMap[transaction.BlockHash] = append(blockTransactionsMap[transaction.BlockHash], index)
+	}
+
+	// find the highest block height, forks at the same height resolve like
+	// BlockHeaderByHeight
+	bestBlockHeader := -1
+	if len(dataContent.BlockHeaders) > 0 {
+		maxHeight := dataContent.BlockHeaders[0].Height
+		for _, blockHeader := range dataContent.BlockHeaders {
+			maxHeight = max(maxHeight, blockHeader.Height)
+		}
+		bestBlockHeader = blockHeaderMap[maxHeight]
+	}
+
+	state := &chainState{
+		content:                 dataContent,
+		blockHeaderMap:          blockHeaderMap,
+		blockHeaderBlockHashMap: blockHeaderBlockHashMap,
+		transactionMap:          transactionMap,
+		blockTransactionsMap:    blockTransactionsMap,
+		bestBlockHeader:         bestBlockHeader,
+		chainWork:               make([]*big.Int, len(dataContent.BlockHeaders)),
+	}
+	state.confirmationsHeight = state.bestHeight()
+	state.addChainWork(0)
+	return state
+}
+
+// bestHeight returns the height of the best block, -1 for an empty chain.
+func (s *chainState) bestHeight() int32 {
+	if s.bestBlockHeader < 0 {
+		return -1
+	}
+	return s.content.BlockHeaders[s.bestBlockHeader].Height
+}
+
+func (s *chainState) count() confirmationCount {
+	return confirmationCount{storedHeight: s.confirmationsHeight, bestHeight: s.bestHeight()}
+}
+
+// countTransaction derives the confirmations of a stored transaction.
+func (s *chainState) countTransaction(count confirmationCount, transaction btcjson.TxRawResult) btcjson.TxRawResult {
+	if count.current() {
+		return transaction
+	}
+	var blockHeader btcjson.GetBlockHeaderVerboseResult
+	index, ok := s.blockHeaderBlockHashMap[transaction.BlockHash]
+	if ok {
+		blockHeader = s.content.BlockHeaders[index]
+	}
+	return count.transaction(transaction, blockHeader, ok)
+}
+
+// addChainWork computes the chain work of the headers from index from on.
+// Parents are always one height below their blocks, so going up by height
+// computes every parent before its blocks.
+func (s *chainState) addChainWork(from int) {
+	indexes := make([]int, 0, len(s.content.BlockHeaders)-from)
+	for index := from; index < len(s.content.BlockHeaders); index++ {
+		indexes = append(indexes, index)
+	}
+	slices.SortStableFunc(indexes, func(a, b int) int {
+		return cmp.Compare(s.content.BlockHeaders[a].Height, s.content.BlockHeaders[b].Height)
+	})
+	for _, index := range indexes {
+		blockHeader := s.content.BlockHeaders[index]
+		var parentWork *big.Int
+		if parent, ok := s.blockHeaderBlockHashMap[blockHeader.PreviousHash]; ok {
+			parentWork = s.chainWork[parent]
+		}
+		s.chainWork[index] = headerChainWork(blockHeader, parentWork)
+	}
+}
+
+// headerChainWork returns the total work of the chain up to blockHeader,
+// given the work up to its parent, nil when the parent is not loaded. Data
+// files do not need to start at the genesis block, the blocks before the
+// first loaded one count with its difficulty.
+func headerChainWork(blockHeader btcjson.GetBlockHeaderVerboseResult, parentWork *big.Int) *big.Int {
+	work := new(big.Int)
+	if bits, err := parseBits(blockHeader); err == nil {
+		work = blockchain.CalcWork(bits)
+	}
+	if parentWork == nil {
+		return work.Mul(work, big.NewInt(int64(blockHeader.Height)+1))
+	}
+	return work.Add(work, parentWork)
+}
+
+// next returns the state of dataContent, an update of the content of s
+// whose confirmations are counted from its best block. The indexes of s are
+// copied and only the entries from the first one which moved are indexed
+// again, so appending blocks and transactions does not hash the whole chain.
+// Headers which were removed or moved rebuild all indexes.
+func (s *chainState) next(dataContent DataContent) *chainState {
+	headersMoved := firstMoved(s.content.BlockHeaders, dataContent.BlockHeaders,
+		func(a, b btcjson.GetBlockHeaderVerboseResult) bool {
+			return a.Hash == b.Hash && a.Height == b.Height
+		})
+	if headersMoved < len(s.content.BlockHeaders) {
+		return newChainState(dataContent)
+	}
+
+	state := &chainState{
+		content:                 dataContent,
+		blockHeaderMap:          maps.Clone(s.blockHeaderMap),
+		blockHeaderBlockHashMap: maps.Clone(s.blockHeaderBlockHashMap),
+		transactionMap:          maps.Clone(s.transactionMap),
+		blockTransactionsMap:    maps.Clone(s.blockTransactionsMap),
+		bestBlockHeader:         s.bestBlockHeader,
+		// the work of the new headers is appended to a copy
+		chainWork: append(slices.Clip(s.chainWork), make([]*big.Int, len(dataContent.BlockHeaders)-headersMoved)...),
+	}
+	for index := headersMoved; index < len(dataContent.BlockHeaders); index++ {
+		blockHeader := dataContent.BlockHeaders[index]
+		state.blockHeaderMap[blockHeader.Height] = index
+		state.blockHeaderBlockHashMap[blockHeader.Hash] = index
+		// the last header wins a height, like in newChainState
+		if state.bestBlockHeader < 0 || blockHeader.Height >= dataContent.BlockHeaders[state.bestBlockHeader].Height {
+			state.bestBlockHeader = index
+		}
+	}
+	state.confirmationsHeight = state.bestHeight()
+	state.addChainWork(headersMoved)
+
+	txsMoved := firstMoved(s.content.Transactions, dataContent.Transactions, func(a, b btcjson.TxRawResult) bool {
+		return a.Txid == b.Txid && a.BlockHash == b.BlockHash
+	})
+	movedBlocks := make(map[string]bool)
+	for _, transaction := range s.content.Transactions[txsMoved:] {
+		delete(state.transactionMap, transaction.Txid)
+		movedBlocks[transaction.BlockHash] = true
+	}
+	for blockHash := range movedBlocks {
+		// the slices are shared with s, so they are never modified in place
+		kept := slices.DeleteFunc(slices.Clone(state.blockTransactionsMap[blockHash]), func(index int) bool {
+			return index >= txsMoved
+		})
+		if len(kept) == 0 {
+			delete(state.blockTransactionsMap, blockHash)
+		} else {
+			state.blockTransactionsMap[blockHash] = kept
+		}
+	}
+	added := make(map[string][]int)
+	for index := txsMoved; index < len(dataContent.Transactions); index++ {
+		transaction := dataContent.Transactions[index]
+		state.transactionMap[transaction.Txid] = index
+		added[transaction.BlockHash] = append(added[transaction.BlockHash], index)
+	}
+	for blockHash, indexes := range added {
+		state.blockTransactionsMap[blockHash] = append(slices.Clip(state.blockTransactionsMap[blockHash]), indexes...)
+	}
+	return state
+}
+
+// appended returns the state of s with the blocks and transactions of added
+// appended, see ChainStore.Append. The stored confirmations are kept, they
+// count from the best block of s.
+func (s *chainState) appended(added DataContent) *chainState {
+	state := s.next(appendContent(s.content, added))
+	state.confirmationsHeight = s.confirmationsHeight
+	return state
+}
+
+// appendContent returns dataContent with the blocks and transactions of added
+// appended, see ChainStore.Append. dataContent is not modified.
+func appendContent(dataContent, added DataContent) DataContent {
+	blockHeaders := slices.Clone(dataContent.BlockHeaders)
+	if len(added.BlockHeaders) > 0 {
+		// the parent is usually one of the last headers
+		for i := len(blockHeaders) - 1; i >= 0; i-- {
+			if blockHeaders[i].Hash == added.BlockHeaders[0].PreviousHash {
+				blockHeaders[i].NextHash = added.BlockHeaders[0].Hash
+				break
+			}
+		}
+	}
+
+	addedTxids := make(map[string]bool, len(added.Transactions))
+	for _, transaction := range added.Transactions {
+		addedTxids[transaction.Txid] = true
+	}
+	transactions := slices.DeleteFunc(slices.Clone(dataContent.Transactions), func(transaction btcjson.TxRawResult) bool {
+		return addedTxids[transaction.Txid]
+	})
+
+	return DataContent{
+		BlockHeaders: append(blockHeaders, added.BlockHeaders...),
+		Transactions: append(transactions, added.Transactions...),
+		NetworkInfo:  dataContent.NetworkInfo,
+	}
+}
+
+func (s *chainState) BlockHeaderByHash(hash string) (btcjson.GetBlockHeaderVerboseResult, bool) {
+	index, ok := s.blockHeaderBlockHashMap[hash]
+	if !ok {
+		return btcjson.GetBlockHeaderVerboseResult{}, false
+	}
+	return s.count().blockHeader(s.content.BlockHeaders[index]), true
+}
+
+func (s *chainState) BlockHeaderByHeight(height int32) (btcjson.GetBlockHeaderVerboseResult, bool) {
+	index, ok := s.blockHeaderMap[height]
+	if !ok {
+		return btcjson.GetBlockHeaderVerboseResult{}, false
+	}
+	return s.count().blockHeader(s.content.BlockHeaders[index]), true
+}
+
+func (s *chainState) BestBlockHeader() (btcjson.GetBlockHeaderVerboseResult, bool) {
+	if s.bestBlockHeader < 0 {
+		return btcjson.GetBlockHeaderVerboseResult{}, false
+	}
+	return s.count().blockHeader(s.content.BlockHeaders[s.bestBlockHeader]), true
+}
+
+func (s *chainState) Transaction(txid string) (btcjson.TxRawResult, bool) {
+	index, ok := s.transactionMap[txid]
+	if !ok {
+		return btcjson.TxRawResult{}, false
+	}
+	return s.countTransaction(s.count(), s.content.Transactions[index]), true
+}
+
+func (s *chainState) BlockTransactions(blockHash string) []btcjson.TxRawResult {
+	indexes := s.blockTransactionsMap[blockHash]
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	count := s.count()
+	transactions := make([]btcjson.TxRawResult, len(indexes))
+	for i, index := range indexes {
+		transactions[i] = s.countTransaction(count, s.content.Transactions[index])
+	}
+	return transactions
+}
+
+func (s *chainState) Mempool() []btcjson.TxRawResult {
+	return s.BlockTransactions("")
+}
+
+func (s *chainState) ChainWork(blockHash string) (*big.Int, bool) {
+	index, ok := s.blockHeaderBlockHashMap[blockHash]
+	if !ok {
+		return nil, false
+	}
+	return s.chainWork[index], true
+}
+
+func (s *chainState) NetworkInfo() btcjson.GetNetworkInfoResult {
+	return s.content.NetworkInfo
+}
+
+// Content returns the content with the derived confirmations, a copy unless
+// the stored ones are current.
+func (s *chainState) Content() DataContent {
+	count := s.count()
+	if count.current() {
+		return s.content
+	}
+	dataContent := DataContent{
+		BlockHeaders: make([]btcjson.GetBlockHeaderVerboseResult, len(s.content.BlockHeaders)),
+		Transactions: make([]btcjson.TxRawResult, len(s.content.Transactions)),
+		NetworkInfo:  s.content.NetworkInfo,
+	}
+	for i, blockHeader := range s.content.BlockHeaders {
+		dataContent.BlockHeaders[i] = count.blockHeader(blockHeader)
+	}
+	for i, transaction := range s.content.Transactions {
+		dataContent.Transactions[i] = s.countTransaction(count, transaction)
+	}
+	return dataContent
+}
+
+// Release is a no-op, a chainState holds no resources.
+func (s *chainState) Release() {}
+
+// DataStore is the in-memory ChainStore populated from json data files. The
+// data is kept as an immutable chainState which is replaced as a whole on
+// every write (copy-on-write), so readers never block and never observe a
+// partial update. The zero value is an empty store.
+type DataStore struct {
+	state atomic.Pointer[chainState]
+	// writeMu serializes writers, including data file reloads
+	writeMu sync.Mutex
+
+	// Params is the network of the raw block fixtures in the data files, see
+	// DataLoader.Params. It must be set before the files are read.
+	Params *chaincfg.Params
+
+	// dataFilePaths are the patterns the store was loaded from, used by Reload
+	dataFilePaths []string
+	// fileStates are the data file states at the time of the last load
+	fileStates map[string]fileState
+
+	// snapshots are the states saved by Snapshot, by id
+	snapshots      map[uint64]*chainState
+	lastSnapshotID uint64
+}
+
+var _ ChainStore = (*DataStore)(nil)
+
+// View returns the current point-in-time view of the chain.
+func (d *DataStore) View() (ChainView, error) {
+	return d.current(), nil
+}
+
+func (d *DataStore) current() *chainState {
+	if state := d.state.Load(); state != nil {
+		return state
+	}
+	return newChainState(DataContent{})
+}
+
+// Replace swaps the whole content of the store.
+func (d *DataStore) Replace(dataContent DataContent) error {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+
+	d.state.Store(newChainState(dataContent))
+	return nil
+}
+
+// Update applies fn to a copy of the current content and publishes the
+// result atomically, see chainState.next for the cost of the indexes. The
+// block header and transaction slices are copied, so fn may append, remove
+// or replace entries, but must not modify slices nested inside the entries
+// in place. If fn returns an error nothing is published.
+func (d *DataStore) Update(fn func(dataContent *DataContent) error) error {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+
+	current := d.current().Content()
+	dataContent := DataContent{
+		BlockHeaders: slices.Clone(current.BlockHeaders),
+		Transactions: slices.Clone(current.Transactions),
+		NetworkInfo:  current.NetworkInfo,
+	}
+	if err := fn(&dataContent); err != nil {
+		return err
+	}
+
+	d.state.Store(d.current().next(dataContent))
+	return nil
+}
+
+// Append applies fn to the current state and publishes the state with the
+// blocks and transactions fn returns appended, see ChainStore.Append. Only
+// the new entries are indexed and the stored confirmations are kept, see
+// confirmationCount.
+func (d *DataStore) Append(fn func(view ChainView) (DataContent, error)) error {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+
+	current := d.current()
+	added, err := fn(current)
+	if err != nil {
+		return err
+	}
+	d.state.Store(current.appended(added))
+	return nil
+}
+
+// Snapshot saves the current state and returns an id to Restore it later.
+// The state is immutable, so a snapshot only keeps a reference to it.
+func (d *DataStore) Snapshot() (uint64, error) {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+
+	if d.snapshots == nil {
+		d.snapshots = make(map[uint64]*chainState)
+	}
+	d.lastSnapshotID++
+	d.snapshots[d.lastSnapshotID] = d.current()
+	return d.lastSnapshotID, nil
+}
+
+// Restore rolls the store back to the state saved by Snapshot. Like
+// evm_revert in Hardhat, the snapshot and every snapshot taken after it are
+// discarded, so restoring the same id twice fails.
+func (d *DataStore) Restore(id uint64) error {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+
+	state, ok := d.snapshots[id]
+	if !ok {
+		return fmt.Errorf("unknown snapshot %d", id)
+	}
+	for snapshotID := range d.snapshots {
+		if snapshotID >= id {
+			delete(d.snapshots, snapshotID)
+		}
+	}
+	d.state.Store(state)
+	return nil
+}
+
+// Close is a no-op, the in-memory store holds no resources.
+func (d *DataStore) Close() error {
+	return nil
+}
@@ -0,0 +1,401 @@
+package mockserver
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/gonative-cc/btc-mock-node/client"
+	"github.com/stretchr/testify/assert"
+)
+
+// testBlockHash returns a deterministic block hash for height.
+func testBlockHash(height int32) string {
+	return chainhash.DoubleHashH([]byte(fmt.Sprintf("block-%d", height))).String()
+}
+
+// appendTestBlock adds a block at the next height with height%3+1
+// transactions, so readers can check they never see a partial block.
+func appendTestBlock(dataContent *DataContent) {
+	height := int32(len(dataContent.BlockHeaders))
+	blockHash := testBlockHash(height)
+
+	blockHeader := btcjson.GetBlockHeaderVerboseResult{Hash: blockHash, Height: height}
+	if height > 0 {
+		blockHeader.PreviousHash = testBlockHash(height - 1)
+		dataContent.BlockHeaders[height-1].NextHash = blockHash
+	}
+	dataContent.BlockHeaders = append(dataContent.BlockHeaders, blockHeader)
+
+	for i := int32(0); i <= height%3; i++ {
+		dataContent.Transactions = append(dataContent.Transactions, btcjson.TxRawResult{
+			Hex:       "00",
+			Txid:      chainhash.DoubleHashH([]byte(fmt.Sprintf("tx-%d-%d", height, i))).String(),
+			BlockHash: blockHash,
+		})
+	}
+}
+
+func TestDataStoreView(t *testing.T) {
+	var dataStore DataStore
+	assert.NoError(t, dataStore.Update(func(dataContent *DataContent) error {
+		appendTestBlock(dataContent)
+		return nil
+	}))
+
+	view, err := dataStore.View()
+	assert.NoError(t, err)
+	defer view.Release()
+
+	assert.NoError(t, dataStore.Update(func(dataContent *DataContent) error {
+		appendTestBlock(dataContent)
+		return nil
+	}))
+
+	// the view taken before the update is unchanged
+	bestBlockHeader, ok := view.BestBlockHeader()
+	assert.True(t, ok)
+	assert.Equal(t, int32(0), bestBlockHeader.Height)
+	assert.Empty(t, bestBlockHeader.NextHash)
+
+	bestBlockHeader, ok = dataStore.current().BestBlockHeader()
+	assert.True(t, ok)
+	assert.Equal(t, int32(1), bestBlockHeader.Height)
+
+	// a failed update publishes nothing
+	err = dataStore.Update(func(dataContent *DataContent) error {
+		appendTestBlock(dataContent)
+		return fmt.Errorf("rejected")
+	})
+	assert.Error(t, err)
+	assert.Len(t, dataStore.current().Content().BlockHeaders, 2)
+}
+
+// TestDataStoreConcurrentAccess issues parallel RPCs while the chain grows.
+// Run it with -race to check the handlers are free of data races.
+func TestDataStoreConcurrentAccess(t *testing.T) {
+	serverHandler := &MockServerHandler{Store: &DataStore{}}
+	assert.NoError(t, serverHandler.Store.Update(func(dataContent *DataContent) error {
+		appendTestBlock(dataContent)
+		return nil
+	}))
+
+	testServ := httptest.NewServer(NewRPCServer(serverHandler))
+	defer testServ.Close()
+
+	const (
+		blocks  = 200
+		readers = 8
+	)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := 0; i < blocks; i++ {
+			err := serverHandler.Store.Update(func(dataContent *DataContent) error {
+				appendTestBlock(dataContent)
+				return nil
+			})
+			assert.NoError(t, err)
+		}
+	}()
+
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx := context.Background()
+			client_handler, close_handler, err := client.New(ctx, testServ.URL)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer close_handler()
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				bestBlockHash, err := client_handler.GetBestBlockHash(ctx)
+				if !assert.NoError(t, err) {
+					return
+				}
+				verbosity := 1
+				block, err := client_handler.GetBlock(ctx, bestBlockHash, &verbosity)
+				if !assert.NoError(t, err) {
+					return
+				}
+				// every block is published with all of its transactions
+				assert.Len(t, block.Tx, int(block.Height%3+1))
+
+				blockCount, err := client_handler.GetBlockCount(ctx)
+				assert.NoError(t, err)
+				assert.GreaterOrEqual(t, int64(blockCount), block.Height)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	blockCount, err := serverHandler.GetBlockCount()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(blocks), blockCount)
+}
+
+// benchmarkContent returns a chain of n blocks built with appendTestBlock.
+func benchmarkContent(n int) DataContent {
+	var dataContent DataContent
+	for i := 0; i < n; i++ {
+		appendTestBlock(&dataContent)
+	}
+	return dataContent
+}
+
+// BenchmarkChainStateLookups shows the lookups used by the handlers don't
+// depend on the chain length: the time per operation stays flat as the
+// number of blocks grows.
+func BenchmarkChainStateLookups(b *testing.B) {
+	for _, n := range []int{1_000, 10_000, 100_000} {
+		state := newChainState(benchmarkContent(n))
+		blockHash := testBlockHash(int32(n / 2))
+
+		b.Run(fmt.Sprintf("BestBlockHeader/blocks=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				state.BestBlockHeader()
+			}
+		})
+
+		b.Run(fmt.Sprintf("BlockTransactions/blocks=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				state.BlockTransactions(blockHash)
+			}
+		})
+	}
+}
+
+// BenchmarkDataStoreUpdate shows an update appending a block only indexes
+// the new block: the time per operation grows with the copy of the content,
+// not with rebuilding the indexes.
+func BenchmarkDataStoreUpdate(b *testing.B) {
+	for _, n := range []int{1_000, 10_000, 100_000} {
+		b.Run(fmt.Sprintf("Update/blocks=%d", n), func(b *testing.B) {
+			dataStore := &DataStore{}
+			if err := dataStore.Replace(benchmarkContent(n)); err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				err := dataStore.Update(func(dataContent *DataContent) error {
+					appendTestBlock(dataContent)
+					return nil
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("Mine/blocks=%d", n), func(b *testing.B) {
+			dataContent, err := GenesisContent(&chaincfg.RegressionNetParams)
+			if err != nil {
+				b.Fatal(err)
+			}
+			dataStore := &DataStore{}
+			if err := dataStore.Replace(dataContent); err != nil {
+				b.Fatal(err)
+			}
+			miner := &Miner{Store: dataStore}
+			if _, err := miner.Mine(n); err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := miner.Mine(1); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetBlock(b *testing.B) {
+	for _, n := range []int{1_000, 100_000} {
+		dataStore := &DataStore{}
+		if err := dataStore.Replace(benchmarkContent(n)); err != nil {
+			b.Fatal(err)
+		}
+		serverHandler := &MockServerHandler{Store: dataStore}
+		blockHash, err := chainhash.NewHashFromStr(testBlockHash(int32(n / 2)))
+		if err != nil {
+			b.Fatal(err)
+		}
+		verbosity := 1
+
+		b.Run(fmt.Sprintf("blocks=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := serverHandler.GetBlock(blockHash, &verbosity); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	serverHandler := NewMockServerHandler("../data/mainnet_oldest_blocks.json")
+	mine := func() {
+		assert.NoError(t, serverHandler.Store.Update(func(dataContent *DataContent) error {
+			appendTestBlock(dataContent)
+			return nil
+		}))
+	}
+	blockCount := func() int32 {
+		blockCount, err := serverHandler.GetBlockCount()
+		assert.NoError(t, err)
+		return blockCount
+	}
+
+	first, err := serverHandler.MockSnapshot()
+	assert.NoError(t, err)
+	mine()
+	assert.NoError(t, serverHandler.SetMockTime(1700000000))
+	second, err := serverHandler.MockSnapshot()
+	assert.NoError(t, err)
+	mine()
+	_, err = serverHandler.MockAdvanceTime(3600)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(12), blockCount())
+
+	// the mock time comes back with the chain
+	restored, err := serverHandler.MockRestore(second)
+	assert.NoError(t, err)
+	assert.True(t, restored)
+	assert.Equal(t, int32(11), blockCount())
+	assert.Equal(t, time.Unix(1700000000, 0), serverHandler.Clock.Now())
+
+	// a snapshot can only be restored once
+	_, err = serverHandler.MockRestore(second)
+	assert.Error(t, err)
+
+	third, err := serverHandler.MockSnapshot()
+	assert.NoError(t, err)
+	_, err = serverHandler.MockRestore(first)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(10), blockCount())
+	_, mocked := serverHandler.Clock.MockTime()
+	assert.False(t, mocked)
+
+	// snapshots taken after the restored one are discarded
+	_, err = serverHandler.MockRestore(third)
+	assert.Error(t, err)
+
+	t.Run("Unsupported", func(t *testing.T) {
+		boltStore, err := OpenBoltStore(filepath.Join(t.TempDir(), "chain.db"))
+		assert.NoError(t, err)
+		defer boltStore.Close()
+
+		_, err = (&MockServerHandler{Store: boltStore}).MockSnapshot()
+		assert.Error(t, err)
+	})
+}
+
+// TestChainStateNext checks the indexes carried over by updates match the
+// indexes built from scratch.
+func TestChainStateNext(t *testing.T) {
+	dataContent, err := GenesisContent(&chaincfg.RegressionNetParams)
+	assert.NoError(t, err)
+	dataStore := &DataStore{}
+	assert.NoError(t, dataStore.Replace(dataContent))
+	miner := &Miner{Store: dataStore}
+
+	for _, update := range []func() error{
+		func() error { _, err := miner.Mine(3); return err },
+		func() error {
+			return dataStore.Update(func(dataContent *DataContent) error {
+				dataContent.Transactions = append(dataContent.Transactions, btcjson.TxRawResult{Txid: "aa"})
+				return nil
+			})
+		},
+		func() error {
+			return dataStore.Update(func(dataContent *DataContent) error {
+				// confirm the mempool transaction in block 1
+				last := len(dataContent.Transactions) - 1
+				dataContent.Transactions[last].BlockHash = dataContent.BlockHeaders[1].Hash
+				return nil
+			})
+		},
+		func() error { _, err := miner.Reorg(2); return err },
+		func() error {
+			return dataStore.Update(func(dataContent *DataContent) error {
+				dataContent.BlockHeaders = dataContent.BlockHeaders[:2]
+				return nil
+			})
+		},
+	} {
+		assert.NoError(t, update())
+		state := dataStore.current()
+		// appended blocks keep the height the confirmations were counted at
+		expected := newChainState(state.content)
+		expected.confirmationsHeight = state.confirmationsHeight
+		assert.Equal(t, expected, state)
+	}
+}
+
+// TestChainWork checks the chain work is kept per block by both stores, also
+// for data files starting after the genesis block.
+func TestChainWork(t *testing.T) {
+	dataContent, err := LoadDataFiles("../data/mainnet_oldest_blocks.json")
+	assert.NoError(t, err)
+	// the oldest blocks all have the minimum difficulty, so the work of the
+	// missing blocks is exact
+	partialContent := dataContent
+	partialContent.BlockHeaders = slices.DeleteFunc(slices.Clone(dataContent.BlockHeaders),
+		func(blockHeader btcjson.GetBlockHeaderVerboseResult) bool {
+			return blockHeader.Height < 5
+		})
+
+	boltStore, err := OpenBoltStore(filepath.Join(t.TempDir(), "chain.db"))
+	assert.NoError(t, err)
+	defer boltStore.Close()
+	assert.NoError(t, boltStore.Replace(partialContent))
+
+	dataStore := &DataStore{}
+	assert.NoError(t, dataStore.Replace(dataContent))
+	partialDataStore := &DataStore{}
+	assert.NoError(t, partialDataStore.Replace(partialContent))
+
+	for name, store := range map[string]ChainStore{
+		"DataStore":        dataStore,
+		"PartialDataStore": partialDataStore,
+		"BoltStore":        boltStore,
+	} {
+		t.Run(name, func(t *testing.T) {
+			serverHandler := &MockServerHandler{Store: store}
+			blockChainInfo, err := serverHandler.GetBlockChainInfo()
+			assert.NoError(t, err)
+			assert.Equal(t, "0000000000000000000000000000000000000000000000000000000b000b000b", blockChainInfo.ChainWork)
+
+			// a mined block adds its work
+			_, err = (&Miner{Store: store}).Mine(1)
+			assert.NoError(t, err)
+			blockChainInfo, err = serverHandler.GetBlockChainInfo()
+			assert.NoError(t, err)
+			assert.Equal(t, "0000000000000000000000000000000000000000000000000000000c000c000c", blockChainInfo.ChainWork)
+		})
+	}
+}
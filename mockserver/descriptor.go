@@ -0,0 +1,398 @@
+package mockserver
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+const (
+	descriptorInputCharset    = "0123456789()[],'/*abcdefgh@:$%{}IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+	descriptorChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	descriptorChecksumLength  = 8
+)
+
+// descriptorChecksum returns the checksum of a descriptor without checksum,
+// false if it has characters descriptors can not have.
+func descriptorChecksum(desc string) (string, bool) {
+	generator := [5]uint64{0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d, 0x3706b1677a, 0x644d626ffd}
+	checksum := uint64(1)
+	polymod := func(value uint64) {
+		top := checksum >> 35
+		checksum = (checksum&0x7ffffffff)<<5 ^ value
+		for i, g := range generator {
+			if (top>>i)&1 == 1 {
+				checksum ^= g
+			}
+		}
+	}
+
+	// every character is its position in the charset, the low 5 bits as
+	// one symbol and the high bits of every 3 characters as another
+	var groups []uint64
+	for _, c := range desc {
+		position := strings.IndexRune(descriptorInputCharset, c)
+		if position < 0 {
+			return "", false
+		}
+		polymod(uint64(position & 31))
+		groups = append(groups, uint64(position>>5))
+		if len(groups) == 3 {
+			polymod(groups[0]*9 + groups[1]*3 + groups[2])
+			groups = groups[:0]
+		}
+	}
+	switch len(groups) {
+	case 1:
+		polymod(groups[0])
+	case 2:
+		polymod(groups[0]*3 + groups[1])
+	}
+	for range descriptorChecksumLength {
+		polymod(0)
+	}
+	checksum ^= 1
+
+	result := make([]byte, descriptorChecksumLength)
+	for i := range result {
+		result[i] = descriptorChecksumCharset[(checksum>>(5*(7-i)))&31]
+	}
+	return string(result), true
+}
+
+// splitDescriptorChecksum splits the checksum from desc and verifies it. The
+// checksum is optional unless requireChecksum.
+func splitDescriptorChecksum(desc string, requireChecksum bool) (string, error) {
+	payload, checksum, hasChecksum := strings.Cut(desc, "#")
+	computed, ok := descriptorChecksum(payload)
+	if !ok {
+		return "", invalidAddressOrKey("Invalid characters in payload")
+	}
+	if !hasChecksum {
+		if requireChecksum {
+			return "", invalidAddressOrKey("Missing checksum")
+		}
+		return payload, nil
+	}
+	if len(checksum) != descriptorChecksumLength {
+		return "", invalidAddressOrKey("Expected %d character checksum, not %d characters", descriptorChecksumLength, len(checksum))
+	}
+	if checksum != computed {
+		return "", invalidAddressOrKey("Provided checksum '%s' does not match computed checksum '%s'", checksum, computed)
+	}
+	return payload, nil
+}
+
+// descriptorKey is a KEY expression of a descriptor, either a fixed public
+// key or an extended key and derivation path.
+type descriptorKey struct {
+	// origin is the key origin, with its brackets, as given
+	origin string
+	// private is whether the key was given as a private key
+	private bool
+
+	pubKey *btcec.PublicKey
+	// uncompressed and xOnly are the serialization of pubKey
+	uncompressed, xOnly bool
+
+	extendedKey *hdkeychain.ExtendedKey
+	path        []uint32
+	// pathText is the derivation path after the extended key, as given,
+	// without the range step
+	pathText string
+	// rangeText is the final /* step of a ranged key, "" if not ranged
+	rangeText string
+	// hardenedRange is whether the range step is hardened
+	hardenedRange bool
+}
+
+// parseDescriptorKey parses a KEY expression for the network of params.
+// taproot is whether the key is in tr(), where hex keys can be x-only.
+func parseDescriptorKey(expression string, taproot bool, params *chaincfg.Params) (*descriptorKey, error) {
+	key := &descriptorKey{}
+	if strings.HasPrefix(expression, "[") {
+		end := strings.IndexByte(expression, ']')
+		if end < 0 {
+			return nil, invalidAddressOrKey("Key origin start '[ character expected but not found in '%s'", expression)
+		}
+		if err := checkKeyOrigin(expression[1:end]); err != nil {
+			return nil, err
+		}
+		key.origin, expression = expression[:end+1], expression[end+1:]
+	}
+
+	steps := strings.Split(expression, "/")
+	if len(steps) == 1 {
+		return key, key.parseSingleKey(expression, taproot, params)
+	}
+
+	extendedKey, err := hdkeychain.NewKeyFromString(steps[0])
+	if err != nil || !extendedKey.IsForNet(params) {
+		return nil, invalidAddressOrKey("key '%s' is not valid", steps[0])
+	}
+	key.private = extendedKey.IsPrivate()
+	steps = steps[1:]
+	if last := steps[len(steps)-1]; last == "*" || last == "*'" || last == "*h" {
+		key.rangeText, key.hardenedRange = "/"+last, last != "*"
+		steps = steps[:len(steps)-1]
+	}
+	for _, step := range steps {
+		index, err := parseKeyPathStep(step)
+		if err != nil {
+			return nil, err
+		}
+		key.path = append(key.path, index)
+		key.pathText += "/" + step
+	}
+	if (key.hardenedRange || hasHardenedStep(key.path)) && !key.private {
+		return nil, invalidAddressOrKey("Can not derive hardened keys from an extended public key")
+	}
+	key.extendedKey = extendedKey
+	return key, nil
+}
+
+// parseSingleKey parses a hex public key or a WIF private key.
+func (k *descriptorKey) parseSingleKey(expression string, taproot bool, params *chaincfg.Params) error {
+	if raw, err := hex.DecodeString(expression); err == nil {
+		if taproot && len(raw) == schnorr.PubKeyBytesLen {
+			if k.pubKey, err = schnorr.ParsePubKey(raw); err != nil {
+				return invalidAddressOrKey("Pubkey '%s' is invalid", expression)
+			}
+			k.xOnly = true
+			return nil
+		}
+		if k.pubKey, err = btcec.ParsePubKey(raw); err != nil || (len(raw) != 33 && len(raw) != 65) {
+			return invalidAddressOrKey("Pubkey '%s' is invalid", expression)
+		}
+		k.uncompressed = len(raw) == 65
+		return nil
+	}
+
+	wif, err := btcutil.DecodeWIF(expression)
+	if err != nil || !wif.IsForNet(params) {
+		return invalidAddressOrKey("key '%s' is not valid", expression)
+	}
+	k.private, k.pubKey, k.uncompressed = true, wif.PrivKey.PubKey(), !wif.CompressPubKey
+	return nil
+}
+
+// checkKeyOrigin checks the fingerprint and path of a key origin.
+func checkKeyOrigin(origin string) error {
+	steps := strings.Split(origin, "/")
+	if len(steps[0]) != 8 {
+		return invalidAddressOrKey("Fingerprint is not 4 bytes (%d characters instead of 8 characters)", len(steps[0]))
+	}
+	if _, err := hex.DecodeString(steps[0]); err != nil {
+		return invalidAddressOrKey("Fingerprint '%s' is not hex", steps[0])
+	}
+	for _, step := range steps[1:] {
+		if _, err := parseKeyPathStep(step); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseKeyPathStep parses a step of a derivation path, hardened with a
+// trailing ' or h.
+func parseKeyPathStep(step string) (uint32, error) {
+	number, hardened := strings.CutSuffix(step, "'")
+	if !hardened {
+		number, hardened = strings.CutSuffix(step, "h")
+	}
+	index, err := strconv.ParseUint(number, 10, 32)
+	if err != nil {
+		return 0, invalidAddressOrKey("Key path value '%s' is not a valid uint32", step)
+	}
+	if index >= hdkeychain.HardenedKeyStart {
+		return 0, invalidAddressOrKey("Key path value %d is out of range", index)
+	}
+	if hardened {
+		index += hdkeychain.HardenedKeyStart
+	}
+	return uint32(index), nil
+}
+
+func hasHardenedStep(path []uint32) bool {
+	for _, index := range path {
+		if index >= hdkeychain.HardenedKeyStart {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the key expression without private keys.
+func (k *descriptorKey) String() string {
+	if k.extendedKey == nil {
+		return k.origin + hex.EncodeToString(k.serialize(k.pubKey))
+	}
+	// neutering a valid private key does not fail
+	publicKey, _ := k.extendedKey.Neuter()
+	return k.origin + publicKey.String() + k.pathText + k.rangeText
+}
+
+func (k *descriptorKey) serialize(pubKey *btcec.PublicKey) []byte {
+	switch {
+	case k.xOnly:
+		return schnorr.SerializePubKey(pubKey)
+	case k.uncompressed:
+		return pubKey.SerializeUncompressed()
+	}
+	return pubKey.SerializeCompressed()
+}
+
+// pubKeyAt returns the public key at index of a ranged key, the public key of
+// a key that is not ranged.
+func (k *descriptorKey) pubKeyAt(index uint32) (*btcec.PublicKey, error) {
+	if k.extendedKey == nil {
+		return k.pubKey, nil
+	}
+	path := k.path
+	if k.rangeText != "" {
+		if k.hardenedRange {
+			index += hdkeychain.HardenedKeyStart
+		}
+		path = append(path[:len(path):len(path)], index)
+	}
+	extendedKey := k.extendedKey
+	for _, step := range path {
+		var err error
+		if extendedKey, err = extendedKey.Derive(step); err != nil {
+			return nil, err
+		}
+	}
+	return extendedKey.ECPubKey()
+}
+
+// descriptor is a parsed output script descriptor (BIP380) of the single key
+// kinds: pkh(KEY), wpkh(KEY), sh(wpkh(KEY)) and tr(KEY) without script tree,
+// plus addr(ADDR) and raw(HEX). A KEY is a hex public key, a WIF private key
+// or an extended key with a derivation path, optionally ranged, behind an
+// optional key origin. Multisig and script descriptors are not supported.
+type descriptor struct {
+	// function is the script kind: pkh, wpkh, sh(wpkh), tr, addr or raw
+	function string
+	key      *descriptorKey
+	address  btcutil.Address
+	script   []byte
+}
+
+// parseDescriptor parses a descriptor without checksum for the network of
+// params.
+func parseDescriptor(desc string, params *chaincfg.Params) (*descriptor, error) {
+	function, argument, ok := splitDescriptorFunction(desc)
+	if !ok {
+		return nil, invalidAddressOrKey("'%s' is not a valid descriptor function", desc)
+	}
+
+	switch function {
+	case "addr":
+		address, err := decodeAddress(argument, params)
+		if err != nil {
+			return nil, invalidAddressOrKey("Address is not valid")
+		}
+		return &descriptor{function: function, address: address}, nil
+	case "raw":
+		script, err := hex.DecodeString(argument)
+		if err != nil || len(script) == 0 {
+			return nil, invalidAddressOrKey("Raw script is not hex")
+		}
+		return &descriptor{function: function, script: script}, nil
+	case "sh":
+		inner, innerArgument, ok := splitDescriptorFunction(argument)
+		if !ok || inner != "wpkh" {
+			return nil, invalidAddressOrKey("sh(%s) descriptors are not supported by the mock, only sh(wpkh(KEY))", argument)
+		}
+		function, argument = "sh(wpkh)", innerArgument
+	case "pkh", "wpkh":
+	case "tr":
+		if strings.Contains(argument, ",") {
+			return nil, invalidAddressOrKey("tr() descriptors with a script tree are not supported by the mock")
+		}
+	case "combo", "multi", "sortedmulti", "wsh", "rawtr", "multi_a", "sortedmulti_a":
+		return nil, invalidAddressOrKey("%s() descriptors are not supported by the mock", function)
+	default:
+		return nil, invalidAddressOrKey("'%s' is not a valid descriptor function", desc)
+	}
+
+	key, err := parseDescriptorKey(argument, function == "tr", params)
+	if err != nil {
+		return nil, err
+	}
+	if key.uncompressed && function != "pkh" {
+		return nil, invalidAddressOrKey("Uncompressed keys are not allowed")
+	}
+	return &descriptor{function: function, key: key}, nil
+}
+
+// splitDescriptorFunction splits FUNCTION(ARGUMENT).
+func splitDescriptorFunction(expression string) (string, string, bool) {
+	function, rest, ok := strings.Cut(expression, "(")
+	if !ok || !strings.HasSuffix(rest, ")") {
+		return "", "", false
+	}
+	return function, strings.TrimSuffix(rest, ")"), true
+}
+
+// String returns the descriptor without private keys and checksum.
+func (d *descriptor) String() string {
+	switch d.function {
+	case "addr":
+		return "addr(" + d.address.EncodeAddress() + ")"
+	case "raw":
+		return "raw(" + hex.EncodeToString(d.script) + ")"
+	case "sh(wpkh)":
+		return "sh(wpkh(" + d.key.String() + "))"
+	}
+	return d.function + "(" + d.key.String() + ")"
+}
+
+// isRange is whether the descriptor has a ranged key.
+func (d *descriptor) isRange() bool {
+	return d.key != nil && d.key.rangeText != ""
+}
+
+// addressAt returns the address of the descriptor at index of its range.
+func (d *descriptor) addressAt(index uint32, params *chaincfg.Params) (btcutil.Address, error) {
+	switch d.function {
+	case "addr":
+		return d.address, nil
+	case "raw":
+		scriptPubKey := NewScriptPubKeyResult(d.script, params)
+		if scriptPubKey.Address == "" {
+			return nil, invalidAddressOrKey("Descriptor does not have a corresponding address")
+		}
+		return btcutil.DecodeAddress(scriptPubKey.Address, params)
+	}
+
+	pubKey, err := d.key.pubKeyAt(index)
+	if err != nil {
+		return nil, err
+	}
+	switch d.function {
+	case "pkh":
+		return btcutil.NewAddressPubKeyHash(btcutil.Hash160(d.key.serialize(pubKey)), params)
+	case "wpkh":
+		return btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pubKey.SerializeCompressed()), params)
+	case "sh(wpkh)":
+		witnessAddress, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pubKey.SerializeCompressed()), params)
+		if err != nil {
+			return nil, err
+		}
+		script, err := txscript.PayToAddrScript(witnessAddress)
+		if err != nil {
+			return nil, err
+		}
+		return btcutil.NewAddressScriptHash(script, params)
+	}
+	outputKey := txscript.ComputeTaprootKeyNoScript(pubKey)
+	return btcutil.NewAddressTaproot(schnorr.SerializePubKey(outputKey), params)
+}
@@ -0,0 +1,101 @@
+package mockserver
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// the public key of the private key 1
+	testPubKey = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	// the master keys of BIP32 test vector 1
+	testXprv = "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"
+	testXpub = "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8"
+)
+
+func TestDescriptorChecksum(t *testing.T) {
+	// the examples of BIP380 and of the deriveaddresses help of bitcoind
+	for desc, expected := range map[string]string{
+		"raw(deadbeef)": "89f8spxm",
+		"wpkh([d34db33f/84h/0h/0h]xpub6DJ2dNUysrn5Vt36jH2KLBT2i1auw1tTSSomg8PhqNiUtx8QX2SvC9nrHu81fT41fvDUnhMjEzQgXnQjKEu3oaqMSzhSrHMxyyoEAmUHQbY/0/*)": "cjjspncu",
+	} {
+		checksum, ok := descriptorChecksum(desc)
+		require.True(t, ok)
+		assert.Equal(t, expected, checksum, desc)
+	}
+	_, ok := descriptorChecksum("raw(é)")
+	assert.False(t, ok)
+
+	payload, err := splitDescriptorChecksum("raw(deadbeef)#89f8spxm", true)
+	require.NoError(t, err)
+	assert.Equal(t, "raw(deadbeef)", payload)
+	for desc, expected := range map[string]string{
+		"raw(deadbeef)":          "Missing checksum",
+		"raw(deadbeef)#89f8spx":  "Expected 8 character checksum, not 7 characters",
+		"raw(deadbeef)#89f8spxq": "Provided checksum '89f8spxq' does not match computed checksum '89f8spxm'",
+		"raw(é)#89f8spxm":        "Invalid characters in payload",
+	} {
+		_, err := splitDescriptorChecksum(desc, true)
+		assert.ErrorContains(t, err, expected, desc)
+	}
+}
+
+func TestParseDescriptor(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	for _, test := range []struct {
+		desc      string
+		canonical string
+		address   string
+		err       string
+	}{
+		{desc: "pkh(" + testPubKey + ")", address: "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"},
+		{desc: "wpkh(" + testPubKey + ")", address: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{desc: "sh(wpkh(" + testPubKey + "))", address: "3JvL6Ymt8MVWiCNHC7oWU6nLeHNJKLZGLN"},
+		// the key path only output of the BIP341 test vectors
+		{
+			desc:    "tr(d6889cb081036e0faefa3a35157ad71086b123b2b144b649798b494c300a961d)",
+			address: "bc1p2wsldez5mud2yam29q22wgfh9439spgduvct83k3pm50fcxa5dps59h4z5",
+		},
+		{
+			desc:      "wpkh(KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWn)",
+			canonical: "wpkh(" + testPubKey + ")",
+			address:   "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+		},
+		{desc: "addr(bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4)", address: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{desc: "raw(0014751e76e8199196d454941c45d1b3a323f1433bd6)", address: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{desc: "raw(6a)", err: "Descriptor does not have a corresponding address"},
+		{desc: "pkh([d34db33f/44'/0'/0']" + testXpub + "/1/*)"},
+		{desc: "pkh(" + testXprv + "/0h/*h)", canonical: "pkh(" + testXpub + "/0h/*h)"},
+		{desc: "pkh(" + testXpub + "/0h/*)", err: "Can not derive hardened keys from an extended public key"},
+		{desc: "wpkh(04" + testPubKey[2:] + "483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8)", err: "Uncompressed keys are not allowed"},
+		{desc: "wpkh([d34db33/0]" + testPubKey + ")", err: "Fingerprint is not 4 bytes (7 characters instead of 8 characters)"},
+		{desc: "wpkh(" + testPubKey + "/0)", err: "key '" + testPubKey + "' is not valid"},
+		{desc: "wpkh(cVt4o7BGAig1UXywgGSmARhxMdzP5qvQsxKkSsc1XEkw3tDTQFpy)", err: "key 'cVt4o7BGAig1UXywgGSmARhxMdzP5qvQsxKkSsc1XEkw3tDTQFpy' is not valid"},
+		{desc: "wsh(pk(" + testPubKey + "))", err: "wsh() descriptors are not supported by the mock"},
+		{desc: "sh(multi(1," + testPubKey + "))", err: "sh(multi(1," + testPubKey + ")) descriptors are not supported by the mock"},
+		{desc: "foo(" + testPubKey + ")", err: "'foo(" + testPubKey + ")' is not a valid descriptor function"},
+	} {
+		parsed, err := parseDescriptor(test.desc, params)
+		if err == nil {
+			canonical := test.canonical
+			if canonical == "" {
+				canonical = test.desc
+			}
+			assert.Equal(t, canonical, parsed.String(), test.desc)
+
+			var address btcutil.Address
+			if address, err = parsed.addressAt(0, params); err == nil && test.address != "" {
+				assert.Equal(t, test.address, address.EncodeAddress(), test.desc)
+			}
+		}
+		if test.err != "" {
+			assert.ErrorContains(t, err, test.err, test.desc)
+		} else {
+			assert.NoError(t, err, test.desc)
+		}
+	}
+}
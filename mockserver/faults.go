@@ -0,0 +1,307 @@
+package mockserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+
+	"github.com/gonative-cc/btc-mock-node/client"
+)
+
+// FaultMode is how a call fails when a fault triggers.
+type FaultMode = client.FaultMode
+
+// The fault modes, see client.FaultMode.
+const (
+	FaultRPCError  = client.FaultRPCError
+	FaultHTTPError = client.FaultHTTPError
+	FaultReset     = client.FaultReset
+	FaultTruncate  = client.FaultTruncate
+)
+
+// AllMethods is the fault key matching every method without its own fault.
+const AllMethods = client.AllMethods
+
+// Duration is a time.Duration written as a string like "250ms" in json.
+type Duration = client.Duration
+
+// Fault describes how calls of a method misbehave. The types of the fault
+// admin methods are shared with the client package.
+type Fault = client.Fault
+
+func validateFault(f Fault) error {
+	switch f.Mode {
+	case "", FaultRPCError, FaultHTTPError, FaultReset, FaultTruncate:
+	default:
+		return fmt.Errorf("unknown fault mode %q", f.Mode)
+	}
+	if f.Rate < 0 || f.Rate > 1 {
+		return fmt.Errorf("fault rate %v is not between 0 and 1", f.Rate)
+	}
+	if f.HTTPStatus != 0 && (f.HTTPStatus < 400 || f.HTTPStatus > 599) {
+		return fmt.Errorf("fault http status %d is not an error status", f.HTTPStatus)
+	}
+	return nil
+}
+
+// FaultInjector is an http middleware making the calls of configured methods
+// slow or failing, to exercise the retry logic of clients. Methods are
+// matched by their bitcoind name, like `getblock`, whether called by that
+// name or by the MockServerHandler name. The mock_ admin methods are never
+// faulted, so faults can always be changed at runtime.
+type FaultInjector struct {
+	mu     sync.Mutex
+	faults map[string]*Fault
+	random *rand.Rand
+}
+
+// NewFaultInjector creates an injector with no faults.
+func NewFaultInjector() *FaultInjector {
+	return &FaultInjector{
+		faults: make(map[string]*Fault),
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// ReadFaultConfig reads a json object of faults by method name, see
+// FaultInjector.SetFaults.
+func ReadFaultConfig(path string) (map[string]Fault, error) {
+	byteValue, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var faults map[string]Fault
+	if err := json.Unmarshal(byteValue, &faults); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal fault config %s: %w", path, err)
+	}
+	return faults, nil
+}
+
+// SetFault sets the fault of a method, or of all methods without their own
+// fault for AllMethods.
+func (f *FaultInjector) SetFault(method string, fault Fault) error {
+	if err := validateFault(fault); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults[normalizeMethod(method)] = &fault
+	return nil
+}
+
+// SetFaults replaces all faults.
+func (f *FaultInjector) SetFaults(faults map[string]Fault) error {
+	for method, fault := range faults {
+		if err := validateFault(fault); err != nil {
+			return fmt.Errorf("%s: %w", method, err)
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults = make(map[string]*Fault, len(faults))
+	for method, fault := range faults {
+		f.faults[normalizeMethod(method)] = &fault
+	}
+	return nil
+}
+
+// Faults returns the current faults, with the remaining FailNext counts.
+func (f *FaultInjector) Faults() map[string]Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	faults := make(map[string]Fault, len(f.faults))
+	for method, fault := range f.faults {
+		faults[method] = *fault
+	}
+	return faults
+}
+
+// normalizeMethod maps the MockServerHandler method names to the bitcoind
+// ones, e.g. `MockServerHandler.GetBlock` to `getblock`.
+func normalizeMethod(method string) string {
+	return strings.ToLower(method[strings.LastIndex(method, ".")+1:])
+}
+
+// trigger decides the fate of a call: the delay to apply and the fault it
+// fails with, nil if it succeeds.
+func (f *FaultInjector) trigger(method string) (time.Duration, *Fault) {
+	method = normalizeMethod(method)
+	if strings.HasPrefix(method, "mock") {
+		return 0, nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fault, ok := f.faults[method]
+	if !ok {
+		if fault, ok = f.faults[AllMethods]; !ok {
+			return 0, nil
+		}
+	}
+
+	delay := time.Duration(fault.Latency)
+	if fault.Jitter > 0 {
+		delay += time.Duration(f.random.Int63n(int64(fault.Jitter) + 1))
+	}
+	if fault.FailNext > 0 {
+		fault.FailNext--
+		return delay, fault
+	}
+	if fault.Rate > 0 && f.random.Float64() < fault.Rate {
+		return delay, fault
+	}
+	return delay, nil
+}
+
+// Wrap returns next with the faults applied in front of it. A batch request
+// takes the fault of its first faulted call, for the whole batch.
+func (f *FaultInjector) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		var requests []rpcRequest
+		if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+			_ = json.Unmarshal(body, &requests)
+		} else {
+			var request rpcRequest
+			if json.Unmarshal(body, &request) == nil {
+				requests = []rpcRequest{request}
+			}
+		}
+
+		var delay time.Duration
+		var fault *Fault
+		for _, request := range requests {
+			callDelay, callFault := f.trigger(request.Method)
+			delay = max(delay, callDelay)
+			if fault == nil && callFault != nil {
+				copied := *callFault
+				fault = &copied
+			}
+		}
+
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-req.Context().Done():
+				return
+			}
+		}
+		if fault == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+		injectFault(w, req, next, *fault, requests)
+	})
+}
+
+func injectFault(w http.ResponseWriter, req *http.Request, next http.Handler, fault Fault, requests []rpcRequest) {
+	switch fault.Mode {
+	case FaultHTTPError:
+		status := fault.HTTPStatus
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, "Injected fault", status)
+	case FaultReset:
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			http.Error(w, "Injected fault", http.StatusInternalServerError)
+			return
+		}
+		conn, _, err := hijacker.Hijack()
+		if err != nil {
+			return
+		}
+		// no lingering, so the client sees a reset rather than a clean close
+		if tcpConn, ok := conn.(*net.TCPConn); ok {
+			_ = tcpConn.SetLinger(0)
+		}
+		conn.Close()
+	case FaultTruncate:
+		recorder := httptest.NewRecorder()
+		next.ServeHTTP(recorder, req)
+		response := recorder.Body.Bytes()
+		// the announced length is the full one, so the client sees an
+		// unexpected end of the body
+		w.Header().Set("Content-Type", recorder.Header().Get("Content-Type"))
+		w.Header().Set("Content-Length", strconv.Itoa(len(response)))
+		w.WriteHeader(recorder.Code)
+		_, _ = w.Write(response[:len(response)/2])
+	default:
+		code := fault.ErrorCode
+		if code == 0 {
+			code = btcjson.ErrRPCMisc
+		}
+		rpcErr := &btcjson.RPCError{Code: code, Message: "Injected fault"}
+		responses := make([]rpcReply, len(requests))
+		for i, request := range requests {
+			responses[i] = rpcReply{JSONRPC: "2.0", ID: request.ID, Error: rpcErr}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if len(responses) == 1 {
+			_ = json.NewEncoder(w).Encode(responses[0])
+		} else {
+			_ = json.NewEncoder(w).Encode(responses)
+		}
+	}
+}
+
+// MockSetFault is an admin method that sets the fault of a method, see
+// FaultInjector.SetFault.
+func (h *MockServerHandler) MockSetFault(method string, fault Fault) (bool, error) {
+	if h.Faults == nil {
+		return false, errNoFaultInjector
+	}
+	if err := h.Faults.SetFault(method, fault); err != nil {
+		return false, &RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: err.Error(),
+		}
+	}
+	return true, nil
+}
+
+// MockClearFaults is an admin method that removes all faults.
+func (h *MockServerHandler) MockClearFaults() (bool, error) {
+	if h.Faults == nil {
+		return false, errNoFaultInjector
+	}
+	_ = h.Faults.SetFaults(nil)
+	return true, nil
+}
+
+// MockGetFaults is an admin method that returns the current faults.
+func (h *MockServerHandler) MockGetFaults() (map[string]Fault, error) {
+	if h.Faults == nil {
+		return nil, errNoFaultInjector
+	}
+	return h.Faults.Faults(), nil
+}
+
+var errNoFaultInjector = &RPCError{
+	Code:    btcjson.ErrRPCMisc,
+	Message: "Fault injection is not enabled",
+}
@@ -0,0 +1,147 @@
+package mockserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// postRPC sends a raw json-rpc request body to url.
+func postRPC(url, body string) (*http.Response, error) {
+	return http.Post(url, "application/json", strings.NewReader(body))
+}
+
+func TestFaultInjector(t *testing.T) {
+	mockService := NewMockRPCServer("../data/mainnet_oldest_blocks.json")
+	defer mockService.Close()
+	client_handler := newTestClient(t, mockService.URL)
+	ctx := context.Background()
+
+	setFault := func(t *testing.T, method string, fault Fault) {
+		params, err := json.Marshal([]any{method, fault})
+		require.NoError(t, err)
+		resp, err := postRPC(mockService.URL, `{"jsonrpc": "2.0", "id": 1, "method": "mock_setfault", "params": `+string(params)+`}`)
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.Contains(t, string(body), `"result":true`)
+		t.Cleanup(func() {
+			resp, err := postRPC(mockService.URL, `{"jsonrpc": "2.0", "id": 1, "method": "mock_clearfaults", "params": []}`)
+			require.NoError(t, err)
+			resp.Body.Close()
+		})
+	}
+
+	t.Run("FailNext", func(t *testing.T) {
+		setFault(t, "getblockcount", Fault{FailNext: 2, ErrorCode: btcjson.ErrRPCInWarmup})
+
+		// the fault matches the MockServerHandler method name too
+		for i := 0; i < 2; i++ {
+			_, err := client_handler.GetBlockCount(ctx)
+			assert.ErrorContains(t, err, "Injected fault")
+		}
+		blockCount, err := client_handler.GetBlockCount(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int32(10), blockCount)
+
+		// other methods are not affected
+		_, err = client_handler.GetBestBlockHash(ctx)
+		assert.NoError(t, err)
+	})
+
+	t.Run("ErrorCode", func(t *testing.T) {
+		setFault(t, AllMethods, Fault{Rate: 1, ErrorCode: btcjson.ErrRPCInWarmup})
+
+		resp, err := postRPC(mockService.URL, `{"jsonrpc": "2.0", "id": 7, "method": "getbestblockhash", "params": []}`)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var reply rpcReply
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&reply))
+		assert.Equal(t, json.RawMessage("7"), reply.ID)
+		assert.Equal(t, btcjson.ErrRPCInWarmup, reply.Error.Code)
+	})
+
+	t.Run("HTTPError", func(t *testing.T) {
+		setFault(t, "getblockcount", Fault{Mode: FaultHTTPError, Rate: 1, HTTPStatus: http.StatusBadGateway})
+
+		resp, err := postRPC(mockService.URL, `{"jsonrpc": "2.0", "id": 1, "method": "getblockcount", "params": []}`)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		setFault(t, "getblockcount", Fault{Mode: FaultReset, FailNext: 1})
+
+		_, err := postRPC(mockService.URL, `{"jsonrpc": "2.0", "id": 1, "method": "getblockcount", "params": []}`)
+		assert.Error(t, err)
+	})
+
+	t.Run("Truncate", func(t *testing.T) {
+		setFault(t, "getblockcount", Fault{Mode: FaultTruncate, FailNext: 1})
+
+		resp, err := postRPC(mockService.URL, `{"jsonrpc": "2.0", "id": 1, "method": "getblockcount", "params": []}`)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		_, err = io.ReadAll(resp.Body)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+
+	t.Run("Latency", func(t *testing.T) {
+		setFault(t, "getblockcount", Fault{Latency: Duration(50 * time.Millisecond), Jitter: Duration(10 * time.Millisecond)})
+
+		start := time.Now()
+		_, err := client_handler.GetBlockCount(ctx)
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("AdminMethodsNotFaulted", func(t *testing.T) {
+		setFault(t, AllMethods, Fault{Rate: 1})
+
+		resp, err := postRPC(mockService.URL, `{"jsonrpc": "2.0", "id": 1, "method": "mock_getfaults", "params": []}`)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var reply struct {
+			Result map[string]Fault `json:"result"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&reply))
+		assert.Equal(t, map[string]Fault{AllMethods: {Rate: 1}}, reply.Result)
+	})
+
+	t.Run("InvalidFault", func(t *testing.T) {
+		resp, err := postRPC(mockService.URL, `{"jsonrpc": "2.0", "id": 1, "method": "mock_setfault", "params": ["getblock", {"mode": "explode"}]}`)
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Contains(t, string(body), "unknown fault mode")
+	})
+}
+
+func TestReadFaultConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "faults.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"getblock": {"latency": "200ms", "jitter": "50ms"},
+		"MockServerHandler.GetBlockHeader": {"mode": "http_error", "rate": 0.5}
+	}`), 0o600))
+
+	faults, err := ReadFaultConfig(path)
+	require.NoError(t, err)
+
+	injector := NewFaultInjector()
+	require.NoError(t, injector.SetFaults(faults))
+	assert.Equal(t, map[string]Fault{
+		"getblock":       {Latency: Duration(200 * time.Millisecond), Jitter: Duration(50 * time.Millisecond)},
+		"getblockheader": {Mode: FaultHTTPError, Rate: 0.5},
+	}, injector.Faults())
+}
@@ -0,0 +1,222 @@
+package mockserver
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+// SyncState is implemented by the views of a node in initial block download,
+// which knows more headers than it has blocks.
+type SyncState interface {
+	// Headers returns the height of the best known header.
+	Headers() int32
+}
+
+// SyncingStore is a ChainStore pretending the node is in initial block
+// download. Only the blocks up to the synced height are visible, the synced
+// height advancing from startHeight at blocksPerSecond until it reaches the
+// tip of the wrapped store. Meanwhile getblockchaininfo reports the tip of
+// the wrapped store as the best known header.
+type SyncingStore struct {
+	ChainStore
+
+	startHeight     int32
+	blocksPerSecond float64
+	start           time.Time
+	// now is the clock, replaced in tests
+	now func() time.Time
+}
+
+var _ ChainStore = (*SyncingStore)(nil)
+
+// NewSyncingStore wraps store, starting the sync at startHeight now. The sync
+// advances with clock, the wall clock when nil.
+func NewSyncingStore(store ChainStore, startHeight int32, blocksPerSecond float64, clock *Clock) *SyncingStore {
+	return &SyncingStore{
+		ChainStore:      store,
+		startHeight:     startHeight,
+		blocksPerSecond: blocksPerSecond,
+		start:           clock.Now(),
+		now:             clock.Now,
+	}
+}
+
+// SyncedHeight returns the height the sync has reached by now, which may be
+// past the tip of the chain.
+func (s *SyncingStore) SyncedHeight() int32 {
+	// a clock set back before the start does not undo the sync
+	synced := float64(s.startHeight) + max(s.now().Sub(s.start).Seconds(), 0)*s.blocksPerSecond
+	return int32(min(synced, math.MaxInt32))
+}
+
+// View returns a view of the chain up to the synced height.
+func (s *SyncingStore) View() (ChainView, error) {
+	view, err := s.ChainStore.View()
+	if err != nil {
+		return nil, err
+	}
+
+	bestBlockHeader, ok := view.BestBlockHeader()
+	height := s.SyncedHeight()
+	if !ok || height >= bestBlockHeader.Height {
+		return view, nil
+	}
+	return &syncingView{
+		ChainView: view,
+		height:    height,
+		headers:   bestBlockHeader.Height,
+		best:      syncedBlockHeight(view, height),
+	}, nil
+}
+
+// syncedBlockHeight returns the height of the highest loaded block at or
+// below height, -1 if there is none. Data files do not need to hold every
+// block of the chain.
+func syncedBlockHeight(view ChainView, height int32) int32 {
+	if _, ok := view.BlockHeaderByHeight(height); ok {
+		return height
+	}
+	best := int32(-1)
+	for _, blockHeader := range view.Content().BlockHeaders {
+		if blockHeader.Height <= height {
+			best = max(best, blockHeader.Height)
+		}
+	}
+	return best
+}
+
+// Reload re-reads the data source of the wrapped store, see Reloader.
+func (s *SyncingStore) Reload() (DataDiff, error) {
+	reloader, ok := s.ChainStore.(Reloader)
+	if !ok {
+		return DataDiff{}, fmt.Errorf("data store does not support reloading")
+	}
+	return reloader.Reload()
+}
+
+// Snapshot checkpoints the wrapped store, see Snapshotter. The sync progress
+// is not part of the snapshot.
+func (s *SyncingStore) Snapshot() (uint64, error) {
+	snapshotter, ok := s.ChainStore.(Snapshotter)
+	if !ok {
+		return 0, fmt.Errorf("data store does not support snapshots")
+	}
+	return snapshotter.Snapshot()
+}
+
+// Restore rolls the wrapped store back to a snapshot, see Snapshotter.
+func (s *SyncingStore) Restore(id uint64) error {
+	snapshotter, ok := s.ChainStore.(Snapshotter)
+	if !ok {
+		return fmt.Errorf("data store does not support snapshots")
+	}
+	return snapshotter.Restore(id)
+}
+
+// syncingView hides the blocks above height and their transactions, and
+// counts the confirmations from the best visible block.
+type syncingView struct {
+	ChainView
+	height  int32
+	headers int32
+	// best is the height of the highest visible block
+	best int32
+}
+
+var _ SyncState = (*syncingView)(nil)
+
+func (v *syncingView) Headers() int32 {
+	return v.headers
+}
+
+// hidden is the number of blocks not downloaded yet.
+func (v *syncingView) hidden() int64 {
+	return int64(v.headers - v.best)
+}
+
+func (v *syncingView) visibleHeader(blockHeader btcjson.GetBlockHeaderVerboseResult) (btcjson.GetBlockHeaderVerboseResult, bool) {
+	if blockHeader.Height > v.height {
+		return btcjson.GetBlockHeaderVerboseResult{}, false
+	}
+	blockHeader.Confirmations -= v.hidden()
+	if blockHeader.Height == v.best {
+		blockHeader.NextHash = ""
+	}
+	return blockHeader, true
+}
+
+func (v *syncingView) visibleBlock(blockHash string) bool {
+	blockHeader, ok := v.ChainView.BlockHeaderByHash(blockHash)
+	return !ok || blockHeader.Height <= v.height
+}
+
+func (v *syncingView) BlockHeaderByHash(hash string) (btcjson.GetBlockHeaderVerboseResult, bool) {
+	blockHeader, ok := v.ChainView.BlockHeaderByHash(hash)
+	if !ok {
+		return blockHeader, false
+	}
+	return v.visibleHeader(blockHeader)
+}
+
+func (v *syncingView) BlockHeaderByHeight(height int32) (btcjson.GetBlockHeaderVerboseResult, bool) {
+	blockHeader, ok := v.ChainView.BlockHeaderByHeight(height)
+	if !ok {
+		return blockHeader, false
+	}
+	return v.visibleHeader(blockHeader)
+}
+
+func (v *syncingView) BestBlockHeader() (btcjson.GetBlockHeaderVerboseResult, bool) {
+	return v.BlockHeaderByHeight(v.best)
+}
+
+func (v *syncingView) ChainWork(blockHash string) (*big.Int, bool) {
+	if _, ok := v.BlockHeaderByHash(blockHash); !ok {
+		return nil, false
+	}
+	return v.ChainView.ChainWork(blockHash)
+}
+
+func (v *syncingView) Transaction(txid string) (btcjson.TxRawResult, bool) {
+	transaction, ok := v.ChainView.Transaction(txid)
+	if !ok || transaction.BlockHash == "" {
+		return transaction, ok
+	}
+	if !v.visibleBlock(transaction.BlockHash) {
+		return btcjson.TxRawResult{}, false
+	}
+	transaction.Confirmations = uint64(max(int64(transaction.Confirmations)-v.hidden(), 0))
+	return transaction, true
+}
+
+func (v *syncingView) BlockTransactions(blockHash string) []btcjson.TxRawResult {
+	if !v.visibleBlock(blockHash) {
+		return nil
+	}
+	transactions := v.ChainView.BlockTransactions(blockHash)
+	for i := range transactions {
+		transactions[i].Confirmations = uint64(max(int64(transactions[i].Confirmations)-v.hidden(), 0))
+	}
+	return transactions
+}
+
+// Content returns the content up to the synced height.
+func (v *syncingView) Content() DataContent {
+	content := v.ChainView.Content()
+	dataContent := DataContent{NetworkInfo: content.NetworkInfo}
+	for _, blockHeader := range content.BlockHeaders {
+		if blockHeader, ok := v.visibleHeader(blockHeader); ok {
+			dataContent.BlockHeaders = append(dataContent.BlockHeaders, blockHeader)
+		}
+	}
+	for _, transaction := range content.Transactions {
+		if transaction, ok := v.Transaction(transaction.Txid); ok {
+			dataContent.Transactions = append(dataContent.Transactions, transaction)
+		}
+	}
+	return dataContent
+}
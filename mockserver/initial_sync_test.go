@@ -0,0 +1,85 @@
+package mockserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncingStore(t *testing.T) {
+	dataStore := &DataStore{}
+	dataStore.ReadJsonFiles("../data/mainnet_oldest_blocks.json")
+
+	// the sync starts at height 5 and downloads 2 blocks per second
+	syncingStore := NewSyncingStore(dataStore, 5, 2, nil)
+	clock := syncingStore.start
+	syncingStore.now = func() time.Time { return clock }
+	serverHandler := &MockServerHandler{Store: syncingStore}
+
+	block7Hash, err := chainhash.NewHashFromStr("0000000071966c2b1d065fd446b1e485b2c9d9594acd2007ccbd5441cfc89444")
+	require.NoError(t, err)
+	block7TxHash, err := chainhash.NewHashFromStr("8aa673bc752f2851fd645d6a0a92917e967083007d9c1684f9423b100540673f")
+	require.NoError(t, err)
+
+	t.Run("Syncing", func(t *testing.T) {
+		blockCount, err := serverHandler.GetBlockCount()
+		require.NoError(t, err)
+		assert.Equal(t, int32(5), blockCount)
+
+		blockChainInfo, err := serverHandler.GetBlockChainInfo()
+		require.NoError(t, err)
+		assert.Equal(t, "main", blockChainInfo.Chain)
+		assert.Equal(t, int32(5), blockChainInfo.Blocks)
+		assert.Equal(t, int32(10), blockChainInfo.Headers)
+		assert.Equal(t, "000000009b7262315dbf071787ad3656097b892abffd1f95a1a022f896f533fc", blockChainInfo.BestBlockHash)
+		assert.True(t, blockChainInfo.InitialBlockDownload)
+		assert.Less(t, blockChainInfo.VerificationProgress, 1.0)
+
+		// the tip has no next block and its confirmations count from itself
+		bestBlockHash, err := serverHandler.GetBestBlockHash()
+		require.NoError(t, err)
+		bestBlockHeader, err := serverHandler.GetBlockHeader(bestBlockHash, true)
+		require.NoError(t, err)
+		assert.Empty(t, bestBlockHeader.NextHash)
+		assert.Equal(t, int64(867298-5), bestBlockHeader.Confirmations)
+
+		// the blocks above the synced height and their transactions are
+		// not downloaded yet
+		_, err = serverHandler.GetBlockHash(7)
+		assert.ErrorContains(t, err, "Block number out of range")
+		_, err = serverHandler.GetBlockHeader(block7Hash, true)
+		assert.ErrorContains(t, err, "Block not found")
+		_, err = serverHandler.GetRawTransaction(block7TxHash, true, nil)
+		assert.ErrorContains(t, err, "Transaction not found")
+	})
+
+	t.Run("Advancing", func(t *testing.T) {
+		clock = clock.Add(time.Second)
+
+		blockCount, err := serverHandler.GetBlockCount()
+		require.NoError(t, err)
+		assert.Equal(t, int32(7), blockCount)
+
+		_, err = serverHandler.GetBlockHeader(block7Hash, true)
+		assert.NoError(t, err)
+		transaction, err := serverHandler.GetRawTransaction(block7TxHash, true, nil)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(867737-3), transaction.Confirmations)
+	})
+
+	t.Run("CaughtUp", func(t *testing.T) {
+		clock = clock.Add(time.Minute)
+
+		blockChainInfo, err := serverHandler.GetBlockChainInfo()
+		require.NoError(t, err)
+		assert.Equal(t, int32(10), blockChainInfo.Blocks)
+		assert.Equal(t, int32(10), blockChainInfo.Headers)
+		assert.False(t, blockChainInfo.InitialBlockDownload)
+		assert.Equal(t, 1.0, blockChainInfo.VerificationProgress)
+		// the median of the times of blocks 0 to 10 is the time of block 5
+		assert.Equal(t, int64(1231471428), blockChainInfo.MedianTime)
+	})
+}
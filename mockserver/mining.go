@@ -0,0 +1,501 @@
+package mockserver
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/mining"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// minedBlockInterval is the time between a mined block and its parent.
+const minedBlockInterval = 10 * time.Minute
+
+// Miner extends the chain of a store with new blocks, like the generate RPCs
+// of a regtest node. The transactions of the store without a block are its
+// mempool: SubmitTransaction adds them and the next mined block confirms all
+// of them. Transactions are not validated.
+//
+// Mined blocks follow the difficulty rules of the network, see nextBits.
+// Their proof of work is only solved at the regtest difficulty, any other
+// difficulty is out of reach of a mock, so clients checking the proof of work
+// need a regtest chain.
+type Miner struct {
+	Store ChainStore
+	// Params, when set, is the network of the chain; otherwise it is taken
+	// from the genesis block of the chain.
+	Params *chaincfg.Params
+	// PkScript is the script the coinbases pay to, OP_TRUE when empty.
+	PkScript []byte
+	// Clock, when set, is the time of the mined blocks, bumped past the
+	// median time past like bitcoind does. Without it the blocks are
+	// minedBlockInterval apart.
+	Clock *Clock
+
+	// extraNonce makes every coinbase unique, so the blocks replacing others
+	// in a reorg get new hashes
+	extraNonce atomic.Uint64
+}
+
+// Mine appends n blocks on top of the best block and returns their hashes.
+// The first block confirms the mempool.
+func (m *Miner) Mine(n int) ([]chainhash.Hash, error) {
+	var hashes []chainhash.Hash
+	err := m.Store.Append(func(view ChainView) (DataContent, error) {
+		tip, ok := view.BestBlockHeader()
+		if !ok {
+			return DataContent{}, errors.New("no block to mine on")
+		}
+		minedContent, err := m.mine(view, tip, n)
+		if err != nil {
+			return DataContent{}, err
+		}
+		hashes, err = blockHashes(minedContent)
+		return minedContent, err
+	})
+	return hashes, err
+}
+
+// SubmitTransaction adds a raw transaction to the mempool, like
+// sendrawtransaction, and returns its txid. Its time is the entry time in the
+// mempool. Only its timelocks and the coinbase outputs it spends are checked:
+// the transaction must be final in the next block, by the median time past
+// of the tip, and the coinbases must have matured in the next block.
+func (m *Miner) SubmitTransaction(txHex string) (*chainhash.Hash, error) {
+	tx, err := decodeTxHex(txHex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode transaction: %w", err)
+	}
+	txid := tx.TxHash()
+
+	err = m.Store.Append(func(view ChainView) (DataContent, error) {
+		if _, ok := view.Transaction(txid.String()); ok {
+			return DataContent{}, fmt.Errorf("transaction %s already exists", txid)
+		}
+		var nextHeight int32
+		var pastTimes []int64
+		if tip, ok := view.BestBlockHeader(); ok {
+			nextHeight = tip.Height + 1
+			pastTimes = chainTimes(activeHeader(view), tip.Height)
+		}
+		if len(pastTimes) > 0 &&
+			!blockchain.IsFinalizedTransaction(btcutil.NewTx(tx), nextHeight, time.Unix(median(pastTimes), 0)) {
+			return DataContent{}, fmt.Errorf("transaction %s is non-final", txid)
+		}
+		params := m.params(view)
+		if err := checkCoinbaseMaturity(view, tx, params); err != nil {
+			return DataContent{}, fmt.Errorf("transaction %s: %w", txid, err)
+		}
+
+		transaction, err := NewTxRawResult(tx, params)
+		if err != nil {
+			return DataContent{}, err
+		}
+		transaction.Time = m.Clock.Now().Unix()
+		return DataContent{Transactions: []btcjson.TxRawResult{transaction}}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &txid, nil
+}
+
+// Reorg replaces the last depth blocks with depth+1 new ones, so the new
+// chain has the most work, and returns the hashes of the new blocks. The
+// replaced blocks are kept as stale blocks, like in bitcoind: they have -1
+// confirmations and no next block. Their coinbases stay with them,
+// unconfirmed, their other transactions go back to the mempool and are
+// confirmed again by the first new block. The whole content is rewritten,
+// see ChainStore.Update.
+func (m *Miner) Reorg(depth int) ([]chainhash.Hash, error) {
+	var hashes []chainhash.Hash
+	err := m.Store.Update(func(dataContent *DataContent) error {
+		tipIndex := bestHeaderIndex(dataContent.BlockHeaders)
+		if tipIndex < 0 {
+			return errors.New("no block to reorg")
+		}
+		forkHeight := dataContent.BlockHeaders[tipIndex].Height - int32(depth)
+		if depth < 1 || forkHeight < 0 {
+			return fmt.Errorf("reorg depth %d is out of range", depth)
+		}
+		params := m.Params
+		if params == nil {
+			params = contentParams(*dataContent)
+		}
+
+		// the new chain is one block longer, the blocks kept get one more
+		// confirmation
+		var fork btcjson.GetBlockHeaderVerboseResult
+		stale := make(map[string]bool)
+		for i, blockHeader := range dataContent.BlockHeaders {
+			switch {
+			case blockHeader.Confirmations < 0:
+			case blockHeader.Height > forkHeight:
+				stale[blockHeader.Hash] = true
+				dataContent.BlockHeaders[i].Confirmations = -1
+				dataContent.BlockHeaders[i].NextHash = ""
+			default:
+				dataContent.BlockHeaders[i].Confirmations++
+				if blockHeader.Height == forkHeight {
+					dataContent.BlockHeaders[i].NextHash = ""
+					fork = dataContent.BlockHeaders[i]
+				}
+			}
+		}
+
+		for i, transaction := range dataContent.Transactions {
+			switch {
+			case !stale[transaction.BlockHash]:
+				if transaction.Confirmations > 0 {
+					dataContent.Transactions[i].Confirmations++
+				}
+			case isCoinbase(transaction) || transaction.Hex == "":
+				// transactions recorded without their data can not go
+				// back to the mempool either
+				dataContent.Transactions[i].Confirmations = 0
+			default:
+				tx, err := decodeTxHex(transaction.Hex)
+				if err != nil {
+					return fmt.Errorf("transaction %s: %w", transaction.Txid, err)
+				}
+				if dataContent.Transactions[i], err = NewTxRawResult(tx, params); err != nil {
+					return err
+				}
+			}
+		}
+
+		if fork.Hash == "" {
+			return fmt.Errorf("block at height %d is not loaded", forkHeight)
+		}
+		// the fork is the best block of the chain the new blocks are mined
+		// on, the stale blocks above it are skipped by height
+		minedContent, err := m.mine(newChainState(*dataContent), fork, depth+1)
+		if err != nil {
+			return err
+		}
+		*dataContent = appendContent(*dataContent, minedContent)
+		hashes, err = blockHashes(minedContent)
+		return err
+	})
+	return hashes, err
+}
+
+// mine returns n blocks on top of tip in view, the first one confirming the
+// mempool of view, see Mine.
+func (m *Miner) mine(view ChainView, tip btcjson.GetBlockHeaderVerboseResult, n int) (DataContent, error) {
+	if n < 1 {
+		return DataContent{}, fmt.Errorf("can not mine %d blocks", n)
+	}
+	params := m.params(view)
+
+	prevHash, err := chainhash.NewHashFromStr(tip.Hash)
+	if err != nil {
+		return DataContent{}, fmt.Errorf("block %s: %w", tip.Hash, err)
+	}
+	// the active chain up to the tip, then the mined blocks, for the
+	// difficulty
+	headers := make(map[int32]btcjson.GetBlockHeaderVerboseResult, n)
+	chainHeader := func(height int32) (btcjson.GetBlockHeaderVerboseResult, bool) {
+		if blockHeader, ok := headers[height]; ok {
+			return blockHeader, true
+		}
+		return activeHeader(view)(height)
+	}
+	pastTimes := chainTimes(chainHeader, tip.Height)
+	parent := tip
+
+	var mempool []*wire.MsgTx
+	for _, transaction := range view.Mempool() {
+		tx, err := decodeTxHex(transaction.Hex)
+		if err != nil {
+			return DataContent{}, fmt.Errorf("transaction %s: %w", transaction.Txid, err)
+		}
+		mempool = append(mempool, tx)
+	}
+	fees := mempoolFees(view, mempool)
+
+	blocks := make([]*wire.MsgBlock, n)
+	for i := range blocks {
+		var txs []*wire.MsgTx
+		var fee int64
+		if i == 0 {
+			txs, fee = mempool, fees
+		}
+		height := tip.Height + 1 + int32(i)
+		timestamp := time.Unix(parent.Time, 0).Add(minedBlockInterval)
+		if m.Clock != nil {
+			timestamp = time.Unix(max(m.Clock.Now().Unix(), median(pastTimes)+1), 0)
+		}
+		bits, err := nextBits(chainHeader, parent, timestamp, params)
+		if err != nil {
+			return DataContent{}, err
+		}
+		block, err := m.newBlock(prevHash, timestamp, bits, height, txs, fee, params)
+		if err != nil {
+			return DataContent{}, err
+		}
+		pastTimes = append(pastTimes, timestamp.Unix())
+		if len(pastTimes) > medianTimeBlocks {
+			pastTimes = pastTimes[1:]
+		}
+		blocks[i] = block
+		blockHash := block.BlockHash()
+		prevHash = &blockHash
+		parent = btcjson.GetBlockHeaderVerboseResult{
+			Hash:   blockHash.String(),
+			Height: height,
+			Time:   timestamp.Unix(),
+			Bits:   fmt.Sprintf("%08x", bits),
+		}
+		headers[height] = parent
+	}
+
+	// the blocks already in the chain are not touched, their confirmations
+	// are derived from the new best block, see confirmationCount
+	return BlocksToContent(blocks, tip.Height+1, params)
+}
+
+// blockHashes returns the hashes of the blocks of dataContent.
+func blockHashes(dataContent DataContent) ([]chainhash.Hash, error) {
+	hashes := make([]chainhash.Hash, len(dataContent.BlockHeaders))
+	for i, blockHeader := range dataContent.BlockHeaders {
+		hash, err := chainhash.NewHashFromStr(blockHeader.Hash)
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = *hash
+	}
+	return hashes, nil
+}
+
+// newBlock assembles a block confirming txs, whose coinbase collects the
+// subsidy and fee.
+func (m *Miner) newBlock(
+	prevHash *chainhash.Hash,
+	timestamp time.Time,
+	bits uint32,
+	height int32,
+	txs []*wire.MsgTx,
+	fee int64,
+	params *chaincfg.Params,
+) (*wire.MsgBlock, error) {
+	coinbase, err := NewCoinbase(height, m.extraNonce.Add(1), blockchain.CalcBlockSubsidy(height, params)+fee, m.PkScript)
+	if err != nil {
+		return nil, err
+	}
+	return NewBlock(*prevHash, timestamp, bits, append([]*wire.MsgTx{coinbase}, txs...))
+}
+
+// NewCoinbase returns the coinbase of a block at height paying value to
+// pkScript, OP_TRUE when empty. The extra nonce follows the height in the
+// signature script, so blocks at the same height get different coinbases.
+func NewCoinbase(height int32, extraNonce uint64, value int64, pkScript []byte) (*wire.MsgTx, error) {
+	sigScript, err := txscript.NewScriptBuilder().
+		AddInt64(int64(height)).
+		AddInt64(int64(extraNonce)).
+		Script()
+	if err != nil {
+		return nil, err
+	}
+	if len(pkScript) == 0 {
+		pkScript = []byte{txscript.OP_TRUE}
+	}
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex), sigScript, nil))
+	coinbase.AddTxOut(wire.NewTxOut(value, pkScript))
+	return coinbase, nil
+}
+
+// NewBlock assembles the block of txs, its coinbase first, on top of
+// prevHash. The witness commitment is added to the coinbase when a
+// transaction has a witness. The proof of work is only solved at the regtest
+// difficulty, any other difficulty is out of reach of a mock.
+func NewBlock(prevHash chainhash.Hash, timestamp time.Time, bits uint32, txs []*wire.MsgTx) (*wire.MsgBlock, error) {
+	blockTxs := make([]*btcutil.Tx, len(txs))
+	hasWitness := false
+	for i, tx := range txs {
+		blockTxs[i] = btcutil.NewTx(tx)
+		hasWitness = hasWitness || tx.HasWitness()
+	}
+	if hasWitness {
+		mining.AddWitnessCommitment(blockTxs[0], blockTxs)
+	}
+
+	block := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:    0x20000000,
+			PrevBlock:  prevHash,
+			MerkleRoot: blockchain.CalcMerkleRoot(blockTxs, false),
+			Timestamp:  timestamp,
+			Bits:       bits,
+		},
+		Transactions: txs,
+	}
+	if bits != chaincfg.RegressionNetParams.PowLimitBits {
+		return block, nil
+	}
+
+	target := blockchain.CompactToBig(bits)
+	for nonce := uint32(0); ; nonce++ {
+		block.Header.Nonce = nonce
+		hash := block.Header.BlockHash()
+		if blockchain.HashToBig(&hash).Cmp(target) <= 0 {
+			return block, nil
+		}
+		if nonce == math.MaxUint32 {
+			return nil, fmt.Errorf("no nonce solves the proof of work of block on top of %s", prevHash)
+		}
+	}
+}
+
+// mempoolFees sums the fees of the mempool transactions whose spent outputs
+// are all known to view.
+func mempoolFees(view ChainView, mempool []*wire.MsgTx) int64 {
+	var fees int64
+	for _, tx := range mempool {
+		var in, out int64
+		known := true
+		for _, txIn := range tx.TxIn {
+			spent, _ := view.Transaction(txIn.PreviousOutPoint.Hash.String())
+			vout := spent.Vout
+			if int(txIn.PreviousOutPoint.Index) >= len(vout) {
+				known = false
+				break
+			}
+			value, err := btcutil.NewAmount(vout[txIn.PreviousOutPoint.Index].Value)
+			if err != nil {
+				known = false
+				break
+			}
+			in += int64(value)
+		}
+		for _, txOut := range tx.TxOut {
+			out += txOut.Value
+		}
+		if known && in > out {
+			fees += in - out
+		}
+	}
+	return fees
+}
+
+// bestHeaderIndex returns the index of the tip of the active chain, the
+// header with the highest height, the last one on equal heights like
+// BlockHeaderByHeight, -1 when there is none. Stale blocks are skipped.
+func bestHeaderIndex(blockHeaders []btcjson.GetBlockHeaderVerboseResult) int {
+	best := -1
+	for i, blockHeader := range blockHeaders {
+		if blockHeader.Confirmations < 0 {
+			continue
+		}
+		if best < 0 || blockHeader.Height >= blockHeaders[best].Height {
+			best = i
+		}
+	}
+	return best
+}
+
+// activeHeader returns the lookup of the headers of the active chain of view
+// by height. Stale blocks are skipped.
+func activeHeader(view ChainView) func(height int32) (btcjson.GetBlockHeaderVerboseResult, bool) {
+	return func(height int32) (btcjson.GetBlockHeaderVerboseResult, bool) {
+		blockHeader, ok := view.BlockHeaderByHeight(height)
+		return blockHeader, ok && blockHeader.Confirmations >= 0
+	}
+}
+
+// chainTimes returns the times of the up to medianTimeBlocks blocks of the
+// active chain ending at height, oldest first, looked up by header.
+func chainTimes(header func(height int32) (btcjson.GetBlockHeaderVerboseResult, bool), height int32) []int64 {
+	var pastTimes []int64
+	for h := height - medianTimeBlocks + 1; h <= height; h++ {
+		if blockHeader, ok := header(h); ok {
+			pastTimes = append(pastTimes, blockHeader.Time)
+		}
+	}
+	return pastTimes
+}
+
+// params returns the network of the chain, see Miner.Params.
+func (m *Miner) params(view ChainView) *chaincfg.Params {
+	if m.Params != nil {
+		return m.Params
+	}
+	genesis, _ := view.BlockHeaderByHeight(0)
+	return genesisParams(genesis.Hash)
+}
+
+// checkCoinbaseMaturity checks that the coinbase outputs spent by tx, in
+// view, have the coinbase maturity of params in the next block.
+func checkCoinbaseMaturity(view ChainView, tx *wire.MsgTx, params *chaincfg.Params) error {
+	for _, txIn := range tx.TxIn {
+		transaction, ok := view.Transaction(txIn.PreviousOutPoint.Hash.String())
+		// in the next block the depth of the coinbase is its confirmations
+		if ok && isCoinbase(transaction) &&
+			transaction.Confirmations < uint64(params.CoinbaseMaturity) {
+			return fmt.Errorf("bad-txns-premature-spend-of-coinbase, tried to spend coinbase %s at depth %d",
+				transaction.Txid, transaction.Confirmations)
+		}
+	}
+	return nil
+}
+
+// contentParams returns the network of the content, see genesisParams.
+func contentParams(dataContent DataContent) *chaincfg.Params {
+	for _, blockHeader := range dataContent.BlockHeaders {
+		if blockHeader.Height == 0 {
+			return genesisParams(blockHeader.Hash)
+		}
+	}
+	return genesisParams("")
+}
+
+func isCoinbase(transaction btcjson.TxRawResult) bool {
+	return len(transaction.Vin) > 0 && transaction.Vin[0].Coinbase != ""
+}
+
+func decodeTxHex(txHex string) (*wire.MsgTx, error) {
+	raw, err := hex.DecodeString(txHex)
+	if err != nil {
+		return nil, err
+	}
+	tx := &wire.MsgTx{}
+	if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// SendRawTransaction adds a raw transaction to the mempool, to be confirmed
+// by the next mined block, and returns its txid. The transaction is not
+// validated, see Miner.SubmitTransaction. maxFeeRate, or the allowhighfees
+// flag of older nodes, is ignored.
+func (h *MockServerHandler) SendRawTransaction(hexTx string, maxFeeRate json.RawMessage) (*chainhash.Hash, error) {
+	if _, err := decodeTxHex(hexTx); err != nil {
+		return nil, &RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "TX decode failed",
+		}
+	}
+	miner := &Miner{Store: h.Store, Params: h.Params, Clock: h.Clock}
+	txid, err := miner.SubmitTransaction(hexTx)
+	if err != nil {
+		return nil, &RPCError{
+			Code:    btcjson.ErrRPCVerifyRejected,
+			Message: err.Error(),
+		}
+	}
+	return txid, nil
+}
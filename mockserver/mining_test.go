@@ -0,0 +1,89 @@
+package mockserver
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiner(t *testing.T) {
+	dataStore := &DataStore{}
+	dataStore.ReadJsonFiles("../data/mainnet_oldest_blocks.json")
+	miner := &Miner{Store: dataStore}
+	serverHandler := &MockServerHandler{Store: dataStore}
+
+	// mainnet blocks keep the difficulty of their parent, unsolved
+	hashes, err := miner.Mine(2)
+	require.NoError(t, err)
+	blockCount, err := serverHandler.GetBlockCount()
+	require.NoError(t, err)
+	assert.Equal(t, int32(12), blockCount)
+	blockHeader, err := serverHandler.GetBlockHeader(&hashes[1], true)
+	require.NoError(t, err)
+	assert.Equal(t, "1d00ffff", blockHeader.Bits)
+	assert.Equal(t, hashes[0].String(), blockHeader.PreviousHash)
+
+	// the mempool only takes raw transactions
+	_, err = miner.SubmitTransaction("not hex")
+	assert.ErrorContains(t, err, "failed to decode transaction")
+
+	// a reorg can not go below the genesis block
+	_, err = miner.Reorg(13)
+	assert.ErrorContains(t, err, "out of range")
+	_, err = miner.Reorg(0)
+	assert.ErrorContains(t, err, "out of range")
+
+	reorgHashes, err := miner.Reorg(12)
+	require.NoError(t, err)
+	assert.Len(t, reorgHashes, 13)
+	genesis, ok := dataStore.current().BlockHeaderByHeight(0)
+	require.True(t, ok)
+	assert.Equal(t, reorgHashes[0].String(), genesis.NextHash)
+
+	// the replaced blocks are stale, their coinbases unconfirmed
+	staleHeader, ok := dataStore.current().BlockHeaderByHash(hashes[1].String())
+	require.True(t, ok)
+	assert.Equal(t, int64(-1), staleHeader.Confirmations)
+	assert.Empty(t, staleHeader.NextHash)
+	staleCoinbase := dataStore.current().BlockTransactions(hashes[1].String())[0]
+	assert.Zero(t, staleCoinbase.Confirmations)
+	assert.Len(t, dataStore.current().Content().Transactions, 12+13)
+}
+
+func TestCoinbaseMaturity(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+	dataContent, err := GenesisContent(params)
+	require.NoError(t, err)
+	dataStore := &DataStore{}
+	require.NoError(t, dataStore.Replace(dataContent))
+	miner := &Miner{Store: dataStore}
+	hashes, err := miner.Mine(1)
+	require.NoError(t, err)
+
+	coinbase := dataStore.current().BlockTransactions(hashes[0].String())[0]
+	coinbaseHash, err := chainhash.NewHashFromStr(coinbase.Txid)
+	require.NoError(t, err)
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(coinbaseHash, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}))
+	var raw bytes.Buffer
+	require.NoError(t, tx.Serialize(&raw))
+
+	// the coinbase can be spent in the block 100 blocks after it
+	_, err = miner.Mine(98)
+	require.NoError(t, err)
+	_, err = miner.SubmitTransaction(hex.EncodeToString(raw.Bytes()))
+	assert.ErrorContains(t, err, "bad-txns-premature-spend-of-coinbase, tried to spend coinbase "+coinbase.Txid+" at depth 99")
+	_, err = miner.Mine(1)
+	require.NoError(t, err)
+	_, err = miner.SubmitTransaction(hex.EncodeToString(raw.Bytes()))
+	assert.NoError(t, err)
+}
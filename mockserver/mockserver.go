@@ -1,21 +1,55 @@
 package mockserver
 
 import (
+	"bytes"
+	"encoding/hex"
 	"fmt"
+	"net/http"
 	"net/http/httptest"
+	"slices"
+	"time"
 
 	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/filecoin-project/go-jsonrpc"
+
+	"github.com/gonative-cc/btc-mock-node/client"
 )
 
 // Have a type with some exported methods
 type MockServerHandler struct {
-	DataStore DataStore
+	Store ChainStore
+	// Faults, when set, is controlled by the mock_ fault admin methods; it
+	// must also wrap the server, see NewHTTPHandler.
+	Faults *FaultInjector
+	// Clock, when set, is controlled by setmocktime and mock_advancetime.
+	Clock *Clock
+	// Params, when set, is the network of the node; otherwise it is taken
+	// from the genesis block of the chain.
+	Params *chaincfg.Params
+}
+
+// params returns the network of the node, see MockServerHandler.Params.
+func (h *MockServerHandler) params(view ChainView) *chaincfg.Params {
+	if h.Params != nil {
+		return h.Params
+	}
+	genesis, _ := view.BlockHeaderByHeight(0)
+	return genesisParams(genesis.Hash)
 }
 
-func (h *MockServerHandler) PopulateDataStore(dataFilePath string) {
-	h.DataStore.ReadJson(dataFilePath)
+// view returns the current chain view, the caller must release it.
+func (h *MockServerHandler) view() (ChainView, error) {
+	view, err := h.Store.View()
+	if err != nil {
+		return nil, &RPCError{
+			Code:    btcjson.ErrRPCDatabase,
+			Message: fmt.Sprintf("Unable to read chain data: %v", err),
+		}
+	}
+	return view, nil
 }
 
 func (h *MockServerHandler) Ping(in int) int {
@@ -23,22 +57,24 @@ func (h *MockServerHandler) Ping(in int) int {
 }
 
 func (h *MockServerHandler) GetBestBlockHash() (*chainhash.Hash, error) {
-	// find the highest block height
-	maxHeightIndex := 0
-	maxHeight := h.DataStore.DataContent.BlockHeaders[0].Height
-	for index, blockHeader := range h.DataStore.DataContent.BlockHeaders {
-		if blockHeader.Height > maxHeight {
-			maxHeightIndex = index
-			maxHeight = blockHeader.Height
-		}
+	view, err := h.view()
+	if err != nil {
+		return nil, err
 	}
+	defer view.Release()
 
-	// get chainHash of block with max height
-	maxheightHash := h.DataStore.DataContent.BlockHeaders[maxHeightIndex].Hash
+	// find the block with the highest block height
+	bestBlockHeader, ok := view.BestBlockHeader()
+	if !ok {
+		return nil, &RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
+	}
 
-	bestBlockHash, err := chainhash.NewHashFromStr(maxheightHash)
+	bestBlockHash, err := chainhash.NewHashFromStr(bestBlockHeader.Hash)
 	if err != nil {
-		return nil, &btcjson.RPCError{
+		return nil, &RPCError{
 			Code:    btcjson.ErrRPCDecodeHexString,
 			Message: "Unable to parse block hash stored",
 		}
@@ -46,37 +82,43 @@ func (h *MockServerHandler) GetBestBlockHash() (*chainhash.Hash, error) {
 	return bestBlockHash, nil
 }
 
+// GetBlock returns a block shaped by verbosity like bitcoind's getblock: the
+// serialized block in hex at verbosity 0, the block with the txids of its
+// transactions at verbosity 1, the default, and with its decoded transactions
+// at verbosity 2 and above.
 func (h *MockServerHandler) GetBlock(
 	blockHash *chainhash.Hash,
 	verbosity *int,
-) (*btcjson.GetBlockVerboseResult, error) {
-	// NOTE: verbosity is added to be compatible with the relayer
-	// the method always assumes verbosity=1
-
-	var foundBlockHeader *btcjson.GetBlockHeaderVerboseResult = nil
-	// find the block with hash `blockHash`
-	if blockHeader, ok := h.DataStore.BlockHeaderBlockHashMap[blockHash.String()]; ok {
-		if blockHeader.Hash == blockHash.String() {
-			foundBlockHeader = &blockHeader
-		}
+) (*client.GetBlockResult, error) {
+	view, err := h.view()
+	if err != nil {
+		return nil, err
 	}
+	defer view.Release()
 
-	if foundBlockHeader == nil {
-		return nil, &btcjson.RPCError{
+	// find the block with hash `blockHash`
+	foundBlockHeader, ok := view.BlockHeaderByHash(blockHash.String())
+	if !ok {
+		return nil, &RPCError{
 			Code:    btcjson.ErrRPCBlockNotFound,
 			Message: "Block not found",
 		}
 	}
+	transactions := view.BlockTransactions(blockHash.String())
 
-	var foundBlockTxsIds []string
-	// find transactions with blockHash
-	for _, tx := range h.DataStore.DataContent.Transactions {
-		if tx.BlockHash == blockHash.String() {
-			foundBlockTxsIds = append(foundBlockTxsIds, tx.Hex)
+	level := 1
+	if verbosity != nil {
+		level = *verbosity
+	}
+	if level <= 0 {
+		blockHex, err := serializeBlock(foundBlockHeader, transactions)
+		if err != nil {
+			return nil, err
 		}
+		return &client.GetBlockResult{Hex: blockHex}, nil
 	}
 
-	return &btcjson.GetBlockVerboseResult{
+	block := btcjson.GetBlockVerboseResult{
 		Hash:          foundBlockHeader.Hash,
 		Confirmations: foundBlockHeader.Confirmations,
 		StrippedSize:  0, // placeholder
@@ -86,32 +128,84 @@ func (h *MockServerHandler) GetBlock(
 		Version:       foundBlockHeader.Version,
 		VersionHex:    foundBlockHeader.VersionHex,
 		MerkleRoot:    foundBlockHeader.MerkleRoot,
-		Tx:            foundBlockTxsIds,
 		Time:          foundBlockHeader.Time,
 		Nonce:         uint32(foundBlockHeader.Nonce),
 		Bits:          foundBlockHeader.Bits,
 		Difficulty:    foundBlockHeader.Difficulty,
 		PreviousHash:  foundBlockHeader.PreviousHash,
 		NextHash:      foundBlockHeader.NextHash,
-	}, nil
+	}
+	for _, tx := range transactions {
+		if level == 1 {
+			block.Tx = append(block.Tx, tx.Txid)
+			continue
+		}
+		// like bitcoind, transactions of a block leave out the block fields
+		tx.BlockHash, tx.Confirmations, tx.Time, tx.Blocktime = "", 0, 0, 0
+		block.RawTx = append(block.RawTx, tx)
+	}
+	return &client.GetBlockResult{GetBlockVerboseResult: block}, nil
+}
+
+// serializeBlock returns the block of the header and transactions in hex.
+// Blocks whose transactions were recorded by their txid alone can not be
+// serialized.
+func serializeBlock(blockHeader btcjson.GetBlockHeaderVerboseResult, transactions []btcjson.TxRawResult) (string, error) {
+	header, err := parseBlockHeader(blockHeader)
+	if err != nil {
+		return "", &RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: fmt.Sprintf("Unable to parse block stored: %v", err),
+		}
+	}
+	var buf bytes.Buffer
+	if err := header.Serialize(&buf); err != nil {
+		return "", err
+	}
+	if err := wire.WriteVarInt(&buf, 0, uint64(len(transactions))); err != nil {
+		return "", err
+	}
+	for _, tx := range transactions {
+		raw, err := hex.DecodeString(tx.Hex)
+		if err != nil || tx.Hex == "" {
+			return "", &RPCError{
+				Code:    btcjson.ErrRPCMisc,
+				Message: "Block not available (transactions not recorded)",
+			}
+		}
+		buf.Write(raw)
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
 }
 
 func (h *MockServerHandler) GetBlockCount() (int32, error) {
+	view, err := h.view()
+	if err != nil {
+		return 0, err
+	}
+	defer view.Release()
+
 	// find the highest block height
-	maxHeight := h.DataStore.DataContent.BlockHeaders[0].Height
-	for _, blockHeader := range h.DataStore.DataContent.BlockHeaders {
-		maxHeight = max(maxHeight, blockHeader.Height)
+	bestBlockHeader, ok := view.BestBlockHeader()
+	if !ok {
+		return 0, nil
 	}
 
-	return maxHeight, nil
+	return bestBlockHeader.Height, nil
 }
 
 func (h *MockServerHandler) GetBlockHash(blockHeight int32) (*chainhash.Hash, error) {
+	view, err := h.view()
+	if err != nil {
+		return nil, err
+	}
+	defer view.Release()
+
 	// get chainHash of block with blockHeight
-	if blockHeader, ok := h.DataStore.BlockHeaderMap[blockHeight]; ok {
+	if blockHeader, ok := view.BlockHeaderByHeight(blockHeight); ok {
 		blockHash, err := chainhash.NewHashFromStr(blockHeader.Hash)
 		if err != nil {
-			return nil, &btcjson.RPCError{
+			return nil, &RPCError{
 				Code:    btcjson.ErrRPCDecodeHexString,
 				Message: "Unable to parse block hash stored",
 			}
@@ -119,7 +213,7 @@ func (h *MockServerHandler) GetBlockHash(blockHeight int32) (*chainhash.Hash, er
 		return blockHash, nil
 	}
 
-	return nil, &btcjson.RPCError{
+	return nil, &RPCError{
 		Code:    btcjson.ErrRPCOutOfRange,
 		Message: "Block number out of range",
 	}
@@ -129,14 +223,18 @@ func (h *MockServerHandler) GetBlockHeader(
 	blockHash *chainhash.Hash,
 	verbose bool,
 ) (*btcjson.GetBlockHeaderVerboseResult, error) {
+	view, err := h.view()
+	if err != nil {
+		return nil, err
+	}
+	defer view.Release()
+
 	// find the block with hash `blockHash`
-	if blockHeader, ok := h.DataStore.BlockHeaderBlockHashMap[blockHash.String()]; ok {
-		if blockHeader.Hash == blockHash.String() {
-			return &blockHeader, nil
-		}
+	if blockHeader, ok := view.BlockHeaderByHash(blockHash.String()); ok {
+		return &blockHeader, nil
 	}
 
-	return nil, &btcjson.RPCError{
+	return nil, &RPCError{
 		Code:    btcjson.ErrRPCBlockNotFound,
 		Message: "Block not found",
 	}
@@ -147,12 +245,18 @@ func (h *MockServerHandler) GetTxOut(
 	index uint32,
 	mempool bool,
 ) (*btcjson.GetTxOutResult, error) {
+	view, err := h.view()
+	if err != nil {
+		return nil, err
+	}
+	defer view.Release()
+
 	voutIndex := index
 
 	// find the transaction with hash `txHash`
-	if transaction, ok := h.DataStore.TransactionMap[txHash.String()]; ok {
+	if transaction, ok := view.Transaction(txHash.String()); ok {
 		if voutIndex >= uint32(len(transaction.Vout)) {
-			return nil, &btcjson.RPCError{
+			return nil, &RPCError{
 				Code: btcjson.ErrRPCInvalidTxVout,
 				Message: "Output index number (vout) does not " +
 					"exist for transaction.",
@@ -170,10 +274,10 @@ func (h *MockServerHandler) GetTxOut(
 	}
 
 	// if no txn found, return error
-	return nil, btcjson.NewRPCError(
-		btcjson.ErrRPCNoTxInfo,
-		fmt.Sprintf("No information available about transaction %v", txHash),
-	)
+	return nil, &RPCError{
+		Code:    btcjson.ErrRPCNoTxInfo,
+		Message: fmt.Sprintf("No information available about transaction %v", txHash),
+	}
 }
 
 func (h *MockServerHandler) GetRawTransaction(
@@ -181,19 +285,116 @@ func (h *MockServerHandler) GetRawTransaction(
 	verbose bool,
 	blockHash *chainhash.Hash,
 ) (*btcjson.TxRawResult, error) {
+	view, err := h.view()
+	if err != nil {
+		return nil, err
+	}
+	defer view.Release()
+
 	// find the transaction with hash `txHash`
-	if transaction, ok := h.DataStore.TransactionMap[txHash.String()]; ok {
+	if transaction, ok := view.Transaction(txHash.String()); ok {
 		return &transaction, nil
 	}
 
-	return nil, &btcjson.RPCError{
+	return nil, &RPCError{
 		Code:    btcjson.ErrRPCRawTxString,
 		Message: "Transaction not found",
 	}
 }
 
 func (h *MockServerHandler) GetNetworkInfo() (*btcjson.GetNetworkInfoResult, error) {
-	return &h.DataStore.DataContent.NetworkInfo, nil
+	view, err := h.view()
+	if err != nil {
+		return nil, err
+	}
+	defer view.Release()
+
+	networkInfo := view.NetworkInfo()
+	// the offset of the node clock to the wall clock
+	if mockTime, ok := h.Clock.MockTime(); ok {
+		networkInfo.TimeOffset = int64(time.Until(mockTime).Round(time.Second).Seconds())
+	}
+	return &networkInfo, nil
+}
+
+// GetBlockChainInfo returns the state of the chain. While a SyncingStore is
+// catching up, the node reports initial block download and headers ahead of
+// its blocks.
+func (h *MockServerHandler) GetBlockChainInfo() (*btcjson.GetBlockChainInfoResult, error) {
+	view, err := h.view()
+	if err != nil {
+		return nil, err
+	}
+	defer view.Release()
+
+	bestBlockHeader, ok := view.BestBlockHeader()
+	if !ok {
+		return nil, &RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
+	}
+
+	headers := bestBlockHeader.Height
+	if syncState, ok := view.(SyncState); ok {
+		headers = syncState.Headers()
+	}
+
+	return &btcjson.GetBlockChainInfoResult{
+		Chain:                networkOf(h.params(view)).Chain,
+		Blocks:               bestBlockHeader.Height,
+		Headers:              headers,
+		BestBlockHash:        bestBlockHeader.Hash,
+		Difficulty:           bestBlockHeader.Difficulty,
+		MedianTime:           medianTime(view, bestBlockHeader.Height),
+		VerificationProgress: float64(bestBlockHeader.Height+1) / float64(headers+1),
+		InitialBlockDownload: headers > bestBlockHeader.Height,
+		ChainWork:            chainWork(view, bestBlockHeader.Hash),
+	}, nil
+}
+
+// genesisParams returns the network of a chain by the hash of its genesis
+// block. Chains loaded without their genesis block are taken as mainnet.
+func genesisParams(genesisHash string) *chaincfg.Params {
+	for _, params := range blockFileParams {
+		if genesisHash == params.GenesisHash.String() {
+			return params
+		}
+	}
+	return &chaincfg.MainNetParams
+}
+
+// medianTimeBlocks is the number of blocks the median time past is taken
+// over.
+const medianTimeBlocks = 11
+
+// medianTime returns the median time of the 11 blocks ending at height, or
+// of the loaded ones among them.
+func medianTime(view ChainView, height int32) int64 {
+	var times []int64
+	for h := height; h > height-medianTimeBlocks && h >= 0; h-- {
+		if blockHeader, ok := view.BlockHeaderByHeight(h); ok {
+			times = append(times, blockHeader.Time)
+		}
+	}
+	return median(times)
+}
+
+// median returns the median of times, which must not be empty.
+func median(times []int64) int64 {
+	sorted := slices.Clone(times)
+	slices.Sort(sorted)
+	return sorted[len(sorted)/2]
+}
+
+// chainWork returns the total work of the chain up to the block in hex, like
+// bitcoind.
+func chainWork(view ChainView, blockHash string) string {
+	work, ok := view.ChainWork(blockHash)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%064x", work)
 }
 
 // GetInfo returns miscellaneous info regarding the RPC server.  The returned
@@ -204,13 +405,106 @@ func (h *MockServerHandler) GetInfo() (*btcjson.InfoWalletResult, error) {
 	return nil, nil
 }
 
-// NewMockRPCServer creates a new instance of the rpcServer and starts listening
-func NewMockRPCServer(dataFilePath string) *httptest.Server {
-	// Create a new RPC server
-	rpcServer := jsonrpc.NewServer()
+// Reloader is implemented by stores that can re-read their data source.
+type Reloader interface {
+	Reload() (DataDiff, error)
+}
+
+// MockReload is an admin method that re-reads the data files and returns a
+// summary of the changes.
+func (h *MockServerHandler) MockReload() (*DataDiff, error) {
+	reloader, ok := h.Store.(Reloader)
+	if !ok {
+		return nil, &RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Data store does not support reloading",
+		}
+	}
+
+	diff, err := reloader.Reload()
+	if err != nil {
+		return nil, &RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: fmt.Sprintf("Unable to reload data files: %v", err),
+		}
+	}
+	return &diff, nil
+}
+
+// Snapshotter is implemented by stores that can checkpoint their state and
+// roll back to it.
+type Snapshotter interface {
+	Snapshot() (uint64, error)
+	Restore(id uint64) error
+}
+
+func (h *MockServerHandler) snapshotter() (Snapshotter, error) {
+	snapshotter, ok := h.Store.(Snapshotter)
+	if !ok {
+		return nil, &RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Data store does not support snapshots",
+		}
+	}
+	return snapshotter, nil
+}
+
+// MockSnapshot is an admin method that checkpoints the chain state and the
+// mock time and returns the snapshot id, see MockRestore.
+func (h *MockServerHandler) MockSnapshot() (uint64, error) {
+	snapshotter, err := h.snapshotter()
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := snapshotter.Snapshot()
+	if err != nil {
+		return 0, &RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: fmt.Sprintf("Unable to take snapshot: %v", err),
+		}
+	}
+	h.Clock.saveMockTime(id)
+	return id, nil
+}
+
+// MockRestore is an admin method that rolls the chain state and the mock time
+// back to a snapshot. The snapshot and all later ones can not be restored again, take
+// a new snapshot to restore the same state once more.
+func (h *MockServerHandler) MockRestore(id uint64) (bool, error) {
+	snapshotter, err := h.snapshotter()
+	if err != nil {
+		return false, err
+	}
+
+	if err := snapshotter.Restore(id); err != nil {
+		return false, &RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Unable to restore snapshot: %v", err),
+		}
+	}
+	h.Clock.restoreMockTime(id)
+	return true, nil
+}
+
+// NewMockServerHandler creates a handler populated from the data files. The
+// data is merged from all given files, directories and glob patterns, see
+// LoadDataFiles for the precedence rules.
+func NewMockServerHandler(dataFilePaths ...string) *MockServerHandler {
+	// populate data from json data/ files
+	dataStore := &DataStore{}
+	dataStore.ReadJsonFiles(dataFilePaths...)
 
-	// create a handler instance and register it
-	serverHandler := &MockServerHandler{}
+	return &MockServerHandler{Store: dataStore, Faults: NewFaultInjector(), Clock: &Clock{}}
+}
+
+// NewRPCServer creates a json-rpc server serving the handler methods under
+// both their Go names and the bitcoind method names.
+func NewRPCServer(serverHandler *MockServerHandler) *jsonrpc.RPCServer {
+	// Create a new RPC server, bool params accept numbers like in bitcoind
+	rpcServer := jsonrpc.NewServer(jsonrpc.WithParamDecoder(new(bool), decodeBoolParam))
+
+	// register the handler instance
 	rpcServer.Register("MockServerHandler", serverHandler)
 
 	// method aliases
@@ -223,13 +517,47 @@ func NewMockRPCServer(dataFilePath string) *httptest.Server {
 	rpcServer.AliasMethod("gettxout", "MockServerHandler.GetTxOut")
 	rpcServer.AliasMethod("getrawtransaction", "MockServerHandler.GetRawTransaction")
 	rpcServer.AliasMethod("getnetworkinfo", "MockServerHandler.GetNetworkInfo")
+	rpcServer.AliasMethod("getblockchaininfo", "MockServerHandler.GetBlockChainInfo")
 	rpcServer.AliasMethod("getinfo", "MockServerHandler.GetInfo")
+	rpcServer.AliasMethod("setmocktime", "MockServerHandler.SetMockTime")
+	rpcServer.AliasMethod("sendrawtransaction", "MockServerHandler.SendRawTransaction")
+	rpcServer.AliasMethod("validateaddress", "MockServerHandler.ValidateAddress")
+	rpcServer.AliasMethod("getaddressinfo", "MockServerHandler.GetAddressInfo")
+	rpcServer.AliasMethod("getdescriptorinfo", "MockServerHandler.GetDescriptorInfo")
+	rpcServer.AliasMethod("deriveaddresses", "MockServerHandler.DeriveAddresses")
+
+	// admin method aliases
+	rpcServer.AliasMethod("mock_reload", "MockServerHandler.MockReload")
+	rpcServer.AliasMethod("mock_dumpstate", "MockServerHandler.MockDumpState")
+	rpcServer.AliasMethod("mock_snapshot", "MockServerHandler.MockSnapshot")
+	rpcServer.AliasMethod("mock_restore", "MockServerHandler.MockRestore")
+	rpcServer.AliasMethod("mock_setfault", "MockServerHandler.MockSetFault")
+	rpcServer.AliasMethod("mock_clearfaults", "MockServerHandler.MockClearFaults")
+	rpcServer.AliasMethod("mock_getfaults", "MockServerHandler.MockGetFaults")
+	rpcServer.AliasMethod("mock_advancetime", "MockServerHandler.MockAdvanceTime")
+
+	return rpcServer
+}
+
+// NewHTTPHandler serves the handler methods like NewRPCServer, with the
+// trailing params optional like in bitcoind, behind the fault injector of the
+// handler when it has one.
+func NewHTTPHandler(serverHandler *MockServerHandler) http.Handler {
+	handler := withOptionalParams(NewRPCServer(serverHandler))
+	if serverHandler.Faults == nil {
+		return handler
+	}
+	return serverHandler.Faults.Wrap(handler)
+}
 
-	// populate data from json data/ file
-	serverHandler.PopulateDataStore(dataFilePath)
+// NewMockRPCServer creates a new instance of the rpcServer and starts listening.
+// The data is merged from all given files, directories and glob patterns, see
+// LoadDataFiles for the precedence rules.
+func NewMockRPCServer(dataFilePaths ...string) *httptest.Server {
+	serverHandler := NewMockServerHandler(dataFilePaths...)
 
 	// serve the API
-	testServ := httptest.NewServer(rpcServer)
+	testServ := httptest.NewServer(NewHTTPHandler(serverHandler))
 
 	return testServ
 }
@@ -1,26 +1,26 @@
 package mockserver
 
 import (
+	"bytes"
 	"context"
+	"encoding/hex"
 	"testing"
 
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/filecoin-project/go-jsonrpc"
 	"github.com/gonative-cc/btc-mock-node/client"
 	"github.com/stretchr/testify/assert"
 )
 
 // setup initializes the test instance and sets up common resources.
-func setup(t *testing.T) (client.Client, jsonrpc.ClientCloser) {
+func setup(t *testing.T) (*client.Client, jsonrpc.ClientCloser) {
 	mockService := NewMockRPCServer("../data/mainnet_oldest_blocks.json")
 
 	t.Logf("mock json-rpc server listening on: %s", mockService.URL)
 
-	ctx := context.Background()
-	client_handler := client.Client{}
-
-	close_handler, err := jsonrpc.NewClient(ctx, mockService.URL, "MockServerHandler", &client_handler, nil)
+	client_handler, close_handler, err := client.New(context.Background(), mockService.URL)
 	assert.NoError(t, err)
 
 	return client_handler, close_handler
@@ -33,15 +33,16 @@ func teardown(close_handler jsonrpc.ClientCloser) {
 
 func TestMockRPCServer(t *testing.T) {
 	client_handler, close_handler := setup(t)
+	ctx := context.Background()
 	defer teardown(close_handler)
 
 	t.Run("Ping", func(t *testing.T) {
-		pingValue := client_handler.Ping(10)
+		pingValue := client_handler.Ping(ctx, 10)
 		assert.Equal(t, 10, pingValue)
 	})
 
 	t.Run("GetBestBlockHash", func(t *testing.T) {
-		bestBlockHash, err := client_handler.GetBestBlockHash()
+		bestBlockHash, err := client_handler.GetBestBlockHash(ctx)
 		assert.NoError(t, err)
 
 		actualBlockHash, err := chainhash.NewHashFromStr("000000002c05cc2e78923c34df87fd108b22221ac6076c18f3ade378a4d915e9")
@@ -51,16 +52,17 @@ func TestMockRPCServer(t *testing.T) {
 	})
 
 	t.Run("GetBlock", func(t *testing.T) {
+		const coinbaseHex = "01000000010000000000000000000000000000000000000000000000000000000000000000ffffffff0704ffff001d012bffffffff0100f2052a01000000434104a59e64c774923d003fae7491b2a7f75d6b7aa3f35606a8ff1cf06cd3317d16a41aa16928b1df1f631f31f28c7da35d4edad3603adb2338c4d4dd268f31530555ac00000000"
 		blockHash, err := chainhash.NewHashFromStr("0000000071966c2b1d065fd446b1e485b2c9d9594acd2007ccbd5441cfc89444")
 		assert.NoError(t, err)
 
 		verbosity := 1
 
-		block, err := client_handler.GetBlock(blockHash, &verbosity)
+		block, err := client_handler.GetBlock(ctx, blockHash, &verbosity)
 		assert.NoError(t, err)
 
 		// https://learnmeabitcoin.com/explorer/block/0000000071966c2b1d065fd446b1e485b2c9d9594acd2007ccbd5441cfc89444
-		actualBlock := &btcjson.GetBlockVerboseResult{
+		actualBlock := btcjson.GetBlockVerboseResult{
 			Hash:          "0000000071966c2b1d065fd446b1e485b2c9d9594acd2007ccbd5441cfc89444",
 			Confirmations: 867297,
 			StrippedSize:  0, // placeholder
@@ -70,9 +72,8 @@ func TestMockRPCServer(t *testing.T) {
 			Version:       1,
 			VersionHex:    "00000001",
 			MerkleRoot:    "8aa673bc752f2851fd645d6a0a92917e967083007d9c1684f9423b100540673f",
-			Tx: []string{
-				"01000000010000000000000000000000000000000000000000000000000000000000000000ffffffff0704ffff001d012bffffffff0100f2052a01000000434104a59e64c774923d003fae7491b2a7f75d6b7aa3f35606a8ff1cf06cd3317d16a41aa16928b1df1f631f31f28c7da35d4edad3603adb2338c4d4dd268f31530555ac00000000",
-			},
+			// the txid of the coinbase, the only transaction
+			Tx:           []string{"8aa673bc752f2851fd645d6a0a92917e967083007d9c1684f9423b100540673f"},
 			Time:         1231472369,
 			Nonce:        2258412857,
 			Bits:         "1d00ffff",
@@ -81,7 +82,30 @@ func TestMockRPCServer(t *testing.T) {
 			NextHash:     "00000000408c48f847aa786c2268fc3e6ec2af68e8468a34a28c61b7f1de0dc6",
 		}
 
-		assert.Equal(t, actualBlock, block)
+		assert.Equal(t, &client.GetBlockResult{GetBlockVerboseResult: actualBlock}, block)
+
+		// verbosity 2 decodes the transactions, without their block fields
+		verbosity = 2
+		block, err = client_handler.GetBlock(ctx, blockHash, &verbosity)
+		assert.NoError(t, err)
+		assert.Nil(t, block.Tx)
+		if assert.Len(t, block.RawTx, 1) {
+			assert.Equal(t, actualBlock.Tx[0], block.RawTx[0].Txid)
+			assert.Equal(t, coinbaseHex, block.RawTx[0].Hex)
+			assert.Empty(t, block.RawTx[0].BlockHash)
+			assert.Zero(t, block.RawTx[0].Confirmations)
+		}
+
+		// verbosity 0 serializes the block
+		verbosity = 0
+		block, err = client_handler.GetBlock(ctx, blockHash, &verbosity)
+		assert.NoError(t, err)
+		raw, err := hex.DecodeString(block.Hex)
+		assert.NoError(t, err)
+		msgBlock := &wire.MsgBlock{}
+		assert.NoError(t, msgBlock.Deserialize(bytes.NewReader(raw)))
+		assert.Equal(t, *blockHash, msgBlock.BlockHash())
+		assert.Equal(t, actualBlock.Tx[0], msgBlock.Transactions[0].TxHash().String())
 	})
 
 	t.Run("GetBlockError", func(t *testing.T) {
@@ -90,19 +114,19 @@ func TestMockRPCServer(t *testing.T) {
 
 		verbosity := 1
 
-		_, err = client_handler.GetBlock(blockHash, &verbosity)
+		_, err = client_handler.GetBlock(ctx, blockHash, &verbosity)
 		assert.Error(t, err)
 	})
 
 	t.Run("GetBlockCount", func(t *testing.T) {
-		blockCount, err := client_handler.GetBlockCount()
+		blockCount, err := client_handler.GetBlockCount(ctx)
 		assert.NoError(t, err)
 
-		assert.Equal(t, int64(10), blockCount)
+		assert.Equal(t, int32(10), blockCount)
 	})
 
 	t.Run("GetBlockHash", func(t *testing.T) {
-		blockHash, err := client_handler.GetBlockHash(5)
+		blockHash, err := client_handler.GetBlockHash(ctx, 5)
 		assert.NoError(t, err)
 
 		actualBlockHash, err := chainhash.NewHashFromStr("000000009b7262315dbf071787ad3656097b892abffd1f95a1a022f896f533fc")
@@ -112,7 +136,7 @@ func TestMockRPCServer(t *testing.T) {
 	})
 
 	t.Run("GetBlockHashError", func(t *testing.T) {
-		_, err := client_handler.GetBlockHash(15)
+		_, err := client_handler.GetBlockHash(ctx, 15)
 		assert.Error(t, err)
 	})
 
@@ -120,7 +144,7 @@ func TestMockRPCServer(t *testing.T) {
 		blockHash, err := chainhash.NewHashFromStr("0000000071966c2b1d065fd446b1e485b2c9d9594acd2007ccbd5441cfc89444")
 		assert.NoError(t, err)
 
-		blockHeader, err := client_handler.GetBlockHeader(blockHash, true)
+		blockHeader, err := client_handler.GetBlockHeader(ctx, blockHash, true)
 		assert.NoError(t, err)
 
 		// https://learnmeabitcoin.com/explorer/block/0000000071966c2b1d065fd446b1e485b2c9d9594acd2007ccbd5441cfc89444
@@ -149,7 +173,7 @@ func TestMockRPCServer(t *testing.T) {
 		blockHash, err := chainhash.NewHashFromStr("0000000071966c2b1d065fd446b1e485b2c9d9594acd2007ccbd5441cfc89222")
 		assert.NoError(t, err)
 
-		_, err = client_handler.GetBlockHeader(blockHash, true)
+		_, err = client_handler.GetBlockHeader(ctx, blockHash, true)
 		assert.Error(t, err)
 	})
 
@@ -157,7 +181,7 @@ func TestMockRPCServer(t *testing.T) {
 		txnHash, err := chainhash.NewHashFromStr("0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098")
 		assert.NoError(t, err)
 
-		txOut, err := client_handler.GetTxOut(txnHash, 0, false)
+		txOut, err := client_handler.GetTxOut(ctx, txnHash, 0, false)
 		assert.NoError(t, err)
 
 		// https://learnmeabitcoin.com/explorer/tx/0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098
@@ -181,7 +205,7 @@ func TestMockRPCServer(t *testing.T) {
 		txnHash, err := chainhash.NewHashFromStr("0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512099")
 		assert.NoError(t, err)
 
-		_, err = client_handler.GetTxOut(txnHash, 0, false)
+		_, err = client_handler.GetTxOut(ctx, txnHash, 0, false)
 		assert.Error(t, err)
 	})
 
@@ -189,7 +213,7 @@ func TestMockRPCServer(t *testing.T) {
 		txnHash, err := chainhash.NewHashFromStr("0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098")
 		assert.NoError(t, err)
 
-		_, err = client_handler.GetTxOut(txnHash, 1, false)
+		_, err = client_handler.GetTxOut(ctx, txnHash, 1, false)
 		assert.Error(t, err)
 	})
 
@@ -197,7 +221,7 @@ func TestMockRPCServer(t *testing.T) {
 		txnHash, err := chainhash.NewHashFromStr("0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098")
 		assert.NoError(t, err)
 
-		rawTx, err := client_handler.GetRawTransaction(txnHash, true, nil)
+		rawTx, err := client_handler.GetRawTransaction(ctx, txnHash, true, nil)
 		assert.NoError(t, err)
 
 		// https://learnmeabitcoin.com/explorer/tx/0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098
@@ -241,12 +265,12 @@ func TestMockRPCServer(t *testing.T) {
 		txnHash, err := chainhash.NewHashFromStr("0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512099")
 		assert.NoError(t, err)
 
-		_, err = client_handler.GetRawTransaction(txnHash, true, nil)
+		_, err = client_handler.GetRawTransaction(ctx, txnHash, true, nil)
 		assert.Error(t, err)
 	})
 
 	t.Run("GetNetworkInfo", func(t *testing.T) {
-		networkInfo, err := client_handler.GetNetworkInfo()
+		networkInfo, err := client_handler.GetNetworkInfo(ctx)
 		assert.NoError(t, err)
 
 		actualNetworkInfo := &btcjson.GetNetworkInfoResult{
@@ -305,4 +329,15 @@ func TestMockRPCServer(t *testing.T) {
 
 		assert.Equal(t, actualNetworkInfo, networkInfo)
 	})
+	t.Run("GetBlockChainInfo", func(t *testing.T) {
+		blockChainInfo, err := client_handler.GetBlockChainInfo(ctx)
+		assert.NoError(t, err)
+
+		assert.Equal(t, "main", blockChainInfo.Chain)
+		assert.Equal(t, int32(10), blockChainInfo.Blocks)
+		assert.Equal(t, int32(10), blockChainInfo.Headers)
+		assert.Equal(t, "000000002c05cc2e78923c34df87fd108b22221ac6076c18f3ade378a4d915e9", blockChainInfo.BestBlockHash)
+		assert.Equal(t, "0000000000000000000000000000000000000000000000000000000b000b000b", blockChainInfo.ChainWork)
+		assert.False(t, blockChainInfo.InitialBlockDownload)
+	})
 }
@@ -0,0 +1,228 @@
+package mockserver
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testNet4GenesisBlock is the genesis block of testnet4 (BIP94), which btcd
+// does not know yet.
+var testNet4GenesisBlock = func() *wire.MsgBlock {
+	const message = "03/May/2024 000000000000000000001ebd58c244970b3aa9d783bb001011fbe8ea8e98e00e"
+	// the bits of the genesis block, the extra nonce 4 and the message, like
+	// the genesis blocks of bitcoind
+	sigScript := append([]byte{0x04, 0xff, 0xff, 0x00, 0x1d, 0x01, 0x04, 0x4c, byte(len(message))}, message...)
+	// an unspendable payout to a public key of zeros
+	pkScript := append(append([]byte{33}, make([]byte, 33)...), 0xac)
+
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex), sigScript, nil))
+	coinbase.AddTxOut(wire.NewTxOut(50*btcutil.SatoshiPerBitcoin, pkScript))
+	return &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:    1,
+			MerkleRoot: coinbase.TxHash(),
+			Timestamp:  time.Unix(1714777860, 0),
+			Bits:       0x1d00ffff,
+			Nonce:      393743547,
+		},
+		Transactions: []*wire.MsgTx{coinbase},
+	}
+}()
+
+var testNet4GenesisHash = testNet4GenesisBlock.BlockHash()
+
+// TestNet4Params are the parameters of testnet4, the testnet3 rules with the
+// genesis block, magic and ports of testnet4. The timewarp fix of BIP94 is
+// not part of chaincfg, the difficulty of mined blocks follows it anyway, see
+// nextBits.
+var TestNet4Params = func() chaincfg.Params {
+	params := chaincfg.TestNet3Params
+	params.Name = "testnet4"
+	params.Net = wire.BitcoinNet(0x283f161c)
+	params.DefaultPort = "48333"
+	params.DNSSeeds = nil
+	params.GenesisBlock = testNet4GenesisBlock
+	params.GenesisHash = &testNet4GenesisHash
+	params.Checkpoints = nil
+	return params
+}()
+
+// Network is a network the mock can serve, selected by its name.
+type Network struct {
+	// Name is the name of the network, as in the -network flag
+	Name string
+	// Chain is the name of the network reported by getblockchaininfo
+	Chain string
+	// RPCPort is the default rpc port of bitcoind on the network
+	RPCPort int
+	Params  *chaincfg.Params
+}
+
+// Networks are the networks the mock can serve.
+var Networks = []Network{
+	{Name: "mainnet", Chain: "main", RPCPort: 8332, Params: &chaincfg.MainNetParams},
+	{Name: "testnet3", Chain: "test", RPCPort: 18332, Params: &chaincfg.TestNet3Params},
+	{Name: "testnet4", Chain: "testnet4", RPCPort: 48332, Params: &TestNet4Params},
+	{Name: "signet", Chain: "signet", RPCPort: 38332, Params: &chaincfg.SigNetParams},
+	{Name: "regtest", Chain: "regtest", RPCPort: 18443, Params: &chaincfg.RegressionNetParams},
+}
+
+// NetworkByName returns the network with the given name, see Networks.
+func NetworkByName(name string) (Network, error) {
+	var names []string
+	for _, network := range Networks {
+		if network.Name == name {
+			return network, nil
+		}
+		names = append(names, network.Name)
+	}
+	return Network{}, fmt.Errorf("unknown network %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// networkOf returns the network of params, mainnet for unknown params.
+func networkOf(params *chaincfg.Params) Network {
+	for _, network := range Networks {
+		if network.Params.Net == params.Net {
+			return network
+		}
+	}
+	return Networks[0]
+}
+
+// CheckNetwork checks that the chain of view belongs to the network of
+// params: its genesis block, when loaded, must be the genesis block of the
+// network, the difficulty of its blocks must be within the proof of work
+// limit of the network and the addresses its transactions pay to must be
+// encoded for the network.
+func CheckNetwork(view ChainView, params *chaincfg.Params) error {
+	if genesis, ok := view.BlockHeaderByHeight(0); ok && genesis.Hash != params.GenesisHash.String() {
+		return fmt.Errorf("genesis block %s is not the %s genesis block %s", genesis.Hash, params.Name, params.GenesisHash)
+	}
+
+	bestBlockHeader, ok := view.BestBlockHeader()
+	if !ok {
+		return nil
+	}
+	for height := int32(0); height <= bestBlockHeader.Height; height++ {
+		blockHeader, ok := view.BlockHeaderByHeight(height)
+		if !ok {
+			continue
+		}
+		bits, err := parseBits(blockHeader)
+		if err != nil {
+			return err
+		}
+		if blockchain.CompactToBig(bits).Cmp(params.PowLimit) > 0 {
+			return fmt.Errorf("block %s: difficulty bits %s are below the %s minimum %08x",
+				blockHeader.Hash, blockHeader.Bits, params.Name, params.PowLimitBits)
+		}
+		for _, transaction := range view.BlockTransactions(blockHeader.Hash) {
+			if err := checkAddresses(transaction, params); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkAddresses checks that the outputs of the transaction pay to addresses
+// of the network of params.
+func checkAddresses(transaction btcjson.TxRawResult, params *chaincfg.Params) error {
+	for _, vout := range transaction.Vout {
+		address := vout.ScriptPubKey.Address
+		if address == "" {
+			continue
+		}
+		decoded, err := btcutil.DecodeAddress(address, params)
+		if err != nil || !decoded.IsForNet(params) {
+			return fmt.Errorf("transaction %s: address %s is not a %s address", transaction.Txid, address, params.Name)
+		}
+	}
+	return nil
+}
+
+// nextBits returns the difficulty bits of the block at the height after the
+// tip, mined at timestamp, by the difficulty rules of params. header looks
+// up the active chain up to the tip by height. The difficulty is kept when
+// the blocks it is computed from are not loaded.
+func nextBits(
+	header func(height int32) (btcjson.GetBlockHeaderVerboseResult, bool),
+	tip btcjson.GetBlockHeaderVerboseResult,
+	timestamp time.Time,
+	params *chaincfg.Params,
+) (uint32, error) {
+	bits, err := parseBits(tip)
+	if err != nil || params.PoWNoRetargeting {
+		return bits, err
+	}
+
+	blocksPerRetarget := int32(params.TargetTimespan / params.TargetTimePerBlock)
+	height := tip.Height + 1
+	if height%blocksPerRetarget != 0 {
+		if !params.ReduceMinDifficulty {
+			return bits, nil
+		}
+		// a block 20 minutes after its parent may have the minimum
+		// difficulty, the next ones return to the difficulty of the last
+		// block which did not
+		if timestamp.After(time.Unix(tip.Time, 0).Add(params.MinDiffReductionTime)) {
+			return params.PowLimitBits, nil
+		}
+		for h := tip.Height; bits == params.PowLimitBits && h%blocksPerRetarget != 0; {
+			h--
+			blockHeader, ok := header(h)
+			if !ok {
+				break
+			}
+			if bits, err = parseBits(blockHeader); err != nil {
+				return 0, err
+			}
+		}
+		return bits, nil
+	}
+
+	first, ok := header(height - blocksPerRetarget)
+	if !ok {
+		return bits, nil
+	}
+	// BIP94 retargets from the difficulty of the first block of the period,
+	// which is never a minimum difficulty exception
+	if params.Net == TestNet4Params.Net {
+		if bits, err = parseBits(first); err != nil {
+			return 0, err
+		}
+	}
+	targetTimespan := int64(params.TargetTimespan / time.Second)
+	timespan := min(max(tip.Time-first.Time, targetTimespan/params.RetargetAdjustmentFactor),
+		targetTimespan*params.RetargetAdjustmentFactor)
+	target := new(big.Int).Mul(blockchain.CompactToBig(bits), big.NewInt(timespan))
+	target.Div(target, big.NewInt(targetTimespan))
+	if target.Cmp(params.PowLimit) > 0 {
+		target = params.PowLimit
+	}
+	return blockchain.BigToCompact(target), nil
+}
+
+func parseBits(blockHeader btcjson.GetBlockHeaderVerboseResult) (uint32, error) {
+	bits, err := strconv.ParseUint(blockHeader.Bits, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("block %s: invalid bits: %w", blockHeader.Hash, err)
+	}
+	return uint32(bits), nil
+}
+
+// GenesisContent returns the chain of the genesis block of params alone.
+func GenesisContent(params *chaincfg.Params) (DataContent, error) {
+	return BlocksToContent([]*wire.MsgBlock{params.GenesisBlock}, 0, params)
+}
@@ -0,0 +1,133 @@
+package mockserver
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworks(t *testing.T) {
+	assert.Equal(t, "00000000da84f2bafbbc53dee25a72ae507ff4914b867c565be350b0da8bf043", TestNet4Params.GenesisHash.String())
+
+	network, err := NetworkByName("testnet4")
+	require.NoError(t, err)
+	assert.Equal(t, 48332, network.RPCPort)
+	_, err = NetworkByName("testnet")
+	assert.ErrorContains(t, err, `unknown network "testnet", expected one of mainnet, testnet3, testnet4, signet, regtest`)
+
+	for _, network := range Networks {
+		dataContent, err := GenesisContent(network.Params)
+		require.NoError(t, err)
+		dataStore := &DataStore{}
+		require.NoError(t, dataStore.Replace(dataContent))
+
+		// the network is recognized by its genesis block
+		serverHandler := &MockServerHandler{Store: dataStore}
+		blockChainInfo, err := serverHandler.GetBlockChainInfo()
+		require.NoError(t, err)
+		assert.Equal(t, network.Chain, blockChainInfo.Chain)
+		assert.NoError(t, CheckNetwork(dataStore.current(), network.Params))
+	}
+}
+
+func TestCheckNetwork(t *testing.T) {
+	dataStore := &DataStore{}
+	dataStore.ReadJsonFiles("../data/mainnet_oldest_blocks.json")
+	assert.NoError(t, CheckNetwork(dataStore.current(), &chaincfg.MainNetParams))
+	assert.ErrorContains(t, CheckNetwork(dataStore.current(), &chaincfg.SigNetParams),
+		"genesis block 000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f is not the signet genesis block")
+
+	// the network of the handler takes precedence over the genesis block
+	serverHandler := &MockServerHandler{Store: dataStore, Params: &chaincfg.RegressionNetParams}
+	blockChainInfo, err := serverHandler.GetBlockChainInfo()
+	require.NoError(t, err)
+	assert.Equal(t, "regtest", blockChainInfo.Chain)
+
+	// without the genesis block the difficulty and addresses tell
+	params := &chaincfg.RegressionNetParams
+	dataContent, err := GenesisContent(params)
+	require.NoError(t, err)
+	require.NoError(t, dataStore.Replace(dataContent))
+	address, err := btcutil.NewAddressWitnessPubKeyHash(make([]byte, 20), params)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(address)
+	require.NoError(t, err)
+	miner := &Miner{Store: dataStore, PkScript: pkScript}
+	_, err = miner.Mine(1)
+	require.NoError(t, err)
+	dataContent = dataStore.current().Content()
+	dataContent.BlockHeaders = dataContent.BlockHeaders[1:]
+	view := newChainState(dataContent)
+	assert.NoError(t, CheckNetwork(view, params))
+	assert.ErrorContains(t, CheckNetwork(view, &chaincfg.MainNetParams), "difficulty bits 207fffff are below the mainnet minimum 1d00ffff")
+
+	assert.Equal(t, address.EncodeAddress(), dataContent.Transactions[len(dataContent.Transactions)-1].Vout[0].ScriptPubKey.Address)
+	assert.ErrorContains(t, checkAddresses(dataContent.Transactions[len(dataContent.Transactions)-1], &chaincfg.TestNet3Params),
+		"address "+address.EncodeAddress()+" is not a testnet3 address")
+}
+
+func TestNextBits(t *testing.T) {
+	const start = 1700000000
+	const week = 7 * 24 * 60 * 60
+	scaled := func(bits uint32, num, den int64) uint32 {
+		target := blockchain.CompactToBig(bits)
+		target.Mul(target, big.NewInt(num))
+		return blockchain.BigToCompact(target.Div(target, big.NewInt(den)))
+	}
+	header := func(height int32, time int64, bits string) btcjson.GetBlockHeaderVerboseResult {
+		return btcjson.GetBlockHeaderVerboseResult{Height: height, Time: time, Bits: bits}
+	}
+
+	for _, test := range []struct {
+		name      string
+		previous  btcjson.GetBlockHeaderVerboseResult
+		tip       btcjson.GetBlockHeaderVerboseResult
+		timestamp int64
+		params    *chaincfg.Params
+		bits      uint32
+	}{
+		{"NoRetargeting", header(2014, start, "207fffff"), header(2015, start, "207fffff"), start + week,
+			&chaincfg.RegressionNetParams, 0x207fffff},
+		{"Kept", header(99, start, "1c00ffff"), header(100, start, "1c00ffff"), start + 3600,
+			&chaincfg.MainNetParams, 0x1c00ffff},
+		{"MinDifficulty", header(99, start, "1c00ffff"), header(100, start, "1c00ffff"), start + 1201,
+			&chaincfg.TestNet3Params, 0x1d00ffff},
+		{"AfterMinDifficulty", header(99, start, "1c00ffff"), header(100, start, "1d00ffff"), start + 60,
+			&chaincfg.TestNet3Params, 0x1c00ffff},
+		{"Retarget", header(2014, start, "1c00ffff"), header(2015, start+week, "1c00ffff"), start + week,
+			&chaincfg.MainNetParams, scaled(0x1c00ffff, 1, 2)},
+		{"RetargetClamped", header(2014, start, "1c00ffff"), header(2015, start+20*week, "1c00ffff"), start + 20*week,
+			&chaincfg.MainNetParams, scaled(0x1c00ffff, 4, 1)},
+		{"RetargetLimit", header(2014, start, "1d00ffff"), header(2015, start+4*week, "1d00ffff"), start + 4*week,
+			&chaincfg.MainNetParams, 0x1d00ffff},
+		{"RetargetTip", header(2014, start, "1c00ffff"), header(2015, start+week, "1c7fffff"), start + week,
+			&chaincfg.TestNet3Params, scaled(0x1c7fffff, 1, 2)},
+		{"RetargetFirstBlock", header(2014, start, "1c00ffff"), header(2015, start+week, "1c7fffff"), start + week,
+			&TestNet4Params, scaled(0x1c00ffff, 1, 2)},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			// the first block of the retarget period is at height 0
+			headers := map[int32]btcjson.GetBlockHeaderVerboseResult{
+				0:                    header(0, start, "1c00ffff"),
+				test.previous.Height: test.previous,
+				test.tip.Height:      test.tip,
+			}
+			header := func(height int32) (btcjson.GetBlockHeaderVerboseResult, bool) {
+				blockHeader, ok := headers[height]
+				return blockHeader, ok
+			}
+			bits, err := nextBits(header, test.tip, time.Unix(test.timestamp, 0), test.params)
+			require.NoError(t, err)
+			assert.Equal(t, fmt.Sprintf("%08x", test.bits), fmt.Sprintf("%08x", bits))
+		})
+	}
+}
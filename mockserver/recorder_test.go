@@ -0,0 +1,140 @@
+package mockserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/gonative-cc/btc-mock-node/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	upstream := NewMockRPCServer("../data/mainnet_oldest_blocks.json")
+	defer upstream.Close()
+
+	recorder := NewRecorder(upstream.URL)
+	proxy := httptest.NewServer(recorder)
+	defer proxy.Close()
+
+	ctx := context.Background()
+	client_handler, close_handler, err := client.New(ctx, proxy.URL)
+	require.NoError(t, err)
+	defer close_handler()
+
+	blockHash, err := chainhash.NewHashFromStr("0000000071966c2b1d065fd446b1e485b2c9d9594acd2007ccbd5441cfc89444")
+	require.NoError(t, err)
+	txHash, err := chainhash.NewHashFromStr("0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098")
+	require.NoError(t, err)
+
+	// responses are forwarded unchanged
+	blockHeader, err := client_handler.GetBlockHeader(ctx, blockHash, true)
+	require.NoError(t, err)
+	assert.Equal(t, int32(7), blockHeader.Height)
+	transaction, err := client_handler.GetRawTransaction(ctx, txHash, true, nil)
+	require.NoError(t, err)
+	networkInfo, err := client_handler.GetNetworkInfo(ctx)
+	require.NoError(t, err)
+	_, err = client_handler.GetBestBlockHash(ctx)
+	require.NoError(t, err)
+
+	// errors are forwarded too and nothing is recorded
+	_, err = client_handler.GetBlockHeader(ctx, &chainhash.Hash{}, true)
+	assert.Error(t, err)
+
+	// a batch with a bitcoind method name
+	resp, err := http.Post(proxy.URL, "application/json", strings.NewReader(
+		`[{"jsonrpc": "2.0", "id": 1, "method": "getblockheader", "params": ["000000002c05cc2e78923c34df87fd108b22221ac6076c18f3ade378a4d915e9", true]},`+
+			`{"jsonrpc": "2.0", "id": 2, "method": "ping", "params": [1]}]`,
+	))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	recorded := recorder.Content()
+	require.Len(t, recorded.BlockHeaders, 2)
+	assert.Equal(t, *blockHeader, recorded.BlockHeaders[0])
+	assert.Equal(t, int32(10), recorded.BlockHeaders[1].Height)
+	assert.Equal(t, []btcjson.TxRawResult{*transaction}, recorded.Transactions)
+	assert.Equal(t, *networkInfo, recorded.NetworkInfo)
+
+	// the saved session is replayed offline
+	path := filepath.Join(t.TempDir(), "session.json")
+	require.NoError(t, recorder.Save(path))
+	replay := NewMockServerHandler(path)
+	replayed, err := replay.GetBlockHeader(blockHash, true)
+	require.NoError(t, err)
+	assert.Equal(t, blockHeader, replayed)
+}
+
+// TestRecorderBlockTxids records a verbosity 1 block, whose transactions are
+// txids, and a verbosity 2 block, whose transactions are objects.
+func TestRecorderBlockTxids(t *testing.T) {
+	mock := NewMockServerHandler("../data/mainnet_oldest_blocks.json")
+	const blockHash = "0000000071966c2b1d065fd446b1e485b2c9d9594acd2007ccbd5441cfc89444"
+	hash, err := chainhash.NewHashFromStr(blockHash)
+	require.NoError(t, err)
+	view, err := mock.Store.View()
+	require.NoError(t, err)
+	coinbase := view.BlockTransactions(blockHash)[0]
+	blockHeader, _ := view.BlockHeaderByHash(blockHash)
+	view.Release()
+
+	upstream := httptest.NewServer(NewHTTPHandler(mock))
+	defer upstream.Close()
+	recorder := NewRecorder(upstream.URL)
+	proxy := httptest.NewServer(recorder)
+	defer proxy.Close()
+	call := func(method string, params ...any) {
+		body, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+		require.NoError(t, err)
+		resp, err := http.Post(proxy.URL, "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	replay := func() *MockServerHandler {
+		path := filepath.Join(t.TempDir(), "session.json")
+		require.NoError(t, recorder.Save(path))
+		return NewMockServerHandler(path)
+	}
+	txHash, err := chainhash.NewHashFromStr(coinbase.Txid)
+	require.NoError(t, err)
+
+	// the block lists its transaction, known by its txid and block only
+	call("getblock", blockHash, 1)
+	replayed, err := replay().GetBlock(hash, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{coinbase.Txid}, replayed.Tx)
+	placeholder, err := replay().GetRawTransaction(txHash, true, nil)
+	require.NoError(t, err)
+	assert.Equal(t, coinbase.Txid, placeholder.Txid)
+	assert.Equal(t, blockHash, placeholder.BlockHash)
+	assert.Empty(t, placeholder.Hex)
+
+	// getrawtransaction fills the placeholder in, a later getblock keeps it
+	call("getrawtransaction", coinbase.Txid, true)
+	call("getblock", blockHash, 1)
+	assert.Equal(t, []btcjson.TxRawResult{coinbase}, recorder.Content().Transactions)
+	verbosity := 2
+	replayed, err = replay().GetBlock(hash, &verbosity)
+	require.NoError(t, err)
+	require.Len(t, replayed.RawTx, 1)
+	assert.Equal(t, coinbase.Hex, replayed.RawTx[0].Hex)
+
+	// a verbosity 2 block records its transactions with their block fields
+	recorder = NewRecorder(upstream.URL)
+	proxy.Config.Handler = recorder
+	call("getblock", blockHash, 2)
+	inBlock := coinbase
+	inBlock.Confirmations = uint64(blockHeader.Confirmations)
+	assert.Equal(t, []btcjson.TxRawResult{inBlock}, recorder.Content().Transactions)
+}
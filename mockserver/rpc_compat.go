@@ -0,0 +1,127 @@
+package mockserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/filecoin-project/go-jsonrpc"
+)
+
+// This file bridges the gap between go-jsonrpc and the json-rpc conventions
+// of bitcoind, which clients like the btcd rpcclient rely on.
+
+// RPCError is the error returned by the handler methods. go-jsonrpc answers
+// any other error with code 1, an RPCError keeps its bitcoind error code.
+type RPCError btcjson.RPCError
+
+func (e *RPCError) Error() string {
+	return (*btcjson.RPCError)(e).Error()
+}
+
+func (e *RPCError) ToJSONRPCError() (jsonrpc.JSONRPCError, error) {
+	return jsonrpc.JSONRPCError{Code: jsonrpc.ErrorCode(e.Code), Message: e.Message}, nil
+}
+
+func (e *RPCError) FromJSONRPCError(jsonErr jsonrpc.JSONRPCError) error {
+	e.Code = btcjson.RPCErrorCode(jsonErr.Code)
+	e.Message = jsonErr.Message
+	return nil
+}
+
+// decodeBoolParam decodes bool params the way bitcoind does: verbose flags
+// are sent as true/false by some clients and as 1/0 by others, e.g. the btcd
+// rpcclient for getrawtransaction. null, an omitted param, is false.
+func decodeBoolParam(_ context.Context, data []byte) (reflect.Value, error) {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return reflect.Value{}, err
+	}
+	switch value := value.(type) {
+	case nil:
+		return reflect.ValueOf(false), nil
+	case bool:
+		return reflect.ValueOf(value), nil
+	case float64:
+		return reflect.ValueOf(value != 0), nil
+	}
+	return reflect.Value{}, fmt.Errorf("expected a bool or a number, got %s", data)
+}
+
+// handlerParamCounts returns the number of params of every handler method by
+// its lowercase name without underscores, which matches both the Go and the
+// bitcoind method names.
+func handlerParamCounts() map[string]int {
+	handlerType := reflect.TypeOf(&MockServerHandler{})
+	counts := make(map[string]int, handlerType.NumMethod())
+	for i := 0; i < handlerType.NumMethod(); i++ {
+		method := handlerType.Method(i)
+		// the receiver is the first param
+		counts[strings.ToLower(method.Name)] = method.Type.NumIn() - 1
+	}
+	return counts
+}
+
+// withOptionalParams pads the params of every call with nulls up to the
+// params of the handler method. go-jsonrpc requires all of them, while
+// bitcoind treats the trailing ones as optional.
+func withOptionalParams(next http.Handler) http.Handler {
+	paramCounts := handlerParamCounts()
+
+	pad := func(request map[string]json.RawMessage) {
+		var method string
+		if json.Unmarshal(request["method"], &method) != nil {
+			return
+		}
+		count, ok := paramCounts[strings.ReplaceAll(normalizeMethod(method), "_", "")]
+		if !ok {
+			return
+		}
+		var params []json.RawMessage
+		if raw, ok := request["params"]; ok && json.Unmarshal(raw, &params) != nil {
+			// named params, go-jsonrpc rejects them anyway
+			return
+		}
+		if len(params) >= count {
+			return
+		}
+		for len(params) < count {
+			params = append(params, json.RawMessage("null"))
+		}
+		request["params"], _ = json.Marshal(params)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+			var requests []map[string]json.RawMessage
+			if json.Unmarshal(body, &requests) == nil {
+				for _, request := range requests {
+					pad(request)
+				}
+				body, _ = json.Marshal(requests)
+			}
+		} else {
+			var request map[string]json.RawMessage
+			if json.Unmarshal(body, &request) == nil {
+				pad(request)
+				body, _ = json.Marshal(request)
+			}
+		}
+
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		next.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,152 @@
+package mockserver
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRPCClientCompatibility connects the btcd rpcclient, in the HTTP POST
+// mode used against bitcoind, to the mock to check it is a drop-in backend.
+func TestRPCClientCompatibility(t *testing.T) {
+	mockService := NewMockRPCServer("../data/mainnet_oldest_blocks.json")
+	defer mockService.Close()
+
+	serverURL, err := url.Parse(mockService.URL)
+	require.NoError(t, err)
+	rpcClient, err := rpcclient.New(&rpcclient.ConnConfig{
+		Host:         serverURL.Host,
+		User:         "user",
+		Pass:         "pass",
+		HTTPPostMode: true,
+		DisableTLS:   true,
+	}, nil)
+	require.NoError(t, err)
+	defer rpcClient.Shutdown()
+
+	blockHash, err := chainhash.NewHashFromStr("0000000071966c2b1d065fd446b1e485b2c9d9594acd2007ccbd5441cfc89444")
+	require.NoError(t, err)
+	txHash, err := chainhash.NewHashFromStr("0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098")
+	require.NoError(t, err)
+	// the coinbase, the only transaction of the block
+	const coinbaseTxid = "8aa673bc752f2851fd645d6a0a92917e967083007d9c1684f9423b100540673f"
+
+	t.Run("BackendVersion", func(t *testing.T) {
+		// the mock answers getinfo, so it is detected as a btcd backend
+		version, err := rpcClient.BackendVersion()
+		require.NoError(t, err)
+		assert.IsType(t, rpcclient.BtcdVersion(0), version)
+	})
+
+	t.Run("GetBestBlockHash", func(t *testing.T) {
+		bestBlockHash, err := rpcClient.GetBestBlockHash()
+		require.NoError(t, err)
+		assert.Equal(t, "000000002c05cc2e78923c34df87fd108b22221ac6076c18f3ade378a4d915e9", bestBlockHash.String())
+	})
+
+	t.Run("GetBlockCount", func(t *testing.T) {
+		blockCount, err := rpcClient.GetBlockCount()
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), blockCount)
+	})
+
+	t.Run("GetBlockHash", func(t *testing.T) {
+		hash, err := rpcClient.GetBlockHash(7)
+		require.NoError(t, err)
+		assert.Equal(t, blockHash, hash)
+
+		_, err = rpcClient.GetBlockHash(15)
+		var rpcErr *btcjson.RPCError
+		require.ErrorAs(t, err, &rpcErr)
+		assert.Equal(t, btcjson.ErrRPCOutOfRange, rpcErr.Code)
+	})
+
+	t.Run("GetBlockVerbose", func(t *testing.T) {
+		block, err := rpcClient.GetBlockVerbose(blockHash)
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), block.Height)
+		assert.Equal(t, "000000003031a0e73735690c5a1ff2a4be82553b2a12b776fbd3a215dc8f778d", block.PreviousHash)
+		assert.Equal(t, []string{coinbaseTxid}, block.Tx)
+	})
+
+	t.Run("GetBlockVerboseTx", func(t *testing.T) {
+		block, err := rpcClient.GetBlockVerboseTx(blockHash)
+		require.NoError(t, err)
+		require.Len(t, block.Tx, 1)
+		assert.Equal(t, coinbaseTxid, block.Tx[0].Txid)
+	})
+
+	t.Run("GetBlock", func(t *testing.T) {
+		block, err := rpcClient.GetBlock(blockHash)
+		require.NoError(t, err)
+		assert.Equal(t, *blockHash, block.BlockHash())
+		require.Len(t, block.Transactions, 1)
+		assert.Equal(t, coinbaseTxid, block.Transactions[0].TxHash().String())
+	})
+
+	t.Run("GetBlockHeaderVerbose", func(t *testing.T) {
+		blockHeader, err := rpcClient.GetBlockHeaderVerbose(blockHash)
+		require.NoError(t, err)
+		assert.Equal(t, int32(7), blockHeader.Height)
+		assert.Equal(t, blockHash.String(), blockHeader.Hash)
+
+		_, err = rpcClient.GetBlockHeaderVerbose(&chainhash.Hash{})
+		var rpcErr *btcjson.RPCError
+		require.ErrorAs(t, err, &rpcErr)
+		assert.Equal(t, btcjson.ErrRPCBlockNotFound, rpcErr.Code)
+	})
+
+	t.Run("GetRawTransactionVerbose", func(t *testing.T) {
+		transaction, err := rpcClient.GetRawTransactionVerbose(txHash)
+		require.NoError(t, err)
+		assert.Equal(t, txHash.String(), transaction.Txid)
+		assert.Equal(t, "00000000839a8e6886ab5951d76f411475428afc90947ee320161bbf18eb6048", transaction.BlockHash)
+	})
+
+	t.Run("GetTxOut", func(t *testing.T) {
+		txOut, err := rpcClient.GetTxOut(txHash, 0, true)
+		require.NoError(t, err)
+		assert.Equal(t, 50.0, txOut.Value)
+	})
+
+	t.Run("GetNetworkInfo", func(t *testing.T) {
+		networkInfo, err := rpcClient.GetNetworkInfo()
+		require.NoError(t, err)
+		assert.Equal(t, "/Satoshi:26.0.0/", networkInfo.SubVersion)
+	})
+
+	t.Run("GetBlockChainInfo", func(t *testing.T) {
+		blockChainInfo, err := rpcClient.GetBlockChainInfo()
+		require.NoError(t, err)
+		assert.Equal(t, "main", blockChainInfo.Chain)
+		assert.Equal(t, int32(10), blockChainInfo.Blocks)
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		batchClient, err := rpcclient.NewBatch(&rpcclient.ConnConfig{
+			Host:         serverURL.Host,
+			User:         "user",
+			Pass:         "pass",
+			HTTPPostMode: true,
+			DisableTLS:   true,
+		})
+		require.NoError(t, err)
+		defer batchClient.Shutdown()
+
+		blockCount := batchClient.GetBlockCountAsync()
+		bestBlockHash := batchClient.GetBestBlockHashAsync()
+		require.NoError(t, batchClient.Send())
+
+		count, err := blockCount.Receive()
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), count)
+		hash, err := bestBlockHash.Receive()
+		require.NoError(t, err)
+		assert.Equal(t, "000000002c05cc2e78923c34df87fd108b22221ac6076c18f3ade378a4d915e9", hash.String())
+	})
+}
@@ -0,0 +1,243 @@
+package mockserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is a timeline of operations on the mock, so end-to-end tests can
+// script the chain without a Go test driver. It is read from yaml or json:
+//
+//	steps:
+//	  - load: [fixtures/chain.json]
+//	  - after: 5s
+//	    mine: 2
+//	  - after: 10s
+//	    reorg: 1
+//	  - at_block: 15
+//	    faults:
+//	      getblock: {mode: rpc_error}
+//
+// The steps run in order, each one once its trigger fired.
+type Scenario struct {
+	Steps []ScenarioStep `json:"steps"`
+}
+
+// ScenarioStep is a step of a scenario: at most one trigger, the step runs
+// right after the previous one without, and its actions in the order of the
+// fields.
+type ScenarioStep struct {
+	// At triggers the step at this time since the scenario started, on the
+	// clock of the mock, so setmocktime and mock_advancetime fire it too.
+	At *Duration `json:"at,omitempty"`
+	// After triggers the step at this time since the previous step ran, on
+	// the clock of the mock.
+	After *Duration `json:"after,omitempty"`
+	// AtBlock triggers the step once the best block reaches this height.
+	AtBlock *int32 `json:"at_block,omitempty"`
+
+	// AdvanceTime moves the clock of the mock forward, see
+	// MockServerHandler.MockAdvanceTime.
+	AdvanceTime *Duration `json:"advance_time,omitempty"`
+	// Load replaces the chain with these data files, see LoadDataFiles. On a
+	// handler with Params they must belong to its network, see CheckNetwork,
+	// and raw block fixtures are decoded for it.
+	// Relative paths are relative to the scenario file.
+	Load []string `json:"load,omitempty"`
+	// Mine mines blocks, see Miner.Mine.
+	Mine int `json:"mine,omitempty"`
+	// Reorg replaces this many blocks, see Miner.Reorg.
+	Reorg int `json:"reorg,omitempty"`
+	// SubmitTxs adds raw transactions in hex to the mempool.
+	SubmitTxs []string `json:"submit_txs,omitempty"`
+	// ClearFaults removes all faults, before Faults are set.
+	ClearFaults bool `json:"clear_faults,omitempty"`
+	// Faults sets the faults of methods, see FaultInjector.SetFault.
+	Faults map[string]Fault `json:"faults,omitempty"`
+}
+
+// scenarioPollInterval is how often an AtBlock trigger checks the chain.
+const scenarioPollInterval = 100 * time.Millisecond
+
+// ReadScenario reads a yaml or json scenario file.
+func ReadScenario(path string) (Scenario, error) {
+	byteValue, err := os.ReadFile(path)
+	if err != nil {
+		return Scenario{}, err
+	}
+	scenario, err := ParseScenario(byteValue)
+	if err != nil {
+		return Scenario{}, fmt.Errorf("scenario %s: %w", path, err)
+	}
+	for i := range scenario.Steps {
+		for j, load := range scenario.Steps[i].Load {
+			if !filepath.IsAbs(load) {
+				scenario.Steps[i].Load[j] = filepath.Join(filepath.Dir(path), load)
+			}
+		}
+	}
+	return scenario, nil
+}
+
+// ParseScenario parses a yaml or json scenario. Durations are strings like
+// "5s", as in fault configs.
+func ParseScenario(data []byte) (Scenario, error) {
+	// json is yaml, and going through json reuses the json field names and
+	// the Duration decoding
+	var value any
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return Scenario{}, err
+	}
+	jsonValue, err := json.Marshal(value)
+	if err != nil {
+		return Scenario{}, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonValue))
+	decoder.DisallowUnknownFields()
+	var scenario Scenario
+	if err := decoder.Decode(&scenario); err != nil {
+		return Scenario{}, err
+	}
+
+	for i, step := range scenario.Steps {
+		if err := validateScenarioStep(step); err != nil {
+			return Scenario{}, fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+	return scenario, nil
+}
+
+func validateScenarioStep(step ScenarioStep) error {
+	triggers := 0
+	for _, set := range []bool{step.At != nil, step.After != nil, step.AtBlock != nil} {
+		if set {
+			triggers++
+		}
+	}
+	if triggers > 1 {
+		return errors.New("at most one of at, after and at_block can be set")
+	}
+	if step.Mine < 0 || step.Reorg < 0 || (step.AdvanceTime != nil && *step.AdvanceTime < 0) {
+		return errors.New("advance_time, mine and reorg can not be negative")
+	}
+	if step.AdvanceTime == nil && len(step.Load) == 0 && step.Mine == 0 && step.Reorg == 0 && len(step.SubmitTxs) == 0 &&
+		!step.ClearFaults && len(step.Faults) == 0 {
+		return errors.New("no action")
+	}
+	for method, fault := range step.Faults {
+		if err := validateFault(fault); err != nil {
+			return fmt.Errorf("%s: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// RunScenario runs the steps of the scenario against the handler, mining with
+// miner, and blocks until all of them ran or ctx is done. It stops at the
+// first failing step.
+func RunScenario(ctx context.Context, handler *MockServerHandler, miner *Miner, scenario Scenario) error {
+	start := handler.Clock.Now()
+	previous := start
+	for i, step := range scenario.Steps {
+		if err := waitScenarioTrigger(ctx, handler, step, start, previous); err != nil {
+			return err
+		}
+		if err := runScenarioStep(handler, miner, step); err != nil {
+			return fmt.Errorf("scenario step %d: %w", i+1, err)
+		}
+		previous = handler.Clock.Now()
+		log.Info().Msgf("Ran scenario step %d of %d", i+1, len(scenario.Steps))
+	}
+	return nil
+}
+
+func waitScenarioTrigger(ctx context.Context, handler *MockServerHandler, step ScenarioStep, start, previous time.Time) error {
+	var deadline time.Time
+	switch {
+	case step.At != nil:
+		deadline = start.Add(time.Duration(*step.At))
+	case step.After != nil:
+		deadline = previous.Add(time.Duration(*step.After))
+	case step.AtBlock != nil:
+		ticker := time.NewTicker(scenarioPollInterval)
+		defer ticker.Stop()
+		for {
+			if height, err := handler.GetBlockCount(); err == nil && height >= *step.AtBlock {
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		}
+	}
+
+	if deadline.IsZero() {
+		return nil
+	}
+	return handler.Clock.waitUntil(ctx, deadline)
+}
+
+func runScenarioStep(handler *MockServerHandler, miner *Miner, step ScenarioStep) error {
+	if step.AdvanceTime != nil {
+		if handler.Clock == nil {
+			return errNoClock
+		}
+		handler.Clock.Advance(time.Duration(*step.AdvanceTime))
+	}
+	if len(step.Load) > 0 {
+		dataContent, err := DataLoader{Params: handler.Params}.Load(step.Load...)
+		if err != nil {
+			return err
+		}
+		if handler.Params != nil {
+			if err := CheckNetwork(newChainState(dataContent), handler.Params); err != nil {
+				return err
+			}
+		}
+		if err := handler.Store.Replace(dataContent); err != nil {
+			return err
+		}
+	}
+	if step.Mine > 0 {
+		if _, err := miner.Mine(step.Mine); err != nil {
+			return err
+		}
+	}
+	if step.Reorg > 0 {
+		if _, err := miner.Reorg(step.Reorg); err != nil {
+			return err
+		}
+	}
+	for _, txHex := range step.SubmitTxs {
+		if _, err := miner.SubmitTransaction(txHex); err != nil {
+			return err
+		}
+	}
+
+	if !step.ClearFaults && len(step.Faults) == 0 {
+		return nil
+	}
+	if handler.Faults == nil {
+		return errNoFaultInjector
+	}
+	if step.ClearFaults {
+		_ = handler.Faults.SetFaults(nil)
+	}
+	for method, fault := range step.Faults {
+		if err := handler.Faults.SetFault(method, fault); err != nil {
+			return err
+		}
+	}
+	return nil
+}
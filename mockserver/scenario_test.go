@@ -0,0 +1,153 @@
+package mockserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScenario(t *testing.T) {
+	yamlScenario, err := ParseScenario([]byte(`
+steps:
+  - load: [chain.json]
+  - after: 5s
+    mine: 2
+  - at_block: 15
+    faults:
+      getblock: {mode: rpc_error, rate: 1}
+`))
+	require.NoError(t, err)
+	jsonScenario, err := ParseScenario([]byte(`{"steps": [
+		{"load": ["chain.json"]},
+		{"after": "5s", "mine": 2},
+		{"at_block": 15, "faults": {"getblock": {"mode": "rpc_error", "rate": 1}}}
+	]}`))
+	require.NoError(t, err)
+	assert.Equal(t, yamlScenario, jsonScenario)
+
+	require.Len(t, yamlScenario.Steps, 3)
+	assert.Equal(t, Duration(5*time.Second), *yamlScenario.Steps[1].After)
+	assert.Equal(t, int32(15), *yamlScenario.Steps[2].AtBlock)
+	assert.Equal(t, Fault{Mode: FaultRPCError, Rate: 1}, yamlScenario.Steps[2].Faults["getblock"])
+
+	for _, test := range []struct {
+		scenario string
+		err      string
+	}{
+		{"steps: [{at: 1s, after: 1s, mine: 1}]", "step 1: at most one of at, after and at_block can be set"},
+		{"steps: [{mine: 1}, {after: 1s}]", "step 2: no action"},
+		{"steps: [{mine: 1, wait: 1s}]", `unknown field "wait"`},
+		{"steps: [{faults: {getblock: {mode: crash}}}]", `step 1: getblock: unknown fault mode "crash"`},
+	} {
+		_, err := ParseScenario([]byte(test.scenario))
+		assert.ErrorContains(t, err, test.err, test.scenario)
+	}
+}
+
+func TestReadScenario(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("steps: [{load: [chain.json, /data/other.json]}]"), 0o600))
+
+	scenario, err := ReadScenario(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(filepath.Dir(path), "chain.json"), "/data/other.json"}, scenario.Steps[0].Load)
+}
+
+func TestRunScenario(t *testing.T) {
+	dataStore := &DataStore{}
+	serverHandler := &MockServerHandler{Store: dataStore, Faults: NewFaultInjector()}
+	miner := &Miner{Store: dataStore}
+	ctx := context.Background()
+
+	scenario, err := ParseScenario([]byte(`
+steps:
+  - load: [../data/mainnet_oldest_blocks.json]
+  - after: 10ms
+    mine: 2
+  - at_block: 12
+    reorg: 1
+    faults:
+      getblock: {mode: rpc_error, rate: 1}
+  - at: 50ms
+    clear_faults: true
+`))
+	require.NoError(t, err)
+	start := time.Now()
+	require.NoError(t, RunScenario(ctx, serverHandler, miner, scenario))
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	blockCount, err := serverHandler.GetBlockCount()
+	require.NoError(t, err)
+	assert.Equal(t, int32(13), blockCount)
+	assert.Empty(t, serverHandler.Faults.Faults())
+
+	// a trigger that never fires waits for the context
+	scenario, err = ParseScenario([]byte(`steps: [{at_block: 100, mine: 1}]`))
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, RunScenario(ctx, serverHandler, miner, scenario), context.DeadlineExceeded)
+
+	// a failing step stops the scenario
+	scenario, err = ParseScenario([]byte(`steps: [{reorg: 100}, {mine: 1}]`))
+	require.NoError(t, err)
+	assert.ErrorContains(t, RunScenario(context.Background(), serverHandler, miner, scenario), "scenario step 1: reorg depth 100 is out of range")
+	blockCount, err = serverHandler.GetBlockCount()
+	require.NoError(t, err)
+	assert.Equal(t, int32(13), blockCount)
+}
+
+func TestRunScenarioMockTime(t *testing.T) {
+	serverHandler := NewMockServerHandler("../data/mainnet_oldest_blocks.json")
+	miner := &Miner{Store: serverHandler.Store, Clock: serverHandler.Clock}
+	blockCount := func() int32 {
+		blockCount, err := serverHandler.GetBlockCount()
+		assert.NoError(t, err)
+		return blockCount
+	}
+	require.NoError(t, serverHandler.SetMockTime(1700000000))
+
+	scenario, err := ParseScenario([]byte(`steps: [{after: 1h, mine: 1}, {at: 90m, mine: 1}]`))
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() { done <- RunScenario(context.Background(), serverHandler, miner, scenario) }()
+
+	// the triggers only fire when the mock time reaches them
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(10), blockCount())
+	_, err = serverHandler.MockAdvanceTime(3600)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return blockCount() == 11 }, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, serverHandler.SetMockTime(1700000000+5400))
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("scenario did not finish")
+	}
+	assert.Equal(t, int32(12), blockCount())
+}
+
+// TestRunScenarioNetwork loads a raw block fixture of the network of the
+// handler.
+func TestRunScenarioNetwork(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "blocks.hex"), []byte(blockHex(t, bip34Block(t, 5))), 0o600))
+	scenarioPath := filepath.Join(dir, "scenario.yaml")
+	require.NoError(t, os.WriteFile(scenarioPath, []byte("steps: [{load: [blocks.hex]}]"), 0o600))
+	scenario, err := ReadScenario(scenarioPath)
+	require.NoError(t, err)
+
+	dataStore := &DataStore{}
+	serverHandler := &MockServerHandler{Store: dataStore, Params: &chaincfg.RegressionNetParams}
+	require.NoError(t, RunScenario(context.Background(), serverHandler, &Miner{Store: dataStore}, scenario))
+	blockCount, err := serverHandler.GetBlockCount()
+	require.NoError(t, err)
+	assert.Equal(t, int32(5), blockCount)
+}